@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RESTART_BACKOFF_STRATEGY values.
+const (
+	backoffConstant    = "constant"
+	backoffLinear      = "linear"
+	backoffExponential = "exponential"
+	backoffFibonacci   = "fibonacci"
+)
+
+const defaultBackoffBase = time.Second
+
+// backoffFunc returns how long to wait before restarting a command whose
+// attempt'th attempt (starting at 1) failed.
+type backoffFunc func(attempt int, base time.Duration) time.Duration
+
+var backoffStrategies = map[string]backoffFunc{
+	backoffConstant:    constantDelay,
+	backoffLinear:      linearDelay,
+	backoffExponential: exponentialDelay,
+	backoffFibonacci:   fibonacciDelay,
+}
+
+// constantDelay waits base after every attempt: 1, 1, 1, 1, ...
+func constantDelay(attempt int, base time.Duration) time.Duration {
+	return base
+}
+
+// linearDelay waits attempt times base: 1, 2, 3, 4, ...
+func linearDelay(attempt int, base time.Duration) time.Duration {
+	return scaleDelay(base, float64(attempt))
+}
+
+// exponentialDelay doubles the wait after every attempt: 1, 2, 4, 8, ...
+func exponentialDelay(attempt int, base time.Duration) time.Duration {
+	return scaleDelay(base, math.Pow(2, float64(attempt-1)))
+}
+
+// fibonacciDelay follows the Fibonacci sequence: 1, 1, 2, 3, 5, 8, ...
+func fibonacciDelay(attempt int, base time.Duration) time.Duration {
+	a, b := 1.0, 1.0
+	for i := 1; i < attempt; i++ {
+		a, b = b, a+b
+	}
+	return scaleDelay(base, a)
+}
+
+// scaleDelay multiplies base by n, saturating instead of overflowing for
+// runs that have been retried for a very long time.
+func scaleDelay(base time.Duration, n float64) time.Duration {
+	d := float64(base) * n
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// loadRestartBackoff reads RESTART_BACKOFF_STRATEGY,
+// RESTART_BACKOFF_BASE_SEC and RESTART_BACKOFF_MAX_SEC. Without a strategy,
+// failed commands are restarted immediately, as before.
+func loadRestartBackoff(cfg *config) error {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv("RESTART_BACKOFF_STRATEGY")))
+	if v == "" {
+		for _, name := range []string{"RESTART_BACKOFF_BASE_SEC", "RESTART_BACKOFF_MAX_SEC"} {
+			if os.Getenv(name) != "" {
+				return fmt.Errorf("%s requires RESTART_BACKOFF_STRATEGY", name)
+			}
+		}
+		return nil
+	}
+	next, ok := backoffStrategies[v]
+	if !ok {
+		return fmt.Errorf("Invalid RESTART_BACKOFF_STRATEGY value '%s' (expected %s, %s, %s or %s)", v, backoffConstant, backoffLinear, backoffExponential, backoffFibonacci)
+	}
+	base, err := timeoutEnv("RESTART_BACKOFF_BASE_SEC", defaultBackoffBase)
+	if err != nil {
+		return err
+	}
+	var limit time.Duration
+	if os.Getenv("RESTART_BACKOFF_MAX_SEC") != "" {
+		if limit, err = timeoutEnv("RESTART_BACKOFF_MAX_SEC", 0); err != nil {
+			return err
+		}
+		if limit < base {
+			return fmt.Errorf("RESTART_BACKOFF_MAX_SEC (%v) must not be less than RESTART_BACKOFF_BASE_SEC (%v)", limit, base)
+		}
+	}
+	cfg.restartBackoff = v
+	cfg.restartBackoffBase = base
+	cfg.restartBackoffMax = limit
+	cfg.nextDelay = next
+	return nil
+}
+
+// loadRetryExitCodes reads CRON_RETRY_EXIT_CODES and
+// CRON_NO_RETRY_EXIT_CODES, comma-separated exit codes from 1 to 255 and
+// ranges of them.
+func loadRetryExitCodes(cfg *config) error {
+	var err error
+	if cfg.retryExitCodes, err = parseExitCodes("CRON_RETRY_EXIT_CODES"); err != nil {
+		return err
+	}
+	if cfg.noRetryExitCodes, err = parseExitCodes("CRON_NO_RETRY_EXIT_CODES"); err != nil {
+		return err
+	}
+	if cfg.retryExitCodes == nil && cfg.noRetryExitCodes == nil {
+		return nil
+	}
+	if cfg.retryExitCodes != nil && cfg.noRetryExitCodes != nil {
+		return fmt.Errorf("CRON_RETRY_EXIT_CODES and CRON_NO_RETRY_EXIT_CODES cannot both be set")
+	}
+	if !cfg.restartOnFail {
+		name := "CRON_RETRY_EXIT_CODES"
+		if cfg.noRetryExitCodes != nil {
+			name = "CRON_NO_RETRY_EXIT_CODES"
+		}
+		return fmt.Errorf("%s requires RESTART_ON_FAIL", name)
+	}
+	return nil
+}
+
+// parseExitCodes reads a comma-separated list of exit codes and ranges such
+// as 75-78, returned sorted and without duplicates.
+func parseExitCodes(name string) ([]int, error) {
+	v := os.Getenv(name)
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	var codes []int
+	for _, item := range splitList(v) {
+		lo, hi, err := parseExitCodeRange(item)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s value '%s' (expected exit codes from 1 to 255, or ranges such as 75-78)", name, item)
+		}
+		for code := lo; code <= hi; code++ {
+			if !slices.Contains(codes, code) {
+				codes = append(codes, code)
+			}
+		}
+	}
+	if codes == nil {
+		return nil, fmt.Errorf("Invalid %s value '%s'", name, v)
+	}
+	slices.Sort(codes)
+	return codes, nil
+}
+
+// parseExitCodeRange parses one exit code, or a range of them with both
+// ends included.
+func parseExitCodeRange(item string) (lo, hi int, err error) {
+	first, last, isRange := strings.Cut(item, "-")
+	if lo, err = parseExitCode(first); err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return lo, lo, nil
+	}
+	if hi, err = parseExitCode(last); err != nil {
+		return 0, 0, err
+	}
+	if hi < lo {
+		return 0, 0, fmt.Errorf("range %s ends before it starts", item)
+	}
+	return lo, hi, nil
+}
+
+func parseExitCode(s string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(s))
+	if err == nil && (code < 1 || code > 255) {
+		err = fmt.Errorf("exit code %d out of range", code)
+	}
+	return code, err
+}
+
+// shouldRestart reports whether RESTART_ON_FAIL restarts an attempt that
+// ended with exitCode, or was killed on a timeout. Killed attempts have no
+// exit code of their own and are always restarted.
+func (cfg *config) shouldRestart(exitCode int, killed bool) bool {
+	switch {
+	case !cfg.restartOnFail:
+		return false
+	case killed:
+		return true
+	case exitCode == 0:
+		return false
+	case cfg.retryExitCodes != nil:
+		return slices.Contains(cfg.retryExitCodes, exitCode)
+	case cfg.noRetryExitCodes != nil:
+		return !slices.Contains(cfg.noRetryExitCodes, exitCode)
+	}
+	return true
+}
+
+// restartDelay is how long to wait before the attempt after the given one,
+// at most RESTART_BACKOFF_MAX_SEC. It is false when the run's hard deadline
+// would pass before the wait is over: no attempt could start after it, so
+// the run ends right away.
+func (r *runner) restartDelay(attempt int, hardDeadline time.Time) (time.Duration, bool) {
+	if r.cfg.nextDelay == nil {
+		return 0, true
+	}
+	d := r.cfg.nextDelay(attempt, r.cfg.restartBackoffBase)
+	if r.cfg.restartBackoffMax > 0 && d > r.cfg.restartBackoffMax {
+		d = r.cfg.restartBackoffMax
+	}
+	if !hardDeadline.IsZero() && d >= time.Until(hardDeadline) {
+		return d, false
+	}
+	return d, true
+}
+
+// sleepUnlessStopping waits for d and reports whether it did so without
+// cronrunner starting to shut down or OVERLAP_STRATEGY=replace stopping
+// the run.
+func (r *runner) sleepUnlessStopping(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.stopped:
+		return false
+	case <-r.interrupt:
+		return false
+	}
+}
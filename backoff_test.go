@@ -0,0 +1,224 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBackoffStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     []time.Duration // waits after attempts 1, 2, 3, ...
+	}{
+		{backoffConstant, []time.Duration{2, 2, 2, 2, 2, 2}},
+		{backoffLinear, []time.Duration{2, 4, 6, 8, 10, 12}},
+		{backoffExponential, []time.Duration{2, 4, 8, 16, 32, 64}},
+		{backoffFibonacci, []time.Duration{2, 2, 4, 6, 10, 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			next := backoffStrategies[tt.strategy]
+			var got []time.Duration
+			for attempt := 1; attempt <= len(tt.want); attempt++ {
+				got = append(got, next(attempt, 2*time.Second)/time.Second)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("waits in seconds = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffSaturates(t *testing.T) {
+	tests := []struct {
+		name string
+		got  time.Duration
+	}{
+		{"exponential", exponentialDelay(100, time.Hour)},
+		{"exponential past float range", exponentialDelay(2000, time.Second)},
+		{"fibonacci", fibonacciDelay(200, time.Second)},
+		{"linear", linearDelay(math.MaxInt, time.Hour)},
+		{"scale at the limit", scaleDelay(math.MaxInt64, 1)},
+		{"scale past the limit", scaleDelay(time.Hour, 1e300)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != math.MaxInt64 {
+				t.Errorf("got %v, want the largest duration", tt.got)
+			}
+		})
+	}
+}
+
+func TestScaleDelay(t *testing.T) {
+	if got := scaleDelay(time.Second, 1.5); got != 1500*time.Millisecond {
+		t.Errorf("scaleDelay(1s, 1.5) = %v, want 1.5s", got)
+	}
+	if got := scaleDelay(time.Minute, 0); got != 0 {
+		t.Errorf("scaleDelay(1m, 0) = %v, want 0", got)
+	}
+}
+
+func TestRestartDelayMax(t *testing.T) {
+	r := &runner{cfg: &config{
+		nextDelay:          exponentialDelay,
+		restartBackoffBase: time.Second,
+		restartBackoffMax:  10 * time.Second,
+	}}
+	for attempt, want := range map[int]time.Duration{
+		1:   time.Second,
+		4:   8 * time.Second,
+		5:   10 * time.Second,
+		100: 10 * time.Second,
+	} {
+		if d, ok := r.restartDelay(attempt, time.Time{}); d != want || !ok {
+			t.Errorf("restartDelay(%d) = %v, %v; want %v, true", attempt, d, ok, want)
+		}
+	}
+	// The cap still counts against the deadline.
+	if d, ok := r.restartDelay(100, time.Now().Add(5*time.Second)); d != 10*time.Second || ok {
+		t.Errorf("restartDelay(100) with 5s left = %v, %v; want 10s, false", d, ok)
+	}
+}
+
+func TestLoadRestartBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantErr  bool
+		wantBase time.Duration
+		wantMax  time.Duration
+	}{
+		{name: "unset"},
+		{name: "default base", env: map[string]string{"RESTART_BACKOFF_STRATEGY": "Linear"}, wantBase: time.Second},
+		{name: "base and max", env: map[string]string{"RESTART_BACKOFF_STRATEGY": "exponential", "RESTART_BACKOFF_BASE_SEC": "500ms", "RESTART_BACKOFF_MAX_SEC": "300"}, wantBase: 500 * time.Millisecond, wantMax: 5 * time.Minute},
+		{name: "max equal to base", env: map[string]string{"RESTART_BACKOFF_STRATEGY": "constant", "RESTART_BACKOFF_BASE_SEC": "5", "RESTART_BACKOFF_MAX_SEC": "5s"}, wantBase: 5 * time.Second, wantMax: 5 * time.Second},
+		{name: "unknown strategy", env: map[string]string{"RESTART_BACKOFF_STRATEGY": "random"}, wantErr: true},
+		{name: "base without strategy", env: map[string]string{"RESTART_BACKOFF_BASE_SEC": "5"}, wantErr: true},
+		{name: "max without strategy", env: map[string]string{"RESTART_BACKOFF_MAX_SEC": "60"}, wantErr: true},
+		{name: "zero base", env: map[string]string{"RESTART_BACKOFF_STRATEGY": "linear", "RESTART_BACKOFF_BASE_SEC": "0"}, wantErr: true},
+		{name: "zero max", env: map[string]string{"RESTART_BACKOFF_STRATEGY": "linear", "RESTART_BACKOFF_MAX_SEC": "0"}, wantErr: true},
+		{name: "max below base", env: map[string]string{"RESTART_BACKOFF_STRATEGY": "linear", "RESTART_BACKOFF_BASE_SEC": "10", "RESTART_BACKOFF_MAX_SEC": "5"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"RESTART_BACKOFF_STRATEGY", "RESTART_BACKOFF_BASE_SEC", "RESTART_BACKOFF_MAX_SEC"} {
+				t.Setenv(name, tt.env[name])
+			}
+			var cfg config
+			err := loadRestartBackoff(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadRestartBackoff() error = %v, want error %v", err, tt.wantErr)
+			}
+			if cfg.restartBackoffBase != tt.wantBase || cfg.restartBackoffMax != tt.wantMax {
+				t.Errorf("got base %v max %v, want base %v max %v", cfg.restartBackoffBase, cfg.restartBackoffMax, tt.wantBase, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestParseExitCodes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: " ", want: nil},
+		{value: "75", want: []int{75}},
+		{value: "111, 75", want: []int{75, 111}},
+		{value: "75-78", want: []int{75, 76, 77, 78}},
+		{value: "2,75-77,64", want: []int{2, 64, 75, 76, 77}},
+		{value: "75 - 76", want: []int{75, 76}},
+		{value: "5-5", want: []int{5}},
+		{value: "75,75,76-77,77", want: []int{75, 76, 77}},
+		{value: "250-255", want: []int{250, 251, 252, 253, 254, 255}},
+		{value: ",", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "256", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "78-75", wantErr: true},
+		{value: "0-5", wantErr: true},
+		{value: "250-256", wantErr: true},
+		{value: "75-", wantErr: true},
+		{value: "-75", wantErr: true},
+		{value: "1-2-3", wantErr: true},
+		{value: "75,x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("CRON_RETRY_EXIT_CODES", tt.value)
+			got, err := parseExitCodes("CRON_RETRY_EXIT_CODES")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseExitCodes(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseExitCodes(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRetryExitCodes(t *testing.T) {
+	tests := []struct {
+		name           string
+		restartOnFail  bool
+		retry, noRetry string
+		wantErr        bool
+	}{
+		{name: "unset"},
+		{name: "retry", restartOnFail: true, retry: "75"},
+		{name: "no retry", restartOnFail: true, noRetry: "2"},
+		{name: "both set", restartOnFail: true, retry: "75", noRetry: "2", wantErr: true},
+		{name: "retry without restart", retry: "75", wantErr: true},
+		{name: "no retry without restart", noRetry: "2", wantErr: true},
+		{name: "invalid", restartOnFail: true, retry: "75-x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CRON_RETRY_EXIT_CODES", tt.retry)
+			t.Setenv("CRON_NO_RETRY_EXIT_CODES", tt.noRetry)
+			cfg := config{restartOnFail: tt.restartOnFail}
+			if err := loadRetryExitCodes(&cfg); (err != nil) != tt.wantErr {
+				t.Errorf("loadRetryExitCodes() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	always := &config{restartOnFail: true}
+	retry := &config{restartOnFail: true, retryExitCodes: []int{75, 76, 77}}
+	noRetry := &config{restartOnFail: true, noRetryExitCodes: []int{2, 64}}
+	tests := []struct {
+		name     string
+		cfg      *config
+		exitCode int
+		killed   bool
+		want     bool
+	}{
+		{"restart off", &config{}, 1, false, false},
+		{"restart off and killed", &config{}, exitTimedOut, true, false},
+		{"exit 0", always, 0, false, false},
+		{"failure", always, 1, false, true},
+		{"killed", always, exitTimedOut, true, true},
+		{"retry listed", retry, 76, false, true},
+		{"retry not listed", retry, 1, false, false},
+		{"retry exit 0", retry, 0, false, false},
+		{"retry killed", retry, exitTimedOut, true, true},
+		{"no retry listed", noRetry, 64, false, false},
+		{"no retry not listed", noRetry, 1, false, true},
+		{"no retry exit 0", noRetry, 0, false, false},
+		{"no retry killed", &config{restartOnFail: true, noRetryExitCodes: []int{exitTimedOut}}, exitTimedOut, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.shouldRestart(tt.exitCode, tt.killed); got != tt.want {
+				t.Errorf("shouldRestart(%d, %v) = %v, want %v", tt.exitCode, tt.killed, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+// Values accepted by CRON_BREAKER_ACTION.
+const (
+	breakerPause = "pause"
+	breakerExit  = "exit"
+)
+
+// breakerStatus is the circuit breaker's state as served on /status.
+type breakerStatus struct {
+	Threshold           int    `json:"threshold"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Open                bool   `json:"open"`
+	Action              string `json:"action"`
+}
+
+// countRun updates the consecutive failure count with the outcome of rec
+// and reports whether this run opened the breaker. r.mu must be held.
+func (r *runner) countRun(rec runRecord) bool {
+	if r.cfg.maxConsecutiveFailures == 0 {
+		return false
+	}
+	if rec.succeeded() {
+		r.failures = 0
+		return false
+	}
+	r.failures++
+	if r.failures < r.cfg.maxConsecutiveFailures || r.breakerOpen {
+		return false
+	}
+	r.breakerOpen = true
+	return true
+}
+
+// tripBreaker stops further runs once CRON_MAX_CONSECUTIVE_FAILURES runs in
+// a row have failed, either by pausing the scheduler or by asking main to
+// shut down and exit non-zero.
+func (r *runner) tripBreaker() {
+	logf(LevelWarn, "Circuit breaker open after %d consecutive failed runs", r.cfg.maxConsecutiveFailures)
+	switch r.cfg.breakerAction {
+	case breakerExit:
+		select {
+		case r.tripped <- struct{}{}:
+		default:
+		}
+	default:
+		r.setPaused(true, "circuit breaker")
+	}
+}
+
+// breakerTripped is signalled when the breaker opens with
+// CRON_BREAKER_ACTION=exit.
+func (r *runner) breakerTripped() <-chan struct{} {
+	return r.tripped
+}
+
+// breakerState reports the breaker for /status, or nil when it is disabled.
+// r.mu must be held.
+func (r *runner) breakerState() *breakerStatus {
+	if r.cfg.maxConsecutiveFailures == 0 {
+		return nil
+	}
+	return &breakerStatus{
+		Threshold:           r.cfg.maxConsecutiveFailures,
+		ConsecutiveFailures: r.failures,
+		Open:                r.breakerOpen,
+		Action:              r.cfg.breakerAction,
+	}
+}
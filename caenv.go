@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// loadCAEnv reads CRON_CA_CERT_FILE and CRON_CA_CERT_DIR into the variables
+// set for the command: SSL_CERT_FILE (OpenSSL, Go, curl, Python) and
+// NODE_EXTRA_CA_CERTS for the file, SSL_CERT_DIR for the directory. Both
+// are checked for parseable certificates now rather than failing inside
+// the command at run time. Like CRON_WORKDIR they are paths inside
+// CRON_CHROOT when it is set.
+func loadCAEnv(chroot string) (map[string]string, error) {
+	env := map[string]string{}
+	if file := strings.TrimSpace(os.Getenv("CRON_CA_CERT_FILE")); file != "" {
+		host, err := hostPath(chroot, file)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CRON_CA_CERT_FILE '%s': %v", file, err)
+		}
+		b, err := os.ReadFile(host)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CRON_CA_CERT_FILE: %v", err)
+		}
+		n, err := countPEMCerts(b)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CRON_CA_CERT_FILE '%s': %v", file, err)
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("Invalid CRON_CA_CERT_FILE '%s': no PEM certificates", file)
+		}
+		env["SSL_CERT_FILE"] = file
+		env["NODE_EXTRA_CA_CERTS"] = file
+	}
+	if dir := strings.TrimSpace(os.Getenv("CRON_CA_CERT_DIR")); dir != "" {
+		host, err := hostPath(chroot, dir)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CRON_CA_CERT_DIR '%s': %v", dir, err)
+		}
+		entries, err := os.ReadDir(host)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CRON_CA_CERT_DIR: %v", err)
+		}
+		// Hashed directories (c_rehash, update-ca-certificates) may hold
+		// other files besides the certificates, so only blocks that claim
+		// to be certificates have to parse.
+		total := 0
+		for _, e := range entries {
+			p := filepath.Join(host, e.Name())
+			if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			b, err := os.ReadFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid CRON_CA_CERT_DIR: %v", err)
+			}
+			n, err := countPEMCerts(b)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid CRON_CA_CERT_DIR '%s': %s: %v", dir, e.Name(), err)
+			}
+			total += n
+		}
+		if total == 0 {
+			return nil, fmt.Errorf("Invalid CRON_CA_CERT_DIR '%s': no PEM certificates", dir)
+		}
+		env["SSL_CERT_DIR"] = dir
+	}
+	return env, nil
+}
+
+// hostPath returns where p, a path as the command sees it, is found from
+// cronrunner: inside chroot when one is set.
+func hostPath(chroot, p string) (string, error) {
+	if chroot == "" {
+		return p, nil
+	}
+	if !path.IsAbs(p) {
+		return "", fmt.Errorf("must be an absolute path inside CRON_CHROOT")
+	}
+	return filepath.Join(chroot, p), nil
+}
+
+// countPEMCerts returns the number of CERTIFICATE blocks in b, failing on
+// the first one that doesn't parse.
+func countPEMCerts(b []byte) (int, error) {
+	n := 0
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return n, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return n, fmt.Errorf("certificate %d: %v", n+1, err)
+		}
+		n++
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const capsSupported = true
+
+var capabilityNames = map[string]int{
+	"CHOWN": unix.CAP_CHOWN, "DAC_OVERRIDE": unix.CAP_DAC_OVERRIDE,
+	"DAC_READ_SEARCH": unix.CAP_DAC_READ_SEARCH, "FOWNER": unix.CAP_FOWNER,
+	"FSETID": unix.CAP_FSETID, "KILL": unix.CAP_KILL, "SETGID": unix.CAP_SETGID,
+	"SETUID": unix.CAP_SETUID, "SETPCAP": unix.CAP_SETPCAP,
+	"LINUX_IMMUTABLE": unix.CAP_LINUX_IMMUTABLE, "NET_BIND_SERVICE": unix.CAP_NET_BIND_SERVICE,
+	"NET_BROADCAST": unix.CAP_NET_BROADCAST, "NET_ADMIN": unix.CAP_NET_ADMIN,
+	"NET_RAW": unix.CAP_NET_RAW, "IPC_LOCK": unix.CAP_IPC_LOCK, "IPC_OWNER": unix.CAP_IPC_OWNER,
+	"SYS_MODULE": unix.CAP_SYS_MODULE, "SYS_RAWIO": unix.CAP_SYS_RAWIO,
+	"SYS_CHROOT": unix.CAP_SYS_CHROOT, "SYS_PTRACE": unix.CAP_SYS_PTRACE,
+	"SYS_PACCT": unix.CAP_SYS_PACCT, "SYS_ADMIN": unix.CAP_SYS_ADMIN, "SYS_BOOT": unix.CAP_SYS_BOOT,
+	"SYS_NICE": unix.CAP_SYS_NICE, "SYS_RESOURCE": unix.CAP_SYS_RESOURCE,
+	"SYS_TIME": unix.CAP_SYS_TIME, "SYS_TTY_CONFIG": unix.CAP_SYS_TTY_CONFIG,
+	"MKNOD": unix.CAP_MKNOD, "LEASE": unix.CAP_LEASE, "AUDIT_WRITE": unix.CAP_AUDIT_WRITE,
+	"AUDIT_CONTROL": unix.CAP_AUDIT_CONTROL, "SETFCAP": unix.CAP_SETFCAP,
+	"MAC_OVERRIDE": unix.CAP_MAC_OVERRIDE, "MAC_ADMIN": unix.CAP_MAC_ADMIN,
+	"SYSLOG": unix.CAP_SYSLOG, "WAKE_ALARM": unix.CAP_WAKE_ALARM,
+	"BLOCK_SUSPEND": unix.CAP_BLOCK_SUSPEND, "AUDIT_READ": unix.CAP_AUDIT_READ,
+	"PERFMON": unix.CAP_PERFMON, "BPF": unix.CAP_BPF, "CHECKPOINT_RESTORE": unix.CAP_CHECKPOINT_RESTORE,
+}
+
+// parseCapabilities resolves CRON_DROP_CAPS names such as CAP_NET_RAW or
+// net_raw; ALL stands for every capability.
+func parseCapabilities(names []string) ([]int, error) {
+	var caps []int
+	for _, name := range names {
+		key := strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+		if key == "ALL" {
+			caps = caps[:0]
+			for c := 0; c <= unix.CAP_LAST_CAP; c++ {
+				caps = append(caps, c)
+			}
+			return caps, nil
+		}
+		c, ok := capabilityNames[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown capability '%s'", name)
+		}
+		caps = append(caps, c)
+	}
+	return caps, nil
+}
+
+// checkCapability reports an error unless cronrunner holds the capability c
+// (called name in the message) in its effective set.
+func checkCapability(c int, name string) error {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("failed to read cronrunner's capabilities: %v", err)
+	}
+	if data[c/32].Effective&(1<<(c%32)) == 0 {
+		return fmt.Errorf("cronrunner lacks %s; run it as root or grant the capability", name)
+	}
+	return nil
+}
+
+// helperExecutable is the binary started as the pre-exec helper:
+// cronrunner itself, through /proc so that it works even if the file has
+// been replaced since.
+func helperExecutable() (string, error) {
+	return "/proc/self/exe", nil
+}
+
+// enterNetns joins CRON_NETNS_PATH, or brings up loopback in the namespace
+// created for CRON_NEW_NETNS.
+func enterNetns(o *helperOptions) error {
+	if o.netns != "" {
+		if err := joinNetns(o.netns); err != nil {
+			return fmt.Errorf("failed to join network namespace %s: %w", o.netns, err)
+		}
+	}
+	if o.loUp {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("failed to bring up loopback in the new network namespace: %w", err)
+		}
+	}
+	return nil
+}
+
+// restrictBeforeSwitch drops CRON_DROP_CAPS from the bounding set before
+// the helper switches to the CRON_RUN_AS_USER user, which takes the
+// privileges for it away.
+func restrictBeforeSwitch(o *helperOptions) error {
+	// Like runc, the filter goes in as late as possible, so it needn't
+	// allow the calls made here: right before exec with no_new_privs, and
+	// otherwise while cronrunner still has CAP_SYS_ADMIN, which the kernel
+	// then requires.
+	if o.seccomp != "" && !o.noNewPrivs {
+		if err := installSeccomp(o.seccomp); err != nil {
+			return fmt.Errorf("failed to install seccomp filter: %w", err)
+		}
+	}
+	for _, c := range o.caps {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to drop capability %d from the bounding set (cronrunner needs CAP_SETPCAP): %w", c, err)
+		}
+		_ = unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_LOWER, uintptr(c), 0, 0)
+	}
+	return nil
+}
+
+// restrictAfterSwitch clears the capabilities that remain once the user has
+// been switched.
+func restrictAfterSwitch(o *helperOptions) error {
+	if len(o.caps) > 0 {
+		if err := clearCapabilities(o.caps); err != nil {
+			return fmt.Errorf("failed to drop capabilities: %w", err)
+		}
+	}
+	return nil
+}
+
+// lockDown sets no_new_privs, with the seccomp filter right after it, as
+// the last step before the exec.
+func lockDown(o *helperOptions) error {
+	if o.noNewPrivs {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to set no_new_privs: %w", err)
+		}
+		if o.seccomp != "" {
+			if err := installSeccomp(o.seccomp); err != nil {
+				return fmt.Errorf("failed to install seccomp filter: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// clearCapabilities removes caps from the effective, permitted and
+// inheritable sets of the calling thread.
+func clearCapabilities(caps []int) error {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return err
+	}
+	for _, c := range caps {
+		mask := ^uint32(1 << (c % 32))
+		d := &data[c/32]
+		d.Effective &= mask
+		d.Permitted &= mask
+		d.Inheritable &= mask
+	}
+	return unix.Capset(&hdr, &data[0])
+}
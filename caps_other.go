@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+const capsSupported = false
+
+func parseCapabilities(names []string) ([]int, error) {
+	return nil, errors.New("capabilities are only supported on Linux")
+}
@@ -0,0 +1,21 @@
+//go:build unix && !linux
+
+package main
+
+import "os"
+
+// helperExecutable is the binary started as the pre-exec helper.
+func helperExecutable() (string, error) {
+	return os.Executable()
+}
+
+// The Linux-only settings are rejected at startup elsewhere, so there is
+// nothing to apply around the user switch.
+
+func enterNetns(o *helperOptions) error { return nil }
+
+func restrictBeforeSwitch(o *helperOptions) error { return nil }
+
+func restrictAfterSwitch(o *helperOptions) error { return nil }
+
+func lockDown(o *helperOptions) error { return nil }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const chrootSupported = true
+
+// checkChroot verifies at startup that dir can serve as CRON_CHROOT: it must
+// be a readable directory, and cronrunner needs CAP_SYS_CHROOT to enter it.
+func checkChroot(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("'%s' is not an absolute path", dir)
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+	if _, err := f.Readdirnames(1); err != nil && err != io.EOF {
+		return fmt.Errorf("'%s' is not readable: %v", dir, err)
+	}
+	return checkCapability(unix.CAP_SYS_CHROOT, "CAP_SYS_CHROOT")
+}
+
+// chrootLookPath resolves file like exec.LookPath, but against the
+// filesystem under root, returning the path as seen inside the chroot.
+func chrootLookPath(root, file string) (string, error) {
+	if strings.Contains(file, "/") {
+		if err := executableIn(root, file); err != nil {
+			return "", &exec.Error{Name: file, Err: err}
+		}
+		return file, nil
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		p := filepath.Join(dir, file)
+		if executableIn(root, p) == nil {
+			return p, nil
+		}
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
+
+func executableIn(root, p string) error {
+	info, err := os.Stat(filepath.Join(root, p))
+	if err != nil {
+		return err
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return os.ErrPermission
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+const chrootSupported = false
+
+func checkChroot(dir string) error {
+	return errors.New("chroot is only supported on Linux")
+}
+
+func chrootLookPath(root, file string) (string, error) {
+	return "", errors.New("chroot is only supported on Linux")
+}
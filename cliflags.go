@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"os"
+	"strconv"
+)
+
+// cliFlag mirrors one environment variable as a command-line flag, for
+// running cronrunner by hand. Flags that are given override the variable;
+// the rest of the configuration still comes from the environment.
+type cliFlag struct {
+	name   string
+	env    string
+	usage  string
+	base64 bool // the variable is base64 encoded; the flag takes plain text
+	isBool bool
+	// unsets names a variable that would conflict with the flag, so the
+	// flag wins over it rather than being rejected.
+	unsets string
+}
+
+var cliFlags = []cliFlag{
+	{name: "cron", env: "CRON_EXPRESSION", usage: "schedule, as plain text (overrides CRON_EXPRESSION)", base64: true, unsets: "CRON_EXPRESSION_FILE"},
+	{name: "cron-file", env: "CRON_EXPRESSION_FILE", usage: "read the schedule from this `file` (overrides CRON_EXPRESSION_FILE)", unsets: "CRON_EXPRESSION"},
+	{name: "cmd", env: "CRON_CMD", usage: "command to execute, as plain text (overrides CRON_CMD)", base64: true, unsets: "CRON_CMD_FILE"},
+	{name: "cmd-file", env: "CRON_CMD_FILE", usage: "read the command from this `file` before every run (overrides CRON_CMD_FILE)", unsets: "CRON_CMD"},
+	{name: "shell", env: "CRON_SHELL", usage: "run the command through `shell` -c (overrides CRON_SHELL)"},
+	{name: "parser", env: "CRON_PARSER", usage: "schedule syntax: seconds or standard (overrides CRON_PARSER)"},
+	{name: "tz", env: "CRON_TZ", usage: "scheduler timezone (overrides CRON_TZ)"},
+	{name: "kill-after-min", env: "CRON_TOTAL_TIMEOUT_MIN", usage: "kill the command after this many minutes (overrides CRON_KILL_AFTER_MIN and CRON_TOTAL_TIMEOUT_MIN)", unsets: "CRON_KILL_AFTER_SEC"},
+	{name: "attempt-timeout-min", env: "CRON_ATTEMPT_TIMEOUT_MIN", usage: "kill each attempt after this many minutes (overrides CRON_ATTEMPT_TIMEOUT_MIN)"},
+	{name: "log-file", env: "LOG_FILE", usage: "append command output to this `file` (overrides LOG_FILE)"},
+	{name: "restart-on-fail", env: "RESTART_ON_FAIL", usage: "rerun the command until it exits 0 (overrides RESTART_ON_FAIL)", isBool: true},
+	{name: "allow-concurrent", env: "ALLOW_CONCURRENT", usage: "allow overlapping runs (overrides ALLOW_CONCURRENT)", isBool: true},
+	{name: "health-port", env: "HEALTH_PORT", usage: "serve the HTTP endpoints on this `port` (overrides HEALTH_PORT)"},
+}
+
+// registerFlags defines cliFlags on the default flag set. The returned
+// function, called after flag.Parse, copies the flags that were given into
+// the environment so loadConfig sees them like any other setting, and
+// returns the variables it set or unset.
+func registerFlags() func() ([]string, error) {
+	values := make(map[string]any, len(cliFlags))
+	for _, f := range cliFlags {
+		if f.isBool {
+			values[f.name] = flag.Bool(f.name, false, f.usage)
+		} else {
+			values[f.name] = flag.String(f.name, "", f.usage)
+		}
+	}
+	return func() ([]string, error) {
+		var owned []string
+		var err error
+		flag.Visit(func(fl *flag.Flag) {
+			for _, f := range cliFlags {
+				if f.name != fl.Name || err != nil {
+					continue
+				}
+				var v string
+				switch p := values[f.name].(type) {
+				case *bool:
+					v = strconv.FormatBool(*p)
+				case *string:
+					v = *p
+				}
+				if f.base64 {
+					v = base64.StdEncoding.EncodeToString([]byte(v))
+				}
+				if f.unsets != "" {
+					_ = os.Unsetenv(f.unsets)
+					owned = append(owned, f.unsets)
+				}
+				err = os.Setenv(f.env, v)
+				owned = append(owned, f.env)
+			}
+		})
+		return owned, err
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	logging "google.golang.org/api/logging/v2"
+	"google.golang.org/api/option"
+	run "google.golang.org/api/run/v2"
+)
+
+const (
+	cloudRunPollInterval = 5 * time.Second
+	cloudRunCancelWait   = 30 * time.Second
+)
+
+// cloudRunJob runs each attempt as an execution of the Cloud Run job
+// GCP_CLOUD_RUN_JOB instead of a local child process, through the Cloud
+// Run Admin API v2. The command becomes the container's arguments, and
+// the execution's logs are copied from Cloud Logging while it runs.
+type cloudRunJob struct {
+	jobs        *run.ProjectsLocationsJobsService
+	entries     *logging.EntriesService
+	credentials string
+	project     string
+	region      string
+	job         string
+}
+
+func newCloudRunJob(cfg *config) (*cloudRunJob, error) {
+	// Tokens are fetched and refreshed with this context's client, so the
+	// requests for them go through CRONRUNNER_HTTP_PROXY as well.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, newHTTPClient(cfg.notifyHTTPTimeout))
+	creds, err := google.FindDefaultCredentials(ctx, run.CloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("find Google credentials: %w", err)
+	}
+	client := &http.Client{
+		Transport: &oauth2.Transport{Source: creds.TokenSource, Base: sharedTransport},
+		Timeout:   cfg.notifyHTTPTimeout,
+	}
+	runService, err := run.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("create Cloud Run client: %w", err)
+	}
+	loggingService, err := logging.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("create Cloud Logging client: %w", err)
+	}
+	return &cloudRunJob{
+		jobs:        runService.Projects.Locations.Jobs,
+		entries:     loggingService.Entries,
+		credentials: describeGoogleCredentials(creds),
+		project:     cfg.gcpProject,
+		region:      cfg.gcpRegion,
+		job:         cfg.cloudRunJob,
+	}, nil
+}
+
+// describeGoogleCredentials names where tokens come from, for the startup
+// log.
+func describeGoogleCredentials(creds *google.Credentials) string {
+	var key struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+	}
+	if len(creds.JSON) == 0 || json.Unmarshal(creds.JSON, &key) != nil {
+		return "the metadata server"
+	}
+	if key.ClientEmail != "" {
+		return "service account " + key.ClientEmail
+	}
+	return key.Type + " credentials"
+}
+
+// name is the job's resource name.
+func (c *cloudRunJob) name() string {
+	return fmt.Sprintf("projects/%s/locations/%s/jobs/%s", c.project, c.region, c.job)
+}
+
+// cloudRunExitError reports an execution that failed or was cancelled.
+// The exit code is the failed task's, or 1 when Cloud Run doesn't say.
+type cloudRunExitError struct {
+	execution string
+	code      int
+	reason    string
+}
+
+func (e *cloudRunExitError) Error() string {
+	return fmt.Sprintf("cloud run execution %s: %s", e.execution, e.reason)
+}
+
+func (e *cloudRunExitError) ExitCode() int { return e.code }
+
+// run starts an execution for req, copies its logs to out and waits for it
+// to finish. The run's deadline becomes the task timeout; if ctx ends
+// first, the execution is cancelled.
+func (c *cloudRunJob) run(ctx context.Context, req runRequest, out io.Writer) error {
+	container := &run.GoogleCloudRunV2ContainerOverride{Args: req.argv}
+	for k, v := range req.env {
+		container.Env = append(container.Env, &run.GoogleCloudRunV2EnvVar{Name: k, Value: v})
+	}
+	overrides := &run.GoogleCloudRunV2Overrides{ContainerOverrides: []*run.GoogleCloudRunV2ContainerOverride{container}}
+	if deadline, ok := ctx.Deadline(); ok {
+		overrides.Timeout = fmt.Sprintf("%ds", int64(math.Ceil(time.Until(deadline).Seconds())))
+	}
+
+	op, err := c.jobs.Run(c.name(), &run.GoogleCloudRunV2RunJobRequest{Overrides: overrides}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("run %s: %w", c.job, err)
+	}
+	var started run.GoogleCloudRunV2Execution
+	if err := json.Unmarshal(op.Metadata, &started); err != nil || started.Name == "" {
+		return fmt.Errorf("run %s: no execution in the response", c.job)
+	}
+	execution := started.Name
+	logf(LevelInfo, "Started Cloud Run execution %s", path.Base(execution))
+
+	logs := &cloudRunLogs{job: c, execution: path.Base(execution), since: time.Now().Add(-time.Minute)}
+	ticker := time.NewTicker(cloudRunPollInterval)
+	defer ticker.Stop()
+	var ex *run.GoogleCloudRunV2Execution
+	for {
+		select {
+		case <-ctx.Done():
+			c.cancel(execution)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		ex, err = c.jobs.Executions.Get(execution).Context(ctx).Do()
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			return fmt.Errorf("get execution %s: %w", path.Base(execution), err)
+		}
+		logs.copy(ctx, out)
+		if ex.CompletionTime != "" {
+			break
+		}
+	}
+	// Cloud Logging can lag behind the execution by a few seconds.
+	select {
+	case <-ctx.Done():
+	case <-time.After(cloudRunPollInterval):
+		logs.copy(ctx, out)
+	}
+
+	if ex.FailedCount == 0 && ex.CancelledCount == 0 {
+		return nil
+	}
+	reason := fmt.Sprintf("%d task(s) failed, %d cancelled", ex.FailedCount, ex.CancelledCount)
+	for _, cond := range ex.Conditions {
+		if cond.Type == "Completed" && cond.Message != "" {
+			reason = cond.Message
+		}
+	}
+	return &cloudRunExitError{execution: path.Base(execution), code: c.exitCode(ctx, execution), reason: reason}
+}
+
+// errFoundExitCode stops paging through tasks once a failed one is found.
+var errFoundExitCode = errors.New("found exit code")
+
+// exitCode returns the exit code of the first task that failed with one.
+func (c *cloudRunJob) exitCode(ctx context.Context, execution string) int {
+	code := 1
+	err := c.jobs.Executions.Tasks.List(execution).Pages(ctx, func(resp *run.GoogleCloudRunV2ListTasksResponse) error {
+		for _, t := range resp.Tasks {
+			if t.LastAttemptResult != nil && t.LastAttemptResult.ExitCode != 0 {
+				code = int(t.LastAttemptResult.ExitCode)
+				return errFoundExitCode
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errFoundExitCode) {
+		logf(LevelWarn, "Warning: failed to read the tasks of Cloud Run execution %s: %v", path.Base(execution), err)
+	}
+	return code
+}
+
+// cancel stops an execution whose run timed out or was abandoned at
+// shutdown.
+func (c *cloudRunJob) cancel(execution string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cloudRunCancelWait)
+	defer cancel()
+	_, err := c.jobs.Executions.Cancel(execution, &run.GoogleCloudRunV2CancelExecutionRequest{}).Context(ctx).Do()
+	if err != nil {
+		logf(LevelError, "Failed to cancel Cloud Run execution %s: %v", path.Base(execution), err)
+		return
+	}
+	logf(LevelInfo, "Cancelled Cloud Run execution %s", path.Base(execution))
+}
+
+// cloudRunLogs copies an execution's log entries from Cloud Logging,
+// oldest first. Entries are read from since onwards; seen holds those
+// already copied with exactly that timestamp, so none is repeated.
+type cloudRunLogs struct {
+	job       *cloudRunJob
+	execution string
+	since     time.Time
+	seen      map[string]bool
+	warned    bool
+}
+
+func (l *cloudRunLogs) copy(ctx context.Context, out io.Writer) {
+	c := l.job
+	filter := fmt.Sprintf(`resource.type="cloud_run_job" AND resource.labels.job_name=%q AND resource.labels.location=%q AND labels."run.googleapis.com/execution_name"=%q AND timestamp>=%q`,
+		c.job, c.region, l.execution, l.since.UTC().Format(time.RFC3339Nano))
+	req := &logging.ListLogEntriesRequest{
+		ResourceNames: []string{"projects/" + c.project},
+		Filter:        filter,
+		OrderBy:       "timestamp asc",
+		PageSize:      1000,
+	}
+	err := c.entries.List(req).Pages(ctx, func(resp *logging.ListLogEntriesResponse) error {
+		for _, e := range resp.Entries {
+			ts, err := time.Parse(time.RFC3339Nano, e.Timestamp)
+			if err != nil {
+				continue
+			}
+			if ts.Before(l.since) || (ts.Equal(l.since) && l.seen[e.InsertId]) {
+				continue
+			}
+			if !ts.Equal(l.since) || l.seen == nil {
+				l.since, l.seen = ts, map[string]bool{}
+			}
+			l.seen[e.InsertId] = true
+			_, _ = io.WriteString(out, logEntryText(e)+"\n")
+		}
+		return nil
+	})
+	// Logs are best effort: the run's outcome doesn't depend on them.
+	if err != nil && !l.warned && ctx.Err() == nil {
+		logf(LevelWarn, "Warning: failed to read logs of Cloud Run execution %s: %v", l.execution, err)
+		l.warned = true
+	}
+}
+
+// logEntryText is an entry's text, or the "message" of a structured entry
+// if it has one, or else the structured payload as JSON.
+func logEntryText(e *logging.LogEntry) string {
+	if len(e.JsonPayload) == 0 {
+		return e.TextPayload
+	}
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(e.JsonPayload, &msg) == nil && msg.Message != "" {
+		return msg.Message
+	}
+	return string(e.JsonPayload)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const cloudWatchTimeout = 10 * time.Second
+
+// cloudWatchReporter publishes JobRunCount and JobDuration custom metrics to
+// CLOUDWATCH_NAMESPACE after each run. Credentials come from the standard
+// AWS chain (environment, shared config, instance or task role).
+type cloudWatchReporter struct {
+	client    *cloudwatch.Client
+	namespace string
+}
+
+func newCloudWatchReporter(namespace, region string, timeout time.Duration) (*cloudWatchReporter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cloudWatchTimeout)
+	defer cancel()
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(region),
+		awsconfig.WithHTTPClient(newHTTPClient(timeout)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS configuration: %w", err)
+	}
+	return &cloudWatchReporter{
+		client:    cloudwatch.NewFromConfig(awsCfg),
+		namespace: namespace,
+	}, nil
+}
+
+// report sends the metrics in the background so a slow or failing
+// CloudWatch call never delays the job.
+func (c *cloudWatchReporter) report(rec runRecord) {
+	status := rec.status()
+	now := time.Now()
+
+	input := &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String(c.namespace),
+		MetricData: []types.MetricDatum{
+			{
+				MetricName: aws.String("JobRunCount"),
+				Dimensions: []types.Dimension{{Name: aws.String("Status"), Value: aws.String(status)}},
+				Timestamp:  aws.Time(now),
+				Unit:       types.StandardUnitCount,
+				Value:      aws.Float64(1),
+			},
+			{
+				MetricName: aws.String("JobDuration"),
+				Timestamp:  aws.Time(now),
+				Unit:       types.StandardUnitMilliseconds,
+				Value:      aws.Float64(float64(rec.DurationMs)),
+			},
+		},
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cloudWatchTimeout)
+		defer cancel()
+		if _, err := c.client.PutMetricData(ctx, input); err != nil {
+			logf(LevelError, "Failed to publish CloudWatch metrics for run %s: %v", rec.RunID, err)
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// readCommandFile returns the command held in the CRON_CMD_FILE at path.
+// Surrounding whitespace, including the trailing newline editors add, is
+// dropped; everything else is passed on as CRON_CMD would be.
+func readCommandFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	command := strings.TrimSpace(string(b))
+	if command == "" {
+		return "", fmt.Errorf("'%s' is empty", path)
+	}
+	return command, nil
+}
+
+// commandFile re-reads CRON_CMD_FILE before every run so edits take effect
+// without restarting cronrunner. If the file can't be read or the new
+// command can't be resolved, the last good command is used instead.
+type commandFile struct {
+	path     string
+	validate func(command string) error
+	display  func(command string) string
+
+	mu   sync.Mutex
+	last string
+}
+
+func newCommandFile(path, command string, validate func(string) error, display func(string) string) *commandFile {
+	return &commandFile{path: path, validate: validate, display: display, last: command}
+}
+
+// command returns the command to run now.
+func (f *commandFile) command() string {
+	command, err := f.reload()
+	if err != nil {
+		logf(LevelWarn, "Warning: running the previous command: %v", err)
+	}
+	return command
+}
+
+// current returns the command of the last run or reload, without reading
+// the file.
+func (f *commandFile) current() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last
+}
+
+// reload reads the file again and returns its command, or the previous one
+// together with the reason the new one was not taken.
+func (f *commandFile) reload() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	command, err := readCommandFile(f.path)
+	if err != nil {
+		return f.last, fmt.Errorf("failed to re-read CRON_CMD_FILE: %v", err)
+	}
+	if command == f.last {
+		return command, nil
+	}
+	if f.validate != nil {
+		if err := f.validate(command); err != nil {
+			return f.last, fmt.Errorf("new command in CRON_CMD_FILE not usable: %v", err)
+		}
+	}
+	logf(LevelInfo, "CRON_CMD_FILE changed, new command: %s", f.display(command))
+	f.last = command
+	return command, nil
+}
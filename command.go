@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// commandArgs turns the decoded CRON_CMD into an argv. Without a shell the
+// command is split on whitespace and executed directly; with CRON_SHELL set
+// the whole string is passed to the shell ("<shell> -c", "cmd /c" or
+// "powershell -Command") so pipes, quoting and redirections behave as they
+// would in a terminal.
+func commandArgs(command, shell string) []string {
+	switch shellKind(shell) {
+	case "":
+		return strings.Fields(command)
+	case shellCmd:
+		return []string{shell, "/c", command}
+	case shellPowerShell:
+		return []string{shell, "-NoProfile", "-NonInteractive", "-Command", command}
+	}
+	return []string{shell, "-c", command}
+}
+
+// Shell families that don't take "-c", as returned by shellKind.
+const (
+	shellPOSIX      = "posix"
+	shellCmd        = "cmd"
+	shellPowerShell = "powershell"
+)
+
+// shellKind classifies CRON_SHELL by its executable name, so cmd.exe and
+// PowerShell get their own flags; anything else is assumed to accept -c.
+func shellKind(shell string) string {
+	if shell == "" {
+		return ""
+	}
+	name := strings.ToLower(filepath.Base(strings.ReplaceAll(shell, `\`, "/")))
+	switch strings.TrimSuffix(name, ".exe") {
+	case "cmd":
+		return shellCmd
+	case "powershell", "pwsh":
+		return shellPowerShell
+	}
+	return shellPOSIX
+}
+
+// jobName derives a short name for the job from the executable in command.
+func jobName(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
+// validateCommand checks that the executable for argv can be resolved, so a
+// typo in CRON_CMD or CRON_SHELL is reported at startup instead of on the
+// first scheduled run. With CRON_CHROOT it is looked up inside root.
+func validateCommand(argv []string, shell, root string) error {
+	if len(argv) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+	lookPath := exec.LookPath
+	if root != "" {
+		lookPath = func(file string) (string, error) { return chrootLookPath(root, file) }
+	}
+	if _, err := lookPath(argv[0]); err != nil {
+		if shell != "" {
+			return fmt.Errorf("CRON_SHELL '%s' not usable: %w", shell, err)
+		}
+		return fmt.Errorf("command '%s' not usable: %w", argv[0], err)
+	}
+	return nil
+}
+
+// Exit codes recorded when the command cannot be started, following the
+// shell convention.
+const (
+	exitNotExecutable = 126
+	exitNotFound      = 127
+)
+
+// startError is a failure to start the command at all, as opposed to the
+// command running and exiting non-zero.
+type startError struct {
+	name string
+	err  error
+}
+
+func (e *startError) Error() string {
+	if e.notFound() {
+		return fmt.Sprintf("command not found: %s", e.name)
+	}
+	return fmt.Sprintf("cannot start %s: %v", e.name, e.err)
+}
+
+func (e *startError) Unwrap() error { return e.err }
+
+func (e *startError) notFound() bool {
+	return errors.Is(e.err, exec.ErrNotFound) || errors.Is(e.err, fs.ErrNotExist)
+}
+
+func (e *startError) ExitCode() int {
+	if e.notFound() {
+		return exitNotFound
+	}
+	return exitNotExecutable
+}
+
+// ensureLogDir creates the parent directory of LOG_FILE if it is missing,
+// or, with CRON_LOG_CREATE_DIRS=false, only checks that it exists.
+func ensureLogDir(logFilePath string, create bool) error {
+	dir := filepath.Dir(logFilePath)
+	if !create {
+		info, err := os.Stat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("LOG_FILE directory '%s' does not exist and CRON_LOG_CREATE_DIRS is false", dir)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot access LOG_FILE directory '%s': %w", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("LOG_FILE directory '%s' is not a directory", dir)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create LOG_FILE directory '%s': %w", dir, err)
+	}
+	return nil
+}
+
+// openLogFile opens LOG_FILE for one run. If its directory was removed
+// since startup, it is created again unless CRON_LOG_CREATE_DIRS is false.
+// A file created here gets CRON_LOG_FILE_MODE and CRON_LOG_FILE_OWNER.
+func openLogFile(cfg *config) (*os.File, error) {
+	mode := cfg.logFileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	_, statErr := os.Stat(cfg.logFilePath)
+	created := errors.Is(statErr, fs.ErrNotExist)
+	f, err := os.OpenFile(cfg.logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, mode)
+	if err != nil && cfg.logCreateDirs && errors.Is(err, fs.ErrNotExist) {
+		if dirErr := ensureLogDir(cfg.logFilePath, true); dirErr != nil {
+			return nil, dirErr
+		}
+		logf(LevelWarn, "Warning: recreated the missing LOG_FILE directory '%s'", filepath.Dir(cfg.logFilePath))
+		f, err = os.OpenFile(cfg.logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, mode)
+	}
+	if err == nil && created {
+		setLogFilePerms(f, cfg)
+	}
+	return f, err
+}
+
+// setLogFilePerms applies CRON_LOG_FILE_MODE, which the umask may have
+// narrowed at creation, and CRON_LOG_FILE_OWNER. Failures are logged; the
+// run still writes to the file.
+func setLogFilePerms(f *os.File, cfg *config) {
+	if cfg.logFileMode != 0 {
+		if err := f.Chmod(cfg.logFileMode); err != nil {
+			logf(LevelWarn, "Warning: failed to set mode %04o on LOG_FILE '%s': %v", cfg.logFileMode, cfg.logFilePath, err)
+		}
+	}
+	if o := cfg.logFileOwner; o != nil {
+		if err := f.Chown(int(o.uid), int(o.gid)); err != nil {
+			logf(LevelWarn, "Warning: failed to change the owner of LOG_FILE '%s' to %s:%s: %v", cfg.logFilePath, o.user, o.group, err)
+		}
+	}
+}
+
+// logLogFilePerms logs the permissions LOG_FILE has or will be created
+// with. An existing file keeps its own; the settings only apply when the
+// file is created, which is worth a warning when they differ.
+func logLogFilePerms(cfg *config) {
+	if cfg.logFilePath == "" || (cfg.logFileMode == 0 && cfg.logFileOwner == nil) {
+		return
+	}
+	owner := "cronrunner's user"
+	if o := cfg.logFileOwner; o != nil {
+		owner = o.user + ":" + o.group
+	}
+	info, err := os.Stat(cfg.logFilePath)
+	if err != nil {
+		logf(LevelInfo, "LOG_FILE will be created with mode %04o, owned by %s", cfg.logFileMode, owner)
+		return
+	}
+	logf(LevelInfo, "LOG_FILE exists with mode %04o", info.Mode().Perm())
+	if cfg.logFileMode != 0 && info.Mode().Perm() != cfg.logFileMode {
+		logf(LevelWarn, "Warning: CRON_LOG_FILE_MODE=%04o only applies when LOG_FILE is created; '%s' keeps mode %04o", cfg.logFileMode, cfg.logFilePath, info.Mode().Perm())
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// defaultConditionTimeout bounds CRON_CONDITION_CMD unless
+// CRON_CONDITION_TIMEOUT_SEC says otherwise.
+const defaultConditionTimeout = 30 * time.Second
+
+// conditionMet runs CRON_CONDITION_CMD before a scheduled run and reports
+// whether the run should go ahead: only if the check exits 0 within
+// CRON_CONDITION_TIMEOUT_SEC and before tickDeadline. The check runs locally with the command's
+// environment, working directory and process settings; its output goes to
+// the console only.
+func (r *runner) conditionMet(tickDeadline time.Time) bool {
+	cfg := r.cfg
+	if len(cfg.conditionArgv) == 0 {
+		return true
+	}
+
+	// CRON_TOTAL_DEADLINE may end the check sooner.
+	deadline := time.Now().Add(cfg.conditionTimeout)
+	if !tickDeadline.IsZero() && tickDeadline.Before(deadline) {
+		deadline = tickDeadline
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	argv := cfg.conditionArgv
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = cfg.workDir
+	if cfg.chroot != "" {
+		cmd.Path, cmd.Err = chrootLookPath(cfg.chroot, argv[0])
+	}
+	redact := newRedactor(cfg.redactPatterns)
+	cmd.Stdout = redact.writer(consoleStdout)
+	cmd.Stderr = redact.writer(os.Stderr)
+	baseEnv := buildChildEnv(cfg.envAllowlist, cfg.envBlocklist)
+	if cfg.clearProxy {
+		baseEnv = withoutEnv(baseEnv, proxyVarNames)
+	}
+	cmd.Env = withEnv(withEnv(baseEnv, cfg.proxyEnv), cfg.caEnv)
+	cmd.SysProcAttr = sysProcAttr(cfg, argv)
+	wrapPrivileges(cfg, cmd)
+
+	start := time.Now()
+	err := cmd.Start()
+	if err == nil {
+		err = cmd.Wait()
+	}
+	redact.flush()
+
+	var ee *exec.ExitError
+	switch {
+	case err == nil:
+		return true
+	case ctx.Err() == context.DeadlineExceeded:
+		logf(LevelWarn, "Warning: CRON_CONDITION_CMD timed out after %v; skipping this run", time.Since(start).Round(time.Millisecond))
+	case errors.As(err, &ee) && ee.ExitCode() >= 0:
+		logf(LevelInfo, "Condition not met (CRON_CONDITION_CMD exited with %d); skipping this run", ee.ExitCode())
+	default:
+		logf(LevelError, "Failed to run CRON_CONDITION_CMD; skipping this run: %v", err)
+	}
+	return false
+}
@@ -0,0 +1,1186 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/robfig/cron/v3"
+)
+
+// config holds the settings resolved from the environment at startup.
+type config struct {
+	schedule     string
+	schedules    []string
+	scheduleFile string
+	command      string
+	cmdFile      string
+	jobName      string
+	argv         []string
+
+	// jobNameSet is true when CRON_JOB_NAME names the job instead of the
+	// command's executable; logJobName is CRON_LOG_JOB_NAME.
+	jobNameSet bool
+	logJobName bool
+
+	// conditionArgv is CRON_CONDITION_CMD, run before each scheduled run.
+	conditionArgv    []string
+	condition        string
+	conditionTimeout time.Duration
+	shell            string
+	workDir          string
+	chroot           string
+	tmpDir           bool
+	tmpDirBase       string
+
+	parser     cron.Parser
+	parserName string
+	// describeSched is CRON_EXPRESSION_HUMAN: log each schedule in words.
+	describeSched bool
+
+	// jitter is the CRON_JITTER_SEC window; in host mode every run is
+	// delayed by jitterOffset, derived from instanceID.
+	jitter       time.Duration
+	jitterMode   string
+	jitterOffset time.Duration
+	instanceID   string
+
+	// restartBackoff is RESTART_BACKOFF_STRATEGY and nextDelay its
+	// function; with no strategy nextDelay is nil and failed commands are
+	// restarted immediately.
+	restartBackoff     string
+	restartBackoffBase time.Duration
+	restartBackoffMax  time.Duration // 0 for no cap
+	nextDelay          backoffFunc
+
+	// retryExitCodes (CRON_RETRY_EXIT_CODES) and noRetryExitCodes
+	// (CRON_NO_RETRY_EXIT_CODES) narrow which exit codes RESTART_ON_FAIL
+	// restarts; at most one is set.
+	retryExitCodes   []int
+	noRetryExitCodes []int
+
+	// killAfter is the budget for a whole run, across restarts, from the
+	// setting named by killAfterVar (CRON_KILL_AFTER_SEC,
+	// CRON_TOTAL_TIMEOUT_MIN or CRON_KILL_AFTER_MIN); attemptTimeout bounds
+	// each attempt on its own.
+	killAfter       time.Duration
+	killAfterVar    string
+	totalDeadline   time.Duration
+	attemptTimeout  time.Duration
+	shutdownTimeout time.Duration
+	killGrace       time.Duration
+	warnAfter       time.Duration
+	warnWebhook     bool
+	restartOnFail   bool
+	allowConcurrent bool
+	overlapStrategy string
+	logFilePath     string
+	logCreateDirs   bool
+	logFsync        bool
+	logFileMode     os.FileMode // 0 unless CRON_LOG_FILE_MODE is set
+	logFileOwner    *runAs
+	logMaxRunBytes  int64
+	logCapConsole   bool
+	logSeparator    string
+	logLevel        int
+	redactPatterns  []*regexp.Regexp
+	redactCmdLog    string
+	logColor        string
+	syslog          bool
+	syslogNetwork   string
+	syslogAddr      string
+	syslogFacility  string
+	syslogTag       string
+	location        *time.Location
+	timezone        string
+	timezoneAlias   string
+
+	// logBufferSize is LOG_OUTPUT_BUFFER_SIZE, 0 to write LOG_FILE
+	// unbuffered; logFlushInterval is LOG_OUTPUT_FLUSH_INTERVAL_MS.
+	logBufferSize    int
+	logFlushInterval time.Duration
+
+	envAllowlist   []string
+	envBlocklist   []string
+	pidFilePath    string
+	exitCodePath   string
+	exitCodeFmt    string
+	exitCodeOutput bool
+	eventFile      string
+	stdinData      []byte
+	stdinSource    string
+	stdinFile      string
+	runAs          *runAs
+	groups         []string
+	groupIDs       []uint32
+	dropCaps       []int
+	dropCapNames   []string
+	noNewPrivs     bool
+	newNetns       bool
+	netnsPath      string
+
+	// seccompFilter is the compiled CRON_SECCOMP_PROFILE or
+	// CRON_SECCOMP_PRESET, encoded for the privilege helper.
+	seccompFilter string
+	seccompSource string
+	nice          *int
+	ioPriority    *ioPriority
+	memLimitMB    int
+	rlimits       []rlimit
+	umask         *int
+
+	allocatePTY bool
+	ptyCols     uint16
+	ptyRows     uint16
+	setsid      bool
+
+	forwardSignals  []os.Signal
+	forwardShutdown bool
+
+	maxConsecutiveFailures int
+	breakerAction          string
+	maxRuns                int
+
+	proxyEnv   map[string]string
+	clearProxy bool
+	httpProxy  string
+	caEnv      map[string]string
+
+	waitFor     []string
+	waitTimeout time.Duration
+
+	healthPort     string
+	healthAPIToken string
+	livenessMaxAge time.Duration
+	historyDBPath  string
+	historyMaxRows int
+
+	cloudWatchNamespace string
+	awsRegion           string
+	statsdAddr          string
+	statsdPrefix        string
+
+	pushgatewayURL      string
+	pushgatewayInstance string
+	pushgatewayTimeout  time.Duration
+
+	s3Bucket    string
+	s3KeyPrefix string
+	s3Compress  bool
+	s3Timeout   time.Duration
+
+	// notifyHTTPTimeout is the default timeout for outgoing HTTP calls;
+	// the per-caller settings below fall back to it.
+	notifyHTTPTimeout time.Duration
+	webhookURL        string
+	webhookTimeout    time.Duration
+	webhookTLS        *tls.Config
+
+	slackURL        string
+	slackChannel    string
+	slackUsername   string
+	slackTailLines  int
+	slackHistoryURL string
+
+	pagerDutyKey     string
+	pagerDutyResolve bool
+	pagerDutyURL     string
+
+	pingURL     string
+	pingFailURL string
+
+	natsURL           string
+	natsSubject       string
+	natsDurable       string
+	natsMaxConcurrent int
+
+	k8sJobMode           bool
+	k8sJobImage          string
+	k8sJobServiceAccount string
+	k8sJobNamespace      string
+	k8sJobKeep           bool
+
+	lambdaFunctionName string
+	lambdaTimeout      time.Duration
+
+	gcpProject  string
+	gcpRegion   string
+	cloudRunJob string
+
+	// sshHost is CRON_SSH_HOST as host:port; the command then runs there
+	// as sshUser, authenticated with sshKeyFile.
+	sshHost       string
+	sshUser       string
+	sshKeyFile    string
+	sshKnownHosts string
+	sshInsecure   bool
+
+	vaultSecretPath string
+	vaultLeaseRenew bool
+
+	otelEndpoint string
+}
+
+// remote reports whether runs execute outside cronrunner, as Kubernetes
+// Jobs, Lambda invocations, Cloud Run executions or over SSH, instead of as
+// local child processes.
+func (cfg *config) remote() bool {
+	return cfg.k8sJobMode || cfg.lambdaFunctionName != "" || cfg.cloudRunJob != "" || cfg.sshHost != ""
+}
+
+// loadConfig reads and validates the cronrunner environment variables.
+func loadConfig() (*config, error) {
+	cronExpr := os.Getenv("CRON_EXPRESSION")
+	hasSchedule := cronExpr != "" || strings.TrimSpace(os.Getenv("CRON_EXPRESSION_FILE")) != ""
+	appCmd := os.Getenv("CRON_CMD")
+
+	cfg := &config{
+		logFilePath:     os.Getenv("LOG_FILE"),
+		describeSched:   parseBool(os.Getenv("CRON_EXPRESSION_HUMAN")),
+		logFsync:        parseBool(os.Getenv("CRON_LOG_FSYNC")),
+		restartOnFail:   parseBool(os.Getenv("RESTART_ON_FAIL")),
+		allowConcurrent: parseBool(os.Getenv("ALLOW_CONCURRENT")),
+		envAllowlist:    splitList(os.Getenv("CRON_ENV_ALLOWLIST")),
+		envBlocklist:    splitList(os.Getenv("CRON_ENV_BLOCKLIST")),
+		pidFilePath:     os.Getenv("CRON_PID_FILE"),
+		shell:           strings.TrimSpace(os.Getenv("CRON_SHELL")),
+		cmdFile:         strings.TrimSpace(os.Getenv("CRON_CMD_FILE")),
+	}
+
+	var err error
+	if cfg.logLevel, err = parseLogLevel(os.Getenv("LOG_LEVEL")); err != nil {
+		return nil, err
+	}
+	// Applied right away, so the debug messages from loading the rest of
+	// the configuration are shown.
+	logLevel = cfg.logLevel
+	cfg.logSeparator, err = parseSeparatorFormat(strings.ToLower(strings.TrimSpace(os.Getenv("LOG_SEPARATOR_FORMAT"))))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.logColor = strings.ToLower(strings.TrimSpace(os.Getenv("CRON_LOG_COLOR")))
+	switch cfg.logColor {
+	case "":
+		cfg.logColor = logColorAuto
+	case logColorAuto, logColorAlways, logColorNever:
+	default:
+		return nil, fmt.Errorf("Invalid CRON_LOG_COLOR '%s': must be auto, always or never", cfg.logColor)
+	}
+	cfg.syslogAddr = strings.TrimSpace(os.Getenv("CRON_SYSLOG_ADDR"))
+	if cfg.syslog = parseBool(os.Getenv("CRON_SYSLOG")) || cfg.syslogAddr != ""; cfg.syslog {
+		if strings.TrimSpace(os.Getenv("CRONRUNNER_LOG_FILE")) != "" {
+			return nil, fmt.Errorf("CRON_SYSLOG and CRONRUNNER_LOG_FILE can't be combined")
+		}
+		cfg.syslogNetwork, cfg.syslogAddr, err = parseSyslogAddr(cfg.syslogAddr)
+		if err != nil {
+			return nil, err
+		}
+		cfg.syslogFacility = strings.ToLower(strings.TrimSpace(os.Getenv("CRON_SYSLOG_FACILITY")))
+		if cfg.syslogFacility == "" {
+			cfg.syslogFacility = "cron"
+		}
+		if !validSyslogFacility(cfg.syslogFacility) {
+			return nil, fmt.Errorf("Invalid CRON_SYSLOG_FACILITY '%s': expected a name such as daemon, cron or local0", cfg.syslogFacility)
+		}
+		cfg.syslogTag = strings.TrimSpace(os.Getenv("CRON_SYSLOG_TAG"))
+		if cfg.syslogTag == "" {
+			cfg.syslogTag = "cronrunner"
+		}
+	}
+
+	// NATS queue mode replaces the schedule; CRON_CMD becomes the default
+	// for messages that don't carry their own command.
+	cfg.natsURL = strings.TrimSpace(os.Getenv("NATS_URL"))
+	cfg.natsSubject = strings.TrimSpace(os.Getenv("NATS_SUBJECT"))
+	queueMode := !hasSchedule && cfg.natsURL != "" && cfg.natsSubject != ""
+	if !queueMode {
+		cfg.natsURL, cfg.natsSubject = "", ""
+	}
+
+	if !hasSchedule && !queueMode {
+		return nil, fmt.Errorf("CRON_EXPRESSION environment variable (or --cron, or CRON_EXPRESSION_FILE) is required")
+	}
+
+	if appCmd != "" && cfg.cmdFile != "" {
+		return nil, fmt.Errorf("CRON_CMD and CRON_CMD_FILE cannot both be set")
+	}
+	if appCmd == "" && cfg.cmdFile == "" && !queueMode {
+		return nil, fmt.Errorf("CRON_CMD environment variable (or --cmd, or CRON_CMD_FILE) is required")
+	}
+
+	if queueMode {
+		cfg.natsDurable = strings.TrimSpace(os.Getenv("NATS_DURABLE"))
+		if cfg.natsDurable == "" {
+			cfg.natsDurable = "cronrunner"
+		}
+		cfg.natsMaxConcurrent = 1
+		if v := os.Getenv("NATS_MAX_CONCURRENT"); v != "" {
+			cfg.natsMaxConcurrent, err = strconv.Atoi(v)
+			if err != nil || cfg.natsMaxConcurrent < 1 {
+				return nil, fmt.Errorf("Invalid NATS_MAX_CONCURRENT value '%s'", v)
+			}
+		}
+	}
+
+	if cfg.killAfter, cfg.killAfterVar, err = loadKillAfter(); err != nil {
+		return nil, err
+	}
+
+	if cfg.attemptTimeout, err = durationEnv("CRON_ATTEMPT_TIMEOUT_MIN", 0); err != nil {
+		return nil, err
+	}
+
+	if err := loadRestartBackoff(cfg); err != nil {
+		return nil, err
+	}
+	if err := loadRetryExitCodes(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.totalDeadline, err = durationEnv("CRON_TOTAL_DEADLINE", 0); err != nil {
+		return nil, err
+	}
+
+	if v := os.Getenv("CRON_MAX_CONSECUTIVE_FAILURES"); v != "" {
+		cfg.maxConsecutiveFailures, err = strconv.Atoi(v)
+		if err != nil || cfg.maxConsecutiveFailures < 0 {
+			return nil, fmt.Errorf("Invalid CRON_MAX_CONSECUTIVE_FAILURES value '%s'", v)
+		}
+		cfg.breakerAction = strings.ToLower(strings.TrimSpace(os.Getenv("CRON_BREAKER_ACTION")))
+		switch cfg.breakerAction {
+		case "":
+			cfg.breakerAction = breakerPause
+		case breakerPause, breakerExit:
+		default:
+			return nil, fmt.Errorf("Invalid CRON_BREAKER_ACTION value '%s' (expected %s or %s)", cfg.breakerAction, breakerPause, breakerExit)
+		}
+	}
+
+	if v := os.Getenv("CRON_MAX_RUNS"); v != "" {
+		cfg.maxRuns, err = strconv.Atoi(v)
+		if err != nil || cfg.maxRuns < 0 {
+			return nil, fmt.Errorf("Invalid CRON_MAX_RUNS value '%s'", v)
+		}
+	}
+
+	if cfg.shutdownTimeout, err = durationEnv("CRON_SHUTDOWN_TIMEOUT_SEC", 0); err != nil {
+		return nil, err
+	}
+	if termSignal == nil && os.Getenv("CRON_KILL_GRACE_SEC") != "" {
+		return nil, fmt.Errorf("CRON_KILL_GRACE_SEC is not supported on Windows")
+	}
+	if cfg.killGrace, err = durationEnv("CRON_KILL_GRACE_SEC", 0); err != nil {
+		return nil, err
+	}
+	if cfg.warnAfter, err = durationEnv("CRON_WARN_AFTER", 0); err != nil {
+		return nil, err
+	}
+	if cfg.warnAfter > 0 && cfg.killAfter > 0 && cfg.warnAfter >= cfg.killAfter {
+		return nil, fmt.Errorf("Invalid CRON_WARN_AFTER value '%s': must be shorter than the %v timeout set by %s", os.Getenv("CRON_WARN_AFTER"), cfg.killAfter, cfg.killAfterVar)
+	}
+
+	if cfg.proxyEnv, err = loadProxyEnv(); err != nil {
+		return nil, err
+	}
+	if cfg.httpProxy = strings.TrimSpace(os.Getenv("CRONRUNNER_HTTP_PROXY")); cfg.httpProxy != "" {
+		u, err := url.Parse(cfg.httpProxy)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			return nil, fmt.Errorf("Invalid CRONRUNNER_HTTP_PROXY '%s': expected an http://, https:// or socks5:// URL", redactURLSecret(cfg.httpProxy))
+		}
+	}
+	cfg.clearProxy = parseBool(os.Getenv("CRON_CLEAR_PROXY"))
+
+	if len(cfg.envAllowlist) > 0 && len(cfg.envBlocklist) > 0 {
+		return nil, fmt.Errorf("CRON_ENV_ALLOWLIST and CRON_ENV_BLOCKLIST cannot both be set")
+	}
+	for _, p := range append(cfg.envAllowlist, cfg.envBlocklist...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("Invalid environment pattern '%s': %v", p, err)
+		}
+	}
+
+	if err := loadSchedule(cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.cmdFile != "" {
+		cfg.command, err = readCommandFile(cfg.cmdFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CRON_CMD_FILE: %v", err)
+		}
+	} else {
+		cfg.command, err = decodeBase64Any(appCmd)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode CRON_CMD: %v", err)
+		}
+	}
+	cfg.argv = commandArgs(cfg.command, cfg.shell)
+	cfg.jobName = jobName(cfg.command)
+	if v := strings.TrimSpace(os.Getenv("CRON_JOB_NAME")); v != "" {
+		cfg.jobName, cfg.jobNameSet = v, true
+	}
+	cfg.logJobName = parseBool(os.Getenv("CRON_LOG_JOB_NAME"))
+
+	if parseBool(os.Getenv("K8S_JOB_MODE")) {
+		cfg.k8sJobMode = true
+		cfg.k8sJobImage = strings.TrimSpace(os.Getenv("K8S_JOB_IMAGE"))
+		if cfg.k8sJobImage == "" {
+			return nil, fmt.Errorf("K8S_JOB_IMAGE is required when K8S_JOB_MODE is enabled")
+		}
+		cfg.k8sJobServiceAccount = strings.TrimSpace(os.Getenv("K8S_JOB_SERVICE_ACCOUNT"))
+		cfg.k8sJobNamespace = strings.TrimSpace(os.Getenv("K8S_JOB_NAMESPACE"))
+		cfg.k8sJobKeep = parseBool(os.Getenv("K8S_JOB_KEEP"))
+	}
+
+	cfg.lambdaFunctionName = strings.TrimSpace(os.Getenv("LAMBDA_FUNCTION_NAME"))
+	if cfg.lambdaFunctionName != "" {
+		if cfg.k8sJobMode {
+			return nil, fmt.Errorf("LAMBDA_FUNCTION_NAME and K8S_JOB_MODE cannot be used together")
+		}
+		if cfg.lambdaTimeout, err = durationEnv("LAMBDA_TIMEOUT_SEC", 0); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.cloudRunJob = strings.TrimSpace(os.Getenv("GCP_CLOUD_RUN_JOB"))
+	if cfg.cloudRunJob != "" {
+		if cfg.k8sJobMode || cfg.lambdaFunctionName != "" {
+			return nil, fmt.Errorf("GCP_CLOUD_RUN_JOB cannot be used with K8S_JOB_MODE or LAMBDA_FUNCTION_NAME")
+		}
+		cfg.gcpProject = strings.TrimSpace(os.Getenv("GCP_PROJECT"))
+		cfg.gcpRegion = strings.TrimSpace(os.Getenv("GCP_REGION"))
+		if cfg.gcpProject == "" || cfg.gcpRegion == "" {
+			return nil, fmt.Errorf("GCP_CLOUD_RUN_JOB requires GCP_PROJECT and GCP_REGION")
+		}
+	}
+
+	if host := strings.TrimSpace(os.Getenv("CRON_SSH_HOST")); host != "" {
+		if cfg.remote() {
+			return nil, fmt.Errorf("CRON_SSH_HOST cannot be used with K8S_JOB_MODE, LAMBDA_FUNCTION_NAME or GCP_CLOUD_RUN_JOB")
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), "22")
+		}
+		cfg.sshHost = host
+		cfg.sshUser = strings.TrimSpace(os.Getenv("CRON_SSH_USER"))
+		cfg.sshKeyFile = strings.TrimSpace(os.Getenv("CRON_SSH_KEY_FILE"))
+		if cfg.sshUser == "" || cfg.sshKeyFile == "" {
+			return nil, fmt.Errorf("CRON_SSH_HOST requires CRON_SSH_USER and CRON_SSH_KEY_FILE")
+		}
+		cfg.sshInsecure = parseBool(os.Getenv("CRON_SSH_INSECURE"))
+		cfg.sshKnownHosts = strings.TrimSpace(os.Getenv("CRON_SSH_KNOWN_HOSTS"))
+		if cfg.sshInsecure && cfg.sshKnownHosts != "" {
+			return nil, fmt.Errorf("CRON_SSH_KNOWN_HOSTS and CRON_SSH_INSECURE cannot both be set")
+		}
+		if !cfg.sshInsecure && cfg.sshKnownHosts == "" {
+			cfg.sshKnownHosts = defaultKnownHosts()
+		}
+	}
+
+	if cfg.chroot = strings.TrimSpace(os.Getenv("CRON_CHROOT")); cfg.chroot != "" {
+		if !chrootSupported {
+			return nil, fmt.Errorf("CRON_CHROOT is only supported on Linux")
+		}
+		if err := checkChroot(cfg.chroot); err != nil {
+			return nil, fmt.Errorf("Invalid CRON_CHROOT: %v", err)
+		}
+	}
+	// CRON_WORKDIR is a path inside CRON_CHROOT when both are set.
+	if cfg.workDir = strings.TrimSpace(os.Getenv("CRON_WORKDIR")); cfg.workDir != "" {
+		dir := cfg.workDir
+		if cfg.chroot != "" {
+			if !path.IsAbs(dir) {
+				return nil, fmt.Errorf("Invalid CRON_WORKDIR '%s': must be an absolute path inside CRON_CHROOT", dir)
+			}
+			dir = filepath.Join(cfg.chroot, dir)
+		}
+		if info, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("Invalid CRON_WORKDIR: %v", err)
+		} else if !info.IsDir() {
+			return nil, fmt.Errorf("Invalid CRON_WORKDIR '%s': not a directory", cfg.workDir)
+		}
+	}
+	// CRON_TMPDIR_BASE is also a path inside CRON_CHROOT.
+	if cfg.tmpDir = parseBool(os.Getenv("CRON_TMPDIR")); cfg.tmpDir {
+		cfg.tmpDirBase = strings.TrimSpace(os.Getenv("CRON_TMPDIR_BASE"))
+		switch {
+		case cfg.tmpDirBase == "" && cfg.chroot != "":
+			cfg.tmpDirBase = "/tmp"
+		case cfg.tmpDirBase == "":
+			cfg.tmpDirBase = os.TempDir()
+		case cfg.chroot != "" && !path.IsAbs(cfg.tmpDirBase):
+			return nil, fmt.Errorf("Invalid CRON_TMPDIR_BASE '%s': must be an absolute path inside CRON_CHROOT", cfg.tmpDirBase)
+		}
+		if err := checkTmpDirBase(cfg); err != nil {
+			return nil, fmt.Errorf("Invalid CRON_TMPDIR_BASE: %v", err)
+		}
+	} else if os.Getenv("CRON_TMPDIR_BASE") != "" {
+		return nil, fmt.Errorf("CRON_TMPDIR_BASE requires CRON_TMPDIR")
+	}
+
+	if cfg.caEnv, err = loadCAEnv(cfg.chroot); err != nil {
+		return nil, err
+	}
+
+	cfg.overlapStrategy = strings.ToLower(strings.TrimSpace(os.Getenv("OVERLAP_STRATEGY")))
+	switch cfg.overlapStrategy {
+	case "":
+		cfg.overlapStrategy = overlapDrop
+	case overlapDrop, overlapQueue, overlapReplace:
+		if cfg.allowConcurrent && cfg.overlapStrategy != overlapDrop {
+			return nil, fmt.Errorf("OVERLAP_STRATEGY=%s can't be combined with ALLOW_CONCURRENT, which starts every run regardless", cfg.overlapStrategy)
+		}
+	default:
+		return nil, fmt.Errorf("Invalid OVERLAP_STRATEGY value '%s' (expected %s, %s or %s)", cfg.overlapStrategy, overlapDrop, overlapQueue, overlapReplace)
+	}
+	// Only local commands can be stopped.
+	if cfg.overlapStrategy == overlapReplace && cfg.remote() {
+		return nil, fmt.Errorf("OVERLAP_STRATEGY=replace is not supported with K8S_JOB_MODE, LAMBDA_FUNCTION_NAME, GCP_CLOUD_RUN_JOB or CRON_SSH_HOST")
+	}
+
+	// In the remote modes the command doesn't run locally, so it can't be
+	// resolved here.
+	if cfg.command != "" && !cfg.remote() {
+		if err := validateCommand(cfg.argv, cfg.shell, cfg.chroot); err != nil {
+			return nil, fmt.Errorf("Invalid CRON_CMD: %v", err)
+		}
+	}
+	if v := os.Getenv("CRON_CONDITION_CMD"); v != "" {
+		if cfg.condition, err = decodeBase64Any(v); err != nil {
+			return nil, fmt.Errorf("Failed to decode CRON_CONDITION_CMD: %v", err)
+		}
+		cfg.conditionArgv = commandArgs(cfg.condition, cfg.shell)
+		if err := validateCommand(cfg.conditionArgv, cfg.shell, cfg.chroot); err != nil {
+			return nil, fmt.Errorf("Invalid CRON_CONDITION_CMD: %v", err)
+		}
+		if cfg.conditionTimeout, err = timeoutEnv("CRON_CONDITION_TIMEOUT_SEC", defaultConditionTimeout); err != nil {
+			return nil, err
+		}
+	}
+	if v := os.Getenv("CRON_LOG_MAX_RUN_BYTES"); v != "" {
+		cfg.logMaxRunBytes, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || cfg.logMaxRunBytes < 0 {
+			return nil, fmt.Errorf("Invalid CRON_LOG_MAX_RUN_BYTES value '%s'", v)
+		}
+		cfg.logCapConsole = parseBool(os.Getenv("CRON_LOG_CAP_CONSOLE"))
+	}
+	if cfg.redactPatterns, err = parseRedactPatterns(os.Getenv("CRON_REDACT_PATTERNS")); err != nil {
+		return nil, fmt.Errorf("Invalid CRON_REDACT_PATTERNS: %v", err)
+	}
+	cfg.redactCmdLog = strings.ToLower(strings.TrimSpace(os.Getenv("CRON_REDACT_CMD_LOG")))
+	switch cfg.redactCmdLog {
+	case "", redactCmdName:
+	case redactCmdPatterns:
+		if len(cfg.redactPatterns) == 0 {
+			return nil, fmt.Errorf("CRON_REDACT_CMD_LOG=patterns requires CRON_REDACT_PATTERNS")
+		}
+	default:
+		return nil, fmt.Errorf("Invalid CRON_REDACT_CMD_LOG '%s': must be name or patterns", cfg.redactCmdLog)
+	}
+	if cfg.exitCodePath = strings.TrimSpace(os.Getenv("CRON_EXITCODE_FILE")); cfg.exitCodePath != "" {
+		cfg.exitCodeFmt = strings.ToLower(strings.TrimSpace(os.Getenv("CRON_EXITCODE_FORMAT")))
+		switch cfg.exitCodeFmt {
+		case "":
+			cfg.exitCodeFmt = exitCodeFormatPlain
+		case exitCodeFormatPlain, exitCodeFormatJSON:
+		default:
+			return nil, fmt.Errorf("Invalid CRON_EXITCODE_FORMAT '%s': must be plain or json", cfg.exitCodeFmt)
+		}
+	}
+	cfg.exitCodeOutput = parseBool(os.Getenv("CRONRUNNER_EXIT_CODE_OUTPUT"))
+	cfg.eventFile = strings.TrimSpace(os.Getenv("CRON_EVENT_FILE"))
+	cfg.logCreateDirs = true
+	if v := strings.TrimSpace(os.Getenv("CRON_LOG_CREATE_DIRS")); v != "" {
+		cfg.logCreateDirs = parseBool(v)
+	}
+	if v := strings.TrimSpace(os.Getenv("CRON_LOG_FILE_MODE")); v != "" {
+		if cfg.logFileMode, err = parseFileMode("CRON_LOG_FILE_MODE", v); err != nil {
+			return nil, err
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("CRON_LOG_FILE_OWNER")); v != "" {
+		if !runAsSupported {
+			return nil, fmt.Errorf("CRON_LOG_FILE_OWNER is not supported on Windows")
+		}
+		userName, groupName, _ := strings.Cut(v, ":")
+		if cfg.logFileOwner, err = lookupIdentity(userName, groupName); err != nil {
+			return nil, fmt.Errorf("Invalid CRON_LOG_FILE_OWNER: %v", err)
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("LOG_OUTPUT_BUFFER_SIZE")); v != "" {
+		if cfg.logBufferSize, err = strconv.Atoi(v); err != nil || cfg.logBufferSize < 0 {
+			return nil, fmt.Errorf("Invalid LOG_OUTPUT_BUFFER_SIZE value '%s'", v)
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("LOG_OUTPUT_FLUSH_INTERVAL_MS")); v != "" {
+		if cfg.logBufferSize == 0 {
+			return nil, fmt.Errorf("LOG_OUTPUT_FLUSH_INTERVAL_MS requires LOG_OUTPUT_BUFFER_SIZE")
+		}
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("Invalid LOG_OUTPUT_FLUSH_INTERVAL_MS value '%s'", v)
+		}
+		cfg.logFlushInterval = time.Duration(ms) * time.Millisecond
+	} else if cfg.logBufferSize > 0 {
+		cfg.logFlushInterval = defaultLogFlushInterval
+	}
+	if cfg.logFilePath != "" {
+		if err := ensureLogDir(cfg.logFilePath, cfg.logCreateDirs); err != nil {
+			return nil, fmt.Errorf("Invalid LOG_FILE: %v", err)
+		}
+	}
+
+	if !runAsSupported && (os.Getenv("CRON_RUN_AS_USER") != "" || os.Getenv("CRON_SUPPLEMENTARY_GROUPS") != "") {
+		return nil, fmt.Errorf("CRON_RUN_AS_USER and CRON_SUPPLEMENTARY_GROUPS are not supported on Windows")
+	}
+	if name := strings.TrimSpace(os.Getenv("CRON_RUN_AS_USER")); name != "" {
+		cfg.runAs, err = resolveRunAs(name, strings.TrimSpace(os.Getenv("CRON_RUN_AS_GROUP")))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CRON_RUN_AS_USER: %v", err)
+		}
+	} else if os.Getenv("CRON_RUN_AS_GROUP") != "" {
+		return nil, fmt.Errorf("CRON_RUN_AS_GROUP requires CRON_RUN_AS_USER")
+	}
+	if cfg.groups = splitList(os.Getenv("CRON_SUPPLEMENTARY_GROUPS")); len(cfg.groups) > 0 {
+		cfg.groupIDs, err = resolveGroups(cfg.groups)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CRON_SUPPLEMENTARY_GROUPS: %v", err)
+		}
+	}
+
+	if cfg.dropCapNames = splitList(os.Getenv("CRON_DROP_CAPS")); len(cfg.dropCapNames) > 0 {
+		cfg.dropCaps, err = parseCapabilities(cfg.dropCapNames)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CRON_DROP_CAPS: %v", err)
+		}
+	}
+	if cfg.noNewPrivs = parseBool(os.Getenv("CRON_NO_NEW_PRIVS")); cfg.noNewPrivs && !capsSupported {
+		return nil, fmt.Errorf("CRON_NO_NEW_PRIVS is only supported on Linux")
+	}
+
+	cfg.newNetns = parseBool(os.Getenv("CRON_NEW_NETNS"))
+	if v := strings.TrimSpace(os.Getenv("CRON_NETNS_PATH")); v != "" {
+		if cfg.newNetns {
+			return nil, fmt.Errorf("CRON_NEW_NETNS and CRON_NETNS_PATH cannot both be set")
+		}
+		if !netnsSupported {
+			return nil, fmt.Errorf("CRON_NETNS_PATH is only supported on Linux")
+		}
+		cfg.netnsPath, err = resolveNetns(v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CRON_NETNS_PATH: %v", err)
+		}
+	}
+	if cfg.newNetns {
+		if !netnsSupported {
+			return nil, fmt.Errorf("CRON_NEW_NETNS is only supported on Linux")
+		}
+		if err := checkNewNetns(); err != nil {
+			return nil, fmt.Errorf("Invalid CRON_NEW_NETNS: %v", err)
+		}
+	}
+
+	seccompProfile := strings.TrimSpace(os.Getenv("CRON_SECCOMP_PROFILE"))
+	seccompPreset := strings.ToLower(strings.TrimSpace(os.Getenv("CRON_SECCOMP_PRESET")))
+	if seccompProfile != "" || seccompPreset != "" {
+		name := "CRON_SECCOMP_PROFILE"
+		cfg.seccompSource = seccompProfile
+		if seccompProfile == "" {
+			name, cfg.seccompSource = "CRON_SECCOMP_PRESET", "preset "+seccompPreset
+		}
+		switch {
+		case seccompProfile != "" && seccompPreset != "":
+			return nil, fmt.Errorf("CRON_SECCOMP_PROFILE and CRON_SECCOMP_PRESET cannot both be set")
+		case !seccompSupported:
+			return nil, fmt.Errorf("%s is only supported on Linux (amd64 and arm64)", name)
+		}
+		cfg.seccompFilter, err = loadSeccomp(cfg, seccompProfile, seccompPreset)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s: %v", name, err)
+		}
+	}
+
+	if v := strings.TrimSpace(os.Getenv("CRON_NICE")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < -20 || n > 19 {
+			return nil, fmt.Errorf("Invalid CRON_NICE value '%s': must be an integer from -20 to 19", v)
+		}
+		if !niceSupported {
+			return nil, fmt.Errorf("CRON_NICE is not supported on Windows")
+		}
+		cfg.nice = &n
+	}
+
+	if v := strings.TrimSpace(os.Getenv("CRON_IONICE_CLASS")); v != "" {
+		class, err := strconv.Atoi(v)
+		if err != nil || class < ioClassNone || class > ioClassIdle {
+			return nil, fmt.Errorf("Invalid CRON_IONICE_CLASS value '%s': must be 0 (none), 1 (realtime), 2 (best-effort) or 3 (idle)", v)
+		}
+		level := 4
+		if lv := strings.TrimSpace(os.Getenv("CRON_IONICE_LEVEL")); lv != "" {
+			level, err = strconv.Atoi(lv)
+			if err != nil || level < 0 || level > 7 {
+				return nil, fmt.Errorf("Invalid CRON_IONICE_LEVEL value '%s': must be an integer from 0 to 7", lv)
+			}
+		}
+		if !ioniceSupported {
+			return nil, fmt.Errorf("CRON_IONICE_CLASS is only supported on Linux")
+		}
+		cfg.ioPriority = &ioPriority{class: class, level: level}
+	} else if os.Getenv("CRON_IONICE_LEVEL") != "" {
+		return nil, fmt.Errorf("CRON_IONICE_LEVEL requires CRON_IONICE_CLASS")
+	}
+
+	if v := strings.TrimSpace(os.Getenv("CRON_MEM_LIMIT_MB")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("Invalid CRON_MEM_LIMIT_MB value '%s': must be a positive integer", v)
+		}
+		if !memLimitSupported {
+			return nil, fmt.Errorf("CRON_MEM_LIMIT_MB is only supported on Linux")
+		}
+		cfg.memLimitMB = n
+	}
+
+	cfg.rlimits, err = loadRlimits()
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.rlimits) > 0 && !rlimitSupported {
+		return nil, fmt.Errorf("CRON_RLIMIT_* settings are only supported on Linux")
+	}
+	if cfg.memLimitMB > 0 && os.Getenv("CRON_RLIMIT_AS") != "" {
+		return nil, fmt.Errorf("CRON_MEM_LIMIT_MB and CRON_RLIMIT_AS both set the address-space limit; use only one")
+	}
+
+	if v := strings.TrimSpace(os.Getenv("CRON_UMASK")); v != "" {
+		n, err := strconv.ParseUint(v, 8, 32)
+		if err != nil || n > 0777 {
+			return nil, fmt.Errorf("Invalid CRON_UMASK value '%s': must be an octal value such as 0022", v)
+		}
+		if !umaskSupported {
+			return nil, fmt.Errorf("CRON_UMASK is only supported on Linux and macOS")
+		}
+		mask := int(n)
+		cfg.umask = &mask
+	}
+
+	if parseBool(os.Getenv("CRON_ALLOCATE_PTY")) {
+		if !ptySupported {
+			return nil, fmt.Errorf("CRON_ALLOCATE_PTY is only supported on Linux and macOS")
+		}
+		if cfg.remote() {
+			return nil, fmt.Errorf("CRON_ALLOCATE_PTY cannot be used with K8S_JOB_MODE, LAMBDA_FUNCTION_NAME, GCP_CLOUD_RUN_JOB or CRON_SSH_HOST")
+		}
+		cfg.allocatePTY = true
+		if cfg.ptyCols, err = ptySizeEnv("CRON_PTY_COLS", 80); err != nil {
+			return nil, err
+		}
+		if cfg.ptyRows, err = ptySizeEnv("CRON_PTY_ROWS", 24); err != nil {
+			return nil, err
+		}
+	}
+
+	if parseBool(os.Getenv("CRON_SETSID")) {
+		if !runAsSupported {
+			return nil, fmt.Errorf("CRON_SETSID is not supported on Windows")
+		}
+		if cfg.remote() {
+			return nil, fmt.Errorf("CRON_SETSID cannot be used with K8S_JOB_MODE, LAMBDA_FUNCTION_NAME, GCP_CLOUD_RUN_JOB or CRON_SSH_HOST")
+		}
+		cfg.setsid = true
+	}
+
+	// CRON_STDIN_DATA is base64-encoded, for binary or multi-line input; it
+	// takes precedence over the plain CRON_STDIN. Unlike CRON_CMD it is
+	// never taken as plain text, which binary data couldn't be told apart
+	// from.
+	if v := os.Getenv("CRON_STDIN_DATA"); v != "" {
+		data, err := decodeBase64Data(v)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode CRON_STDIN_DATA: %v", err)
+		}
+		cfg.stdinData = data
+		cfg.stdinSource = "CRON_STDIN_DATA"
+	} else if v := os.Getenv("CRON_STDIN"); v != "" {
+		cfg.stdinData = []byte(v)
+		cfg.stdinSource = "CRON_STDIN"
+	}
+	cfg.stdinFile = os.Getenv("CRON_STDIN_FILE")
+
+	cfg.waitFor = splitList(os.Getenv("CRON_WAIT_FOR"))
+	for _, addr := range cfg.waitFor {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("Invalid CRON_WAIT_FOR address '%s': %v", addr, err)
+		}
+	}
+	if cfg.waitTimeout, err = durationEnv("CRON_WAIT_TIMEOUT_SEC", 0); err != nil {
+		return nil, err
+	}
+
+	cfg.healthPort = strings.TrimSpace(os.Getenv("HEALTH_PORT"))
+	cfg.healthAPIToken = os.Getenv("HEALTH_API_TOKEN")
+	if cfg.livenessMaxAge, err = durationEnv("LIVENESS_MAX_AGE_SEC", 0); err != nil {
+		return nil, err
+	}
+	cfg.historyDBPath = os.Getenv("HISTORY_DB_PATH")
+	if v := os.Getenv("HISTORY_MAX_ROWS"); v != "" {
+		cfg.historyMaxRows, err = strconv.Atoi(v)
+		if err != nil || cfg.historyMaxRows < 0 {
+			return nil, fmt.Errorf("Invalid HISTORY_MAX_ROWS value '%s'", v)
+		}
+	}
+
+	// CloudWatch metrics are only enabled when both settings are present.
+	if ns, region := os.Getenv("CLOUDWATCH_NAMESPACE"), os.Getenv("AWS_REGION"); ns != "" && region != "" {
+		cfg.cloudWatchNamespace = ns
+		cfg.awsRegion = region
+	}
+
+	cfg.notifyHTTPTimeout, err = timeoutEnv("NOTIFY_HTTP_TIMEOUT_SEC", defaultHTTPTimeout)
+	if err != nil {
+		return nil, err
+	}
+	cfg.webhookURL = strings.TrimSpace(os.Getenv("NOTIFY_WEBHOOK_URL"))
+	cfg.webhookTimeout, err = timeoutEnv("NOTIFY_WEBHOOK_TIMEOUT_SEC", cfg.notifyHTTPTimeout)
+	if err != nil {
+		return nil, err
+	}
+	cfg.webhookTLS, err = buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.warnWebhook = parseBool(os.Getenv("CRON_WARN_WEBHOOK")); cfg.warnWebhook {
+		if cfg.webhookURL == "" {
+			return nil, fmt.Errorf("CRON_WARN_WEBHOOK requires NOTIFY_WEBHOOK_URL")
+		}
+		if cfg.warnAfter == 0 {
+			return nil, fmt.Errorf("CRON_WARN_WEBHOOK requires CRON_WARN_AFTER")
+		}
+	}
+
+	cfg.slackURL = strings.TrimSpace(os.Getenv("SLACK_WEBHOOK_URL"))
+	if cfg.slackURL != "" {
+		cfg.slackChannel = strings.TrimSpace(os.Getenv("SLACK_CHANNEL"))
+		cfg.slackUsername = strings.TrimSpace(os.Getenv("SLACK_USERNAME"))
+		cfg.slackHistoryURL = strings.TrimSpace(os.Getenv("SLACK_HISTORY_URL"))
+		cfg.slackTailLines = defaultSlackTailLines
+		if v := os.Getenv("SLACK_TAIL_LINES"); v != "" {
+			cfg.slackTailLines, err = strconv.Atoi(v)
+			if err != nil || cfg.slackTailLines < 0 {
+				return nil, fmt.Errorf("Invalid SLACK_TAIL_LINES value '%s'", v)
+			}
+		}
+	}
+
+	cfg.pagerDutyKey = strings.TrimSpace(os.Getenv("PAGERDUTY_ROUTING_KEY"))
+	if cfg.pagerDutyKey != "" {
+		cfg.pagerDutyResolve = parseBool(os.Getenv("PAGERDUTY_RESOLVE"))
+		cfg.pagerDutyURL = defaultPagerDutyURL
+		if v := strings.TrimSpace(os.Getenv("PAGERDUTY_EVENTS_URL")); v != "" {
+			cfg.pagerDutyURL = v
+		}
+	} else if parseBool(os.Getenv("PAGERDUTY_RESOLVE")) {
+		return nil, fmt.Errorf("PAGERDUTY_RESOLVE requires PAGERDUTY_ROUTING_KEY")
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *string
+	}{{"HEALTHCHECK_PING_URL", &cfg.pingURL}, {"HEALTHCHECK_PING_FAIL_URL", &cfg.pingFailURL}} {
+		v := strings.TrimSpace(os.Getenv(p.name))
+		if v == "" {
+			continue
+		}
+		// Checked with the placeholders filled in, as it will be requested.
+		if u, err := url.Parse(expandPingURL(v, runRecord{})); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("Invalid %s: expected an http or https URL", p.name)
+		}
+		*p.dst = v
+	}
+
+	cfg.statsdAddr = strings.TrimSpace(os.Getenv("CRON_STATSD_ADDR"))
+	if cfg.statsdAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.statsdAddr); err != nil {
+			return nil, fmt.Errorf("Invalid CRON_STATSD_ADDR '%s': %v", cfg.statsdAddr, err)
+		}
+		cfg.statsdPrefix = strings.Trim(strings.TrimSpace(os.Getenv("CRON_STATSD_PREFIX")), ".")
+		if cfg.statsdPrefix == "" {
+			cfg.statsdPrefix = "cronrunner"
+		}
+	}
+
+	cfg.pushgatewayURL = strings.TrimSpace(os.Getenv("CRON_PUSHGATEWAY_URL"))
+	if cfg.pushgatewayURL != "" {
+		if u, err := url.Parse(cfg.pushgatewayURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("Invalid CRON_PUSHGATEWAY_URL '%s': expected an absolute URL such as http://pushgateway:9091", cfg.pushgatewayURL)
+		}
+		cfg.pushgatewayInstance = strings.TrimSpace(os.Getenv("CRON_PUSHGATEWAY_INSTANCE"))
+		if cfg.pushgatewayInstance == "" {
+			cfg.pushgatewayInstance = defaultPushgatewayInstance()
+		}
+		cfg.pushgatewayTimeout, err = timeoutEnv("CRON_PUSHGATEWAY_TIMEOUT_SEC", 10*time.Second)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.s3Bucket = strings.TrimSpace(os.Getenv("S3_OUTPUT_BUCKET"))
+	cfg.s3KeyPrefix = strings.Trim(os.Getenv("S3_OUTPUT_KEY_PREFIX"), "/")
+	cfg.s3Compress = parseBool(os.Getenv("S3_OUTPUT_COMPRESS"))
+	cfg.s3Timeout, err = timeoutEnv("S3_OUTPUT_TIMEOUT_SEC", cfg.notifyHTTPTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// VAULT_ADDR and VAULT_TOKEN are read by the Vault client itself.
+	cfg.vaultSecretPath = strings.TrimSpace(os.Getenv("VAULT_SECRET_PATH"))
+	cfg.vaultLeaseRenew = parseBool(os.Getenv("VAULT_SECRET_LEASE_RENEW"))
+
+	cfg.otelEndpoint = strings.TrimSpace(os.Getenv("CRON_OTEL_ENDPOINT"))
+	if cfg.otelEndpoint != "" && !otelSupported {
+		return nil, fmt.Errorf("CRON_OTEL_ENDPOINT is set but this cronrunner was built without OpenTelemetry support (build with -tags otel)")
+	}
+
+	if len(signalNames) == 0 && os.Getenv("CRON_FORWARD_SIGNALS") != "" {
+		return nil, fmt.Errorf("CRON_FORWARD_SIGNALS is not supported on Windows")
+	}
+	cfg.forwardSignals, err = parseSignals(splitList(os.Getenv("CRON_FORWARD_SIGNALS")))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CRON_FORWARD_SIGNALS: %v", err)
+	}
+	// The shutdown signal is passed on to running commands unless
+	// CRON_FORWARD_SHUTDOWN turns it off; Windows can't signal them.
+	if v := strings.TrimSpace(os.Getenv("CRON_FORWARD_SHUTDOWN")); v != "" {
+		cfg.forwardShutdown = parseBool(v)
+		if cfg.forwardShutdown && termSignal == nil {
+			return nil, fmt.Errorf("CRON_FORWARD_SHUTDOWN is not supported on Windows")
+		}
+	} else {
+		cfg.forwardShutdown = termSignal != nil
+	}
+
+	return cfg, nil
+}
+
+// parseSyslogAddr splits CRON_SYSLOG_ADDR into a network and address. A
+// bare host:port means UDP; an empty value means the local syslog daemon.
+func parseSyslogAddr(v string) (network, addr string, err error) {
+	if v == "" {
+		return "", "", nil
+	}
+	network, addr, ok := strings.Cut(v, "://")
+	if !ok {
+		network, addr = "udp", v
+	}
+	switch network {
+	case "udp", "tcp":
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return "", "", fmt.Errorf("Invalid CRON_SYSLOG_ADDR '%s': %v", v, err)
+		}
+	case "unix", "unixgram":
+		if addr == "" {
+			return "", "", fmt.Errorf("Invalid CRON_SYSLOG_ADDR '%s': missing socket path", v)
+		}
+	default:
+		return "", "", fmt.Errorf("Invalid CRON_SYSLOG_ADDR '%s': network must be udp, tcp, unix or unixgram", v)
+	}
+	return network, addr, nil
+}
+
+// parseSchedules splits expr into its schedules and validates each with
+// the configured parser; source names the setting in errors.
+func (cfg *config) parseSchedules(source, expr string) ([]string, error) {
+	var schedules []string
+	for _, expr := range splitSchedules(expr) {
+		if cfg.parserName == parserSeconds {
+			expr = normalizeSchedule(expr)
+		}
+		if _, err := cfg.parser.Parse(expr); err != nil {
+			return nil, fmt.Errorf("Invalid %s '%s' for the %s parser: %v", source, expr, cfg.parserName, err)
+		}
+		if slices.Contains(schedules, expr) {
+			return nil, fmt.Errorf("Invalid %s: '%s' is listed more than once", source, expr)
+		}
+		schedules = append(schedules, expr)
+	}
+	return schedules, nil
+}
+
+// loadSchedule reads CRON_EXPRESSION, CRON_PARSER, CRON_TZ and the jitter
+// settings into cfg. An empty expression is left empty, for NATS queue
+// mode.
+func loadSchedule(cfg *config) error {
+	source := "CRON_EXPRESSION"
+	var cronDecoded []byte
+	var err error
+	if cfg.scheduleFile = strings.TrimSpace(os.Getenv("CRON_EXPRESSION_FILE")); cfg.scheduleFile != "" {
+		if os.Getenv("CRON_EXPRESSION") != "" {
+			return fmt.Errorf("CRON_EXPRESSION and CRON_EXPRESSION_FILE cannot both be set")
+		}
+		source = "CRON_EXPRESSION_FILE"
+		expr, err := readScheduleFile(cfg.scheduleFile)
+		if err != nil {
+			return fmt.Errorf("Failed to read CRON_EXPRESSION_FILE: %v", err)
+		}
+		cronDecoded = []byte(expr)
+	} else {
+		expr, err := decodeBase64Any(os.Getenv("CRON_EXPRESSION"))
+		if err != nil {
+			return fmt.Errorf("Failed to decode CRON_EXPRESSION: %v", err)
+		}
+		cronDecoded = []byte(expr)
+	}
+
+	cfg.parserName = strings.ToLower(strings.TrimSpace(os.Getenv("CRON_PARSER")))
+	if cfg.parserName == "" {
+		cfg.parserName = parserSeconds
+	}
+	cfg.parser, err = scheduleParser(cfg.parserName)
+	if err != nil {
+		return err
+	}
+	if cfg.schedules, err = cfg.parseSchedules(source, string(cronDecoded)); err != nil {
+		return err
+	}
+	cfg.schedule = strings.Join(cfg.schedules, scheduleSeparator+" ")
+
+	if cronTZ := strings.TrimSpace(os.Getenv("CRON_TZ")); cronTZ != "" {
+		loc, name, err := loadTimezone(cronTZ)
+		if err != nil {
+			return fmt.Errorf("Invalid CRON_TZ value '%s': %v", cronTZ, err)
+		}
+		cfg.location = loc
+		cfg.timezone = name
+		if name != cronTZ {
+			cfg.timezoneAlias = cronTZ
+		}
+	}
+	return loadJitter(cfg)
+}
+
+// loadKillAfter returns the run timeout and the setting it came from.
+// CRON_KILL_AFTER_SEC takes seconds or a duration such as "2m30s" and wins
+// over the minute-based CRON_TOTAL_TIMEOUT_MIN, which in turn wins over
+// its older name CRON_KILL_AFTER_MIN. Zero or negative minutes mean no
+// timeout, as they always have.
+func loadKillAfter() (time.Duration, string, error) {
+	for _, name := range []string{"CRON_KILL_AFTER_SEC", "CRON_TOTAL_TIMEOUT_MIN", "CRON_KILL_AFTER_MIN"} {
+		v := strings.TrimSpace(os.Getenv(name))
+		if v == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(v); err == nil && n < 0 && name != "CRON_KILL_AFTER_SEC" {
+			return 0, name, nil
+		}
+		d, err := durationEnv(name, 0)
+		return d, name, err
+	}
+	return 0, "", nil
+}
+
+// ptySizeEnv reads a terminal dimension for CRON_ALLOCATE_PTY.
+func ptySizeEnv(name string, def uint16) (uint16, error) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 16)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("Invalid %s value '%s': must be a positive integer", name, v)
+	}
+	return uint16(n), nil
+}
+
+// parseFileMode parses an octal permission setting such as 0640.
+func parseFileMode(name, v string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(strings.TrimPrefix(v, "0o"), 8, 32)
+	if err != nil || m > 0o777 {
+		return 0, fmt.Errorf("Invalid %s value '%s' (expected octal permissions such as 0640)", name, v)
+	}
+	return os.FileMode(m), nil
+}
+
+// parseBool accepts the truthy spellings used by RESTART_ON_FAIL: 1, true,
+// yes and y in any case.
+func parseBool(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "y":
+		return true
+	}
+	return false
+}
+
+// base64Encodings are the variants decodeBase64Data accepts, in the order
+// they are tried: padded standard, unpadded standard, then the URL-safe
+// alphabet (- and _ instead of + and /) with and without padding.
+var base64Encodings = []struct {
+	name string
+	enc  *base64.Encoding
+}{
+	{"standard", base64.StdEncoding},
+	{"unpadded standard", base64.RawStdEncoding},
+	{"URL-safe", base64.URLEncoding},
+	{"unpadded URL-safe", base64.RawURLEncoding},
+}
+
+// decodeBase64Data decodes a base64 value in whichever variant it was
+// encoded with, since CI systems and secret managers don't all use the
+// standard one, and logs the variant that matched at DEBUG. The error is
+// the standard encoding's.
+func decodeBase64Data(s string) ([]byte, error) {
+	var first error
+	for _, e := range base64Encodings {
+		b, err := e.enc.DecodeString(s)
+		if err == nil {
+			logf(LevelDebug, "Decoded %s base64 (%d bytes)", e.name, len(b))
+			return b, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return nil, first
+}
+
+// decodeBase64Any decodes a setting such as CRON_CMD with decodeBase64Data,
+// or takes it as plain text when it isn't base64. Many short words are
+// valid base64 too, so a value only counts as base64 if it decodes to
+// text; "true" or "date" decode to binary and are kept as they are.
+func decodeBase64Any(s string) (string, error) {
+	if b, err := decodeBase64Data(s); err == nil && isText(b) {
+		return string(b), nil
+	}
+	if !utf8.ValidString(s) {
+		return "", errors.New("neither base64 nor UTF-8 text")
+	}
+	logf(LevelDebug, "Value is not base64 text; using it as plain text")
+	return s, nil
+}
+
+// isText reports whether b is UTF-8 without control characters other than
+// tabs and line breaks.
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
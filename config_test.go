@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBase64Any(t *testing.T) {
+	// "?>" encodes to + and / in the standard alphabet, and to - and _ in
+	// the URL-safe one.
+	const cmd = "echo ?>??"
+	if std := base64.StdEncoding.EncodeToString([]byte(cmd)); !strings.ContainsAny(std, "+/") {
+		t.Fatalf("%q has no + or /, so the variants aren't told apart", std)
+	}
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"standard", base64.StdEncoding.EncodeToString([]byte(cmd)), cmd},
+		{"URL-safe", base64.URLEncoding.EncodeToString([]byte(cmd)), cmd},
+		{"unpadded standard", base64.RawStdEncoding.EncodeToString([]byte(cmd)), cmd},
+		{"unpadded URL-safe", base64.RawURLEncoding.EncodeToString([]byte(cmd)), cmd},
+		{"wrapped", "ZWNobyBo\naQo=", "echo hi\n"},
+		{"multiline", base64.StdEncoding.EncodeToString([]byte("set -e\n\tdate\r\n")), "set -e\n\tdate\r\n"},
+		{"plain schedule", "*/5 * * * *", "*/5 * * * *"},
+		{"plain descriptor", "@every 1h", "@every 1h"},
+		{"plain command", "/app/backup.sh --verbose", "/app/backup.sh --verbose"},
+		{"plain word that is base64", "true", "true"},
+		{"plain word that is unpadded base64", "date", "date"},
+		{"plain two letters", "ls", "ls"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeBase64Any(tt.value)
+			if err != nil || got != tt.want {
+				t.Errorf("decodeBase64Any(%q) = %q, %v; want %q", tt.value, got, err, tt.want)
+			}
+		})
+	}
+	for _, v := range []string{"\xff\xfe", "echo \xff"} {
+		if got, err := decodeBase64Any(v); err == nil {
+			t.Errorf("decodeBase64Any(%q) = %q, want an error for a value that is neither base64 nor text", v, got)
+		}
+	}
+}
+
+func TestDecodeBase64Data(t *testing.T) {
+	data := []byte{0, 0xff, 0xfe, '\n', 0x80}
+	for _, e := range base64Encodings {
+		got, err := decodeBase64Data(e.enc.EncodeToString(data))
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("decodeBase64Data(%q) = %v, %v; want %v", e.enc.EncodeToString(data), got, err, data)
+		}
+	}
+	if _, err := decodeBase64Data("not base64!"); err == nil {
+		t.Error("decodeBase64Data accepted plain text")
+	}
+}
+
+func TestDecodeBase64DataLogsVariant(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	defer func(level int) { logLevel = level }(logLevel)
+	logLevel = LevelDebug
+
+	// A value valid in several variants is decoded by the first in
+	// base64Encodings: "YWI" is unpadded base64 in both alphabets, and the
+	// standard one is tried first.
+	for value, want := range map[string]string{
+		"YWI=":      "Decoded standard base64",
+		"YWI":       "Decoded unpadded standard base64",
+		"Pz8-Pw==":  "Decoded URL-safe base64",
+		"Pz8-Pw":    "Decoded unpadded URL-safe base64",
+		"@every 1h": "using it as plain text",
+	} {
+		buf.Reset()
+		if _, err := decodeBase64Any(value); err != nil {
+			t.Fatalf("decodeBase64Any(%q): %v", value, err)
+		}
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("decodeBase64Any(%q) logged %q, want %q", value, buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// loadConfigMapDir reads CRONRUNNER_CONFIGMAP_DIR, a ConfigMap mounted as a
+// volume, and sets its keys as environment variables before the
+// configuration is loaded. Each file whose name is a valid variable name
+// holds that variable's value, the way Kubernetes projects ConfigMap keys;
+// files ending in .env hold KEY=VALUE lines instead. Variables that are
+// already set take precedence. It returns the names that were set.
+func loadConfigMapDir() ([]string, error) {
+	dir := strings.TrimSpace(os.Getenv("CRONRUNNER_CONFIGMAP_DIR"))
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CRONRUNNER_CONFIGMAP_DIR: %v", err)
+	}
+
+	vars := map[string]string{}
+	for _, e := range entries {
+		name := e.Name()
+		// Kubernetes keeps the real files in a ..<timestamp> directory
+		// behind a ..data symlink; the keys are symlinks into it.
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(name, ".env"):
+			if err := parseEnvFile(path, vars); err != nil {
+				return nil, fmt.Errorf("Invalid CRONRUNNER_CONFIGMAP_DIR: %v", err)
+			}
+		case envNameRe.MatchString(name):
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid CRONRUNNER_CONFIGMAP_DIR: %v", err)
+			}
+			vars[name] = strings.TrimRight(string(b), "\r\n")
+		}
+	}
+
+	var set []string
+	for name, value := range vars {
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return nil, fmt.Errorf("Invalid CRONRUNNER_CONFIGMAP_DIR: %v", err)
+		}
+		set = append(set, name)
+	}
+	slices.Sort(set)
+	return set, nil
+}
+
+// loadOverrides imports CRONRUNNER_CONFIGMAP_DIR and then copies the
+// command-line flags into the environment with applyFlags, in that order:
+// a flag wins over the ConfigMap as it does over the environment, and a
+// variable it unsets because it conflicts with the flag stays unset. It
+// returns the names imported from the ConfigMap that no flag overrode.
+func loadOverrides(applyFlags func() ([]string, error)) ([]string, error) {
+	imported, err := loadConfigMapDir()
+	if err != nil {
+		return nil, err
+	}
+	owned, err := applyFlags()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(imported, func(name string) bool { return slices.Contains(owned, name) }), nil
+}
+
+// parseEnvFile adds the KEY=VALUE lines of the file at path to vars. Blank
+// lines and # comments are skipped, a leading "export " is allowed and
+// values may be wrapped in single or double quotes.
+func parseEnvFile(path string, vars map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envNameRe.MatchString(name) {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == value[len(value)-1] && (value[0] == '"' || value[0] == '\'') {
+			if value[0] == '"' {
+				if value, err = strconv.Unquote(value); err != nil {
+					return fmt.Errorf("%s:%d: %v", path, n, err)
+				}
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		vars[name] = value
+	}
+	return sc.Err()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// clearEnv unsets names for the test and restores them afterwards, so that
+// variables the code under test sets don't leak into other tests.
+func clearEnv(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	dir := t.TempDir()
+	for name, value := range map[string]string{
+		"CRON_EXPRESSION_FILE": "/etc/cronrunner/schedule",
+		"CRON_SHELL":           "/bin/sh",
+		"CRON_TZ":              "Europe/Berlin",
+		"LOG_FILE":             "/var/log/job.log",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clearEnv(t, "CRON_EXPRESSION", "CRON_EXPRESSION_FILE", "CRON_SHELL", "CRON_TZ", "LOG_FILE")
+	t.Setenv("CRONRUNNER_CONFIGMAP_DIR", dir)
+	t.Setenv("LOG_FILE", "/tmp/from-env.log")
+
+	applyFlags := registerFlags()
+	if err := flag.CommandLine.Parse([]string{"-cron", "@every 5m", "-tz", "UTC"}); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := loadOverrides(applyFlags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// --cron unsets CRON_EXPRESSION_FILE, which the ConfigMap must not
+	// bring back.
+	if v, ok := os.LookupEnv("CRON_EXPRESSION_FILE"); ok {
+		t.Errorf("CRON_EXPRESSION_FILE = %q, want it unset by --cron", v)
+	}
+	if got, want := os.Getenv("CRON_EXPRESSION"), base64.StdEncoding.EncodeToString([]byte("@every 5m")); got != want {
+		t.Errorf("CRON_EXPRESSION = %q, want %q", got, want)
+	}
+	for name, want := range map[string]string{
+		"CRON_TZ":    "UTC",               // the flag wins over the ConfigMap
+		"CRON_SHELL": "/bin/sh",           // only in the ConfigMap
+		"LOG_FILE":   "/tmp/from-env.log", // the environment wins over the ConfigMap
+	} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if want := []string{"CRON_SHELL"}; !slices.Equal(imported, want) {
+		t.Errorf("imported %q, want %q", imported, want)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// describeSchedule renders a cron expression as English for
+// CRON_EXPRESSION_HUMAN and --print-config, e.g. "every day at 09:00
+// (America/New_York)". It covers the common shapes: fixed times, steps,
+// ranges and lists of minutes, hours, days, months and weekdays. Fields
+// it doesn't recognise are quoted as they are rather than guessed at.
+func describeSchedule(spec string, loc *time.Location) string {
+	spec = strings.TrimSpace(spec)
+	zone := "local time"
+	if loc != nil && loc != time.Local && loc.String() != "Local" {
+		zone = loc.String()
+	}
+	// A schedule may carry its own zone, as the scheduler allows.
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if rest, ok := strings.CutPrefix(spec, prefix); ok {
+			zone, spec, _ = strings.Cut(rest, " ")
+			spec = strings.TrimSpace(spec)
+			break
+		}
+	}
+	return fmt.Sprintf("%s (%s)", describeFields(spec), zone)
+}
+
+// describeSchedules describes each of specs.
+func describeSchedules(specs []string, loc *time.Location) []string {
+	var out []string
+	for _, spec := range specs {
+		out = append(out, describeSchedule(spec, loc))
+	}
+	return out
+}
+
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "every year on January 1 at 00:00",
+	"@annually": "every year on January 1 at 00:00",
+	"@monthly":  "on day 1 of every month at 00:00",
+	"@weekly":   "every Sunday at 00:00",
+	"@daily":    "every day at 00:00",
+	"@midnight": "every day at 00:00",
+	"@hourly":   "every hour at minute 0",
+}
+
+var (
+	monthNames   = []string{"", "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	weekdayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+)
+
+func describeFields(spec string) string {
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		return "every " + strings.TrimSpace(d)
+	}
+	if d, ok := scheduleDescriptors[strings.ToLower(spec)]; ok {
+		return d
+	}
+
+	f := strings.Fields(spec)
+	switch len(f) {
+	case 5:
+		f = append([]string{"0"}, f...)
+	case 6:
+	default:
+		return spec
+	}
+	sec, min, hour, dom, month, dow := f[0], f[1], f[2], f[3], f[4], f[5]
+
+	var when string
+	if times, ok := fixedTimes(sec, min, hour); ok {
+		days := describeDays(dom, month, dow)
+		if days == "" {
+			days = "every day"
+		}
+		when = days + " at " + times
+	} else {
+		when = describeClock(sec, min, hour)
+		if days := describeDays(dom, month, dow); days != "" {
+			when += ", " + days
+		}
+	}
+	return when
+}
+
+// fixedTimes lists the times of day for a schedule that fires at a few
+// exact times, such as "0 30 9,17 * * *".
+func fixedTimes(sec, min, hour string) (string, bool) {
+	s, okS := strconv.Atoi(sec)
+	m, okM := strconv.Atoi(min)
+	if okS != nil || okM != nil {
+		return "", false
+	}
+	var hours []int
+	for _, h := range strings.Split(hour, ",") {
+		n, err := strconv.Atoi(h)
+		if err != nil {
+			return "", false
+		}
+		hours = append(hours, n)
+	}
+	times := make([]string, len(hours))
+	for i, h := range hours {
+		times[i] = fmt.Sprintf("%02d:%02d", h, m)
+		if s != 0 {
+			times[i] += fmt.Sprintf(":%02d", s)
+		}
+	}
+	return joinWords(times, "and"), true
+}
+
+// describeClock describes the seconds, minutes and hours of a schedule
+// that fires more than a few times a day.
+func describeClock(sec, min, hour string) string {
+	var parts []string
+	switch {
+	case isWildcard(sec):
+		parts = append(parts, "every second")
+	case sec == "0":
+	default:
+		parts = append(parts, describeUnit(sec, "second", "seconds", nil))
+	}
+	switch {
+	case isWildcard(min) && len(parts) == 0:
+		parts = append(parts, "every minute")
+	case isWildcard(min):
+	case min == "0" && len(parts) == 0 && isWildcard(hour):
+		return "every hour at minute 0"
+	default:
+		parts = append(parts, describeUnit(min, "minute", "minutes", nil))
+	}
+	if !isWildcard(hour) {
+		if h, err := strconv.Atoi(hour); err == nil {
+			parts = append(parts, fmt.Sprintf("during hour %02d", h))
+		} else if step, ok := everyStep(hour); ok {
+			parts = append(parts, fmt.Sprintf("every %d hours", step))
+		} else {
+			parts = append(parts, "during hours "+describeValues(hour, func(n int) string { return fmt.Sprintf("%02d", n) }))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeDays describes the day, month and weekday fields, or returns ""
+// when the schedule runs on every day.
+func describeDays(dom, month, dow string) string {
+	var parts []string
+	dayOfMonth, dayOfWeek := !isWildcard(dom), !isWildcard(dow)
+	switch {
+	case dayOfMonth && dayOfWeek:
+		// The scheduler runs on days matching either field.
+		parts = append(parts, "on "+describeUnit(dom, "day", "days", nil)+" of the month or on "+describeWeekdays(dow))
+	case dayOfMonth:
+		parts = append(parts, "on "+describeUnit(dom, "day", "days", nil)+" of the month")
+	case dayOfWeek:
+		parts = append(parts, "on "+describeWeekdays(dow))
+	}
+	if !isWildcard(month) {
+		if len(parts) == 0 {
+			parts = append(parts, "every day")
+		}
+		parts = append(parts, "in "+describeValues(month, func(n int) string { return nameOf(monthNames, n) }))
+	}
+	return strings.Join(parts, " ")
+}
+
+func describeWeekdays(dow string) string {
+	if strings.ToUpper(dow) == "1-5" || strings.ToUpper(dow) == "MON-FRI" {
+		return "weekdays"
+	}
+	if v := strings.ToUpper(dow); v == "0,6" || v == "6,0" || v == "SAT,SUN" || v == "SUN,SAT" {
+		return "weekends"
+	}
+	return describeValues(dow, func(n int) string { return nameOf(weekdayNames, n%7) })
+}
+
+// describeUnit renders a field such as "*/15", "5" or "0,30" with its unit:
+// "every 15 minutes", "at minute 5", "at minutes 0 and 30".
+func describeUnit(v, one, many string, name func(int) string) string {
+	if step, ok := everyStep(v); ok {
+		return fmt.Sprintf("every %d %s", step, many)
+	}
+	if name == nil {
+		name = strconv.Itoa
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		if one == "day" {
+			return fmt.Sprintf("day %s", name(n))
+		}
+		return fmt.Sprintf("at %s %s", one, name(n))
+	}
+	if one == "day" {
+		return "days " + describeValues(v, name)
+	}
+	return fmt.Sprintf("at %s %s", many, describeValues(v, name))
+}
+
+// everyStep reports the n of "*/n".
+func everyStep(v string) (int, bool) {
+	rest, ok := strings.CutPrefix(v, "*/")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	return n, err == nil
+}
+
+// describeValues renders a list of values, ranges and steps, naming each
+// number with name: "Monday through Friday", "January and July".
+func describeValues(v string, name func(int) string) string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		items = append(items, describeItem(item, name))
+	}
+	return joinWords(items, "and")
+}
+
+func describeItem(item string, name func(int) string) string {
+	rng, step, hasStep := strings.Cut(item, "/")
+	var from, to string
+	if lo, hi, ok := strings.Cut(rng, "-"); ok {
+		from, to = fieldValue(lo, name), fieldValue(hi, name)
+	} else {
+		from = fieldValue(rng, name)
+	}
+	switch {
+	case hasStep && isWildcard(rng):
+		return "every " + step
+	case hasStep && to != "":
+		return fmt.Sprintf("every %s from %s through %s", step, from, to)
+	case hasStep:
+		return fmt.Sprintf("every %s from %s", step, from)
+	case to != "":
+		return from + " through " + to
+	}
+	return from
+}
+
+// fieldValue names a number, or a month or weekday abbreviation such as
+// "JAN" or "mon", through name; anything else is returned as it is.
+func fieldValue(v string, name func(int) string) string {
+	if n, err := strconv.Atoi(v); err == nil {
+		return name(n)
+	}
+	up := strings.ToUpper(v)
+	for _, names := range [][]string{monthNames, weekdayNames} {
+		for _, full := range names {
+			if len(full) >= 3 && strings.ToUpper(full[:3]) == up {
+				return full
+			}
+		}
+	}
+	return v
+}
+
+func nameOf(names []string, n int) string {
+	if n >= 0 && n < len(names) && names[n] != "" {
+		return names[n]
+	}
+	return strconv.Itoa(n)
+}
+
+func isWildcard(v string) bool {
+	return v == "*" || v == "?"
+}
+
+// joinWords joins items as English: "a", "a and b", "a, b and c".
+func joinWords(items []string, conj string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + conj + " " + items[len(items)-1]
+}
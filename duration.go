@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationEnv reads the time setting name. It takes a Go duration such as
+// "90s", "5m" or "1h30m", or, as it always has, a plain integer in the
+// unit the name's _MIN or _SEC suffix stands for. An unset setting gives
+// def; negative values are rejected.
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def, nil
+	}
+	d, err := parseDurationSetting(v, settingUnit(name))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s value '%s': %v", name, v, err)
+	}
+	return d, nil
+}
+
+// timeoutEnv is durationEnv for timeouts, which must be positive.
+func timeoutEnv(name string, def time.Duration) (time.Duration, error) {
+	d, err := durationEnv(name, def)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("Invalid %s value '%s': must be positive", name, os.Getenv(name))
+	}
+	return d, err
+}
+
+// settingUnit is the unit of a plain integer in the time setting name.
+func settingUnit(name string) time.Duration {
+	if strings.HasSuffix(name, "_MIN") {
+		return time.Minute
+	}
+	return time.Second
+}
+
+func parseDurationSetting(v string, unit time.Duration) (time.Duration, error) {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if errors.Is(err, strconv.ErrRange) && !strings.HasPrefix(v, "-") {
+		return 0, errors.New("too large")
+	}
+	if err == nil {
+		if n < 0 {
+			return 0, errors.New("must not be negative")
+		}
+		// A plain integer is multiplied by its unit, which can overflow
+		// long before ParseInt's own limit.
+		if n > math.MaxInt64/int64(unit) {
+			return 0, errors.New("too large")
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		units := "seconds"
+		if unit == time.Minute {
+			units = "minutes"
+		}
+		return 0, fmt.Errorf("expected a whole number of %s or a duration such as 90s or 1h30m", units)
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d, nil
+}
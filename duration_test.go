@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDurationEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr string
+	}{
+		{name: "CRTEST_WAIT_SEC", value: "", want: 7 * time.Second},
+		{name: "CRTEST_WAIT_SEC", value: "  ", want: 7 * time.Second},
+		{name: "CRTEST_WAIT_SEC", value: "90", want: 90 * time.Second},
+		{name: "CRTEST_WAIT_MIN", value: "90", want: 90 * time.Minute},
+		{name: "CRTEST_WAIT", value: "90", want: 90 * time.Second},
+		{name: "CRTEST_WAIT_SEC", value: " 5 ", want: 5 * time.Second},
+		{name: "CRTEST_WAIT_SEC", value: "0", want: 0},
+		{name: "CRTEST_WAIT_MIN", value: "0s", want: 0},
+		{name: "CRTEST_WAIT_SEC", value: "1h30m", want: 90 * time.Minute},
+		{name: "CRTEST_WAIT_MIN", value: "500ms", want: 500 * time.Millisecond},
+		{name: "CRTEST_WAIT_SEC", value: "9223372036", want: 9223372036 * time.Second},
+		{name: "CRTEST_WAIT_SEC", value: "-1", wantErr: "must not be negative"},
+		{name: "CRTEST_WAIT_MIN", value: "-5m", wantErr: "must not be negative"},
+		{name: "CRTEST_WAIT_SEC", value: "5x", wantErr: "whole number of seconds"},
+		{name: "CRTEST_WAIT_MIN", value: "5 minutes", wantErr: "whole number of minutes"},
+		{name: "CRTEST_WAIT_SEC", value: "1.5", wantErr: "whole number of seconds"},
+		{name: "CRTEST_WAIT_SEC", value: "9223372037", wantErr: "too large"},
+		{name: "CRTEST_WAIT_MIN", value: "153722868", wantErr: "too large"},
+		{name: "CRTEST_WAIT_SEC", value: "99999999999999999999", wantErr: "too large"},
+		{name: "CRTEST_WAIT_SEC", value: "3000000h", wantErr: "whole number of seconds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			got, err := durationEnv(tt.name, 7*time.Second)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("durationEnv() = %v, %v; want an error containing %q", got, err, tt.wantErr)
+				}
+				if !strings.HasPrefix(err.Error(), "Invalid "+tt.name+" value ") {
+					t.Errorf("error %q doesn't name the setting", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("durationEnv() = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: time.Minute},
+		{value: "30", want: 30 * time.Second},
+		{value: "1ns", want: time.Nanosecond},
+		{value: "0", wantErr: true},
+		{value: "0s", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "9223372037", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("CRTEST_TIMEOUT_SEC", tt.value)
+			got, err := timeoutEnv("CRTEST_TIMEOUT_SEC", time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("timeoutEnv() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("timeoutEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutEnvZeroDefault(t *testing.T) {
+	t.Setenv("CRTEST_TIMEOUT_SEC", "")
+	if _, err := timeoutEnv("CRTEST_TIMEOUT_SEC", 0); err == nil {
+		t.Error("timeoutEnv() accepted an unset setting with a zero default")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// buildChildEnv filters os.Environ() for the child process. With an allowlist
+// only variables whose name matches one of the glob patterns are kept; with a
+// blocklist every match is removed. It returns nil when neither list is set so
+// the child inherits the parent environment unchanged.
+func buildChildEnv(allowlist, blocklist []string) []string {
+	if len(allowlist) == 0 && len(blocklist) == 0 {
+		return nil
+	}
+
+	env := []string{}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if len(allowlist) > 0 && !matchAny(allowlist, name) {
+			continue
+		}
+		if len(blocklist) > 0 && matchAny(blocklist, name) {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(p, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutEnv removes the variables in names from env, which defaults to
+// os.Environ() like in withEnv.
+func withoutEnv(env []string, names []string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !slices.Contains(names, name) {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+// withEnv returns env (or the inherited environment when env is nil) with
+// the variables in extra added, replacing any existing values.
+func withEnv(env []string, extra map[string]string) []string {
+	if len(extra) == 0 {
+		return env
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	out := make([]string, 0, len(env)+len(extra))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, ok := extra[name]; !ok {
+			out = append(out, kv)
+		}
+	}
+	for k, v := range extra {
+		out = append(out, k+"="+v)
+	}
+	return out
+}
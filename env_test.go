@@ -0,0 +1,91 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildChildEnv(t *testing.T) {
+	t.Setenv("CRTEST_APP_NAME", "app")
+	t.Setenv("CRTEST_APP_PORT", "8080")
+	t.Setenv("CRTEST_SECRET_TOKEN", "s3cret")
+	t.Setenv("CRTEST_DEBUG", "1")
+
+	tests := []struct {
+		name      string
+		allowlist []string
+		blocklist []string
+		// want lists the CRTEST_ variables kept, sorted; only those are
+		// compared, so the rest of the test's own environment doesn't
+		// matter.
+		want []string
+	}{
+		{
+			name:      "allowlist globs",
+			allowlist: []string{"CRTEST_APP_*", "CRTEST_DEBUG"},
+			want:      []string{"CRTEST_APP_NAME=app", "CRTEST_APP_PORT=8080", "CRTEST_DEBUG=1"},
+		},
+		{
+			name:      "allowlist single character glob",
+			allowlist: []string{"CRTEST_APP_NAM?"},
+			want:      []string{"CRTEST_APP_NAME=app"},
+		},
+		{
+			name:      "blocklist",
+			blocklist: []string{"CRTEST_SECRET_*", "CRTEST_DEBUG"},
+			want:      []string{"CRTEST_APP_NAME=app", "CRTEST_APP_PORT=8080"},
+		},
+		{
+			name:      "blocklist applied after allowlist",
+			allowlist: []string{"CRTEST_*"},
+			blocklist: []string{"*_SECRET_*"},
+			want:      []string{"CRTEST_APP_NAME=app", "CRTEST_APP_PORT=8080", "CRTEST_DEBUG=1"},
+		},
+		{
+			name:      "invalid pattern matches nothing",
+			allowlist: []string{"CRTEST_[", "CRTEST_DEBUG"},
+			want:      []string{"CRTEST_DEBUG=1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildChildEnv(tt.allowlist, tt.blocklist)
+			if got == nil {
+				t.Fatal("got nil, which inherits the whole environment")
+			}
+			var kept []string
+			for _, kv := range got {
+				if strings.HasPrefix(kv, "CRTEST_") {
+					kept = append(kept, kv)
+				}
+			}
+			slices.Sort(kept)
+			if !slices.Equal(kept, tt.want) {
+				t.Errorf("kept %q, want %q", kept, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildChildEnvAllowlistOnlyKeepsMatches(t *testing.T) {
+	t.Setenv("CRTEST_APP_NAME", "app")
+	got := buildChildEnv([]string{"CRTEST_APP_*"}, nil)
+	if !slices.Equal(got, []string{"CRTEST_APP_NAME=app"}) {
+		t.Errorf("got %q, want only CRTEST_APP_NAME", got)
+	}
+}
+
+func TestBuildChildEnvUnset(t *testing.T) {
+	if got := buildChildEnv(nil, nil); got != nil {
+		t.Errorf("got %d variables, want nil so the child inherits the environment", len(got))
+	}
+}
+
+func TestBuildChildEnvNoMatch(t *testing.T) {
+	t.Setenv("CRTEST_APP_NAME", "app")
+	got := buildChildEnv([]string{"CRTEST_NO_SUCH_*"}, nil)
+	if got == nil || len(got) != 0 {
+		t.Errorf("got %q, want an empty, non-nil environment", got)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// eventStdout is the CRON_EVENT_FILE value that writes events to stdout.
+const eventStdout = "-"
+
+// Values of runEvent.Event.
+const (
+	eventRun     = "run"
+	eventSkipped = "skipped"
+)
+
+// eventLog appends a JSON line to CRON_EVENT_FILE for every finished run
+// and every skipped tick, for tools that tail the stream instead of parsing
+// the log. The file is opened for each event, so it can be rotated by
+// moving it away. A nil eventLog writes nothing.
+type eventLog struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// runEvent is one line of CRON_EVENT_FILE. Skipped ticks have no run ID,
+// exit code or attempts.
+type runEvent struct {
+	Event         string    `json:"event"`
+	Job           string    `json:"job"`
+	RunID         string    `json:"run_id,omitempty"`
+	Command       string    `json:"command"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	DurationMs    int64     `json:"duration_ms"`
+	ExitCode      *int      `json:"exit_code,omitempty"`
+	Killed        bool      `json:"killed"`
+	Attempts      int       `json:"attempts"`
+	Status        string    `json:"status,omitempty"`
+	SkippedReason string    `json:"skipped_reason,omitempty"`
+}
+
+// newEventLog creates the directory of path if it is missing, so that a
+// mistake in it shows at startup rather than after the first run.
+func newEventLog(path string) (*eventLog, error) {
+	if path != eventStdout {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &eventLog{path: path}, nil
+}
+
+// run records a finished run of command.
+func (l *eventLog) run(command string, rec runRecord) {
+	if l == nil {
+		return
+	}
+	code := rec.ExitCode
+	l.write(runEvent{
+		Event:      eventRun,
+		Job:        rec.Job,
+		RunID:      rec.RunID,
+		Command:    command,
+		Start:      rec.StartedAt.UTC(),
+		End:        rec.StartedAt.Add(time.Duration(rec.DurationMs) * time.Millisecond).UTC(),
+		DurationMs: rec.DurationMs,
+		ExitCode:   &code,
+		Killed:     rec.Killed,
+		Attempts:   rec.Attempts,
+		Status:     rec.status(),
+	})
+}
+
+// skip records a tick that was skipped for reason, one of skipReasons.
+func (l *eventLog) skip(job, command, reason string) {
+	if l == nil {
+		return
+	}
+	now := time.Now().UTC()
+	l.write(runEvent{Event: eventSkipped, Job: job, Command: command, Start: now, End: now, SkippedReason: reason})
+}
+
+func (l *eventLog) write(ev runEvent) {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		logf(LevelError, "Failed to encode CRON_EVENT_FILE event: %v", err)
+		return
+	}
+	if l.path == eventStdout {
+		if err := consoleStdout.writeLine(string(b)); err != nil {
+			logf(LevelError, "Failed to write event to stdout: %v", err)
+		}
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err == nil {
+		_, err = f.Write(append(b, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		logf(LevelError, "Failed to write CRON_EVENT_FILE '%s': %v", l.path, err)
+	}
+}
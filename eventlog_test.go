@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNewOutputFileDirectory covers the files cronrunner creates its parent
+// directory for.
+func TestNewOutputFileDirectory(t *testing.T) {
+	tests := []struct {
+		name string
+		open func(path string) error
+	}{
+		{"CRON_EXITCODE_FILE", func(path string) error {
+			_, err := newExitCodeFile(path, exitCodeFormatPlain)
+			return err
+		}},
+		{"CRON_EVENT_FILE", func(path string) error {
+			_, err := newEventLog(path)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "run", "cronrunner", "out")
+			if err := tt.open(path); err != nil {
+				t.Fatal(err)
+			}
+			if fi, err := os.Stat(filepath.Dir(path)); err != nil || !fi.IsDir() {
+				t.Fatalf("directory not created: %v", err)
+			}
+
+			blocker := filepath.Join(dir, "file")
+			if err := os.WriteFile(blocker, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.open(filepath.Join(blocker, "out")); err == nil {
+				t.Error("accepted a directory that is a file")
+			}
+		})
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l, err := newEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.run("backup.sh", runRecord{Job: "backup", RunID: "r1", ExitCode: 2, Attempts: 1})
+	l.skip("backup", "backup.sh", "overlap")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), b)
+	}
+	var run, skipped runEvent
+	if err := json.Unmarshal([]byte(lines[0]), &run); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &skipped); err != nil {
+		t.Fatal(err)
+	}
+	if run.Event != eventRun || run.RunID != "r1" || run.ExitCode == nil || *run.ExitCode != 2 {
+		t.Errorf("run event = %+v", run)
+	}
+	if skipped.Event != eventSkipped || skipped.SkippedReason != "overlap" || skipped.ExitCode != nil {
+		t.Errorf("skipped event = %+v", skipped)
+	}
+}
+
+func TestNewEventLogStdout(t *testing.T) {
+	if _, err := newEventLog(eventStdout); err != nil {
+		t.Errorf("newEventLog(%q): %v", eventStdout, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Values accepted by CRON_EXITCODE_FORMAT.
+const (
+	exitCodeFormatPlain = "plain"
+	exitCodeFormatJSON  = "json"
+)
+
+// exitCodeFile overwrites CRON_EXITCODE_FILE after every run with the run's
+// exit code, or a JSON summary of it, for sidecars and scripts that poll a
+// file instead of HTTP.
+type exitCodeFile struct {
+	path   string
+	format string
+
+	mu sync.Mutex
+}
+
+// exitCodeSummary is the CRON_EXITCODE_FORMAT=json content.
+type exitCodeSummary struct {
+	runRecord
+	Status     string    `json:"status"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// newExitCodeFile creates the directory of path if it is missing, so that
+// a mistake in it shows at startup rather than after the first run.
+func newExitCodeFile(path, format string) (*exitCodeFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return &exitCodeFile{path: path, format: format}, nil
+}
+
+func (f *exitCodeFile) report(rec runRecord) {
+	data := []byte(strconv.Itoa(rec.ExitCode) + "\n")
+	if f.format == exitCodeFormatJSON {
+		b, err := json.Marshal(exitCodeSummary{runRecord: rec, Status: rec.status(), FinishedAt: time.Now().UTC()})
+		if err != nil {
+			logf(LevelError, "Failed to encode CRON_EXITCODE_FILE for run %s: %v", rec.RunID, err)
+			return
+		}
+		data = append(b, '\n')
+	}
+
+	// Overlapping runs finish in any order; the lock only keeps their
+	// writes from interleaving.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := writeFileAtomic(f.path, data); err != nil {
+		logf(LevelError, "Failed to write CRON_EXITCODE_FILE '%s': %v", f.path, err)
+	}
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory, so readers see either the old or the new content.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExitCodeFilePlain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exitcode")
+	f, err := newExitCodeFile(path, exitCodeFormatPlain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.report(runRecord{ExitCode: 3})
+	if b, err := os.ReadFile(path); err != nil || string(b) != "3\n" {
+		t.Errorf("file holds %q, %v; want \"3\\n\"", b, err)
+	}
+}
+
+func TestExitCodeFileJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exitcode.json")
+	f, err := newExitCodeFile(path, exitCodeFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.report(runRecord{RunID: "r1", ExitCode: 0, Attempts: 1})
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got exitCodeSummary
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", b, err)
+	}
+	if got.RunID != "r1" || got.ExitCode != 0 || got.Status == "" || got.FinishedAt.IsZero() {
+		t.Errorf("got %+v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// exitTimedOut is reported on stdout for runs killed at a timeout, as
+// timeout(1) does.
+const exitTimedOut = 124
+
+// consoleStdout is the command's stdout on the console. It remembers
+// whether the output ended mid-line, so CRONRUNNER_EXIT_CODE_OUTPUT can
+// start its own line.
+var consoleStdout = &lineWriter{w: os.Stdout, atLineStart: true}
+
+type lineWriter struct {
+	mu          sync.Mutex
+	w           io.Writer
+	atLineStart bool
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n, err := l.w.Write(p)
+	if n > 0 {
+		l.atLineStart = p[n-1] == '\n'
+	}
+	return n, err
+}
+
+// writeLine writes s as a line of its own.
+func (l *lineWriter) writeLine(s string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.atLineStart {
+		s = "\n" + s
+	}
+	_, err := io.WriteString(l.w, s+"\n")
+	l.atLineStart = true
+	return err
+}
+
+// exitCodeOutput prints CRONRUNNER_EXIT_CODE=<N> on stdout after every run
+// for CRONRUNNER_EXIT_CODE_OUTPUT. N is always a non-negative number: runs
+// killed at a timeout report 124, and runs that ended without an exit
+// status, killed by a signal or never started, report 1.
+type exitCodeOutput struct{}
+
+func (exitCodeOutput) report(rec runRecord) {
+	code := rec.ExitCode
+	switch {
+	case rec.Killed:
+		code = exitTimedOut
+	case code < 0:
+		code = 1
+	}
+	if err := consoleStdout.writeLine(fmt.Sprintf("CRONRUNNER_EXIT_CODE=%d", code)); err != nil {
+		logf(LevelError, "Failed to write CRONRUNNER_EXIT_CODE for run %s: %v", rec.RunID, err)
+	}
+}
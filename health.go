@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	defaultRunsLimit  = 20
+	defaultQueryLimit = 50
+	maxRunsLimit      = 1000
+)
+
+// healthServer is the optional HTTP endpoint enabled by HEALTH_PORT.
+type healthServer struct {
+	r     *runner
+	token string
+	srv   *http.Server
+}
+
+func newHealthServer(port, token string, r *runner) *healthServer {
+	h := &healthServer{r: r, token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", h.handleHealthz)
+	mux.HandleFunc("GET /status", h.handleStatus)
+	mux.Handle("GET /metrics", metricsHandler(r))
+	mux.HandleFunc("GET /metrics/runs", h.handleRuns)
+	mux.HandleFunc("GET /runs", h.handleQueryRuns)
+	mux.HandleFunc("GET /next-run", h.handleNextRun)
+	mux.HandleFunc("GET /config", h.authorized(h.handleConfig))
+	mux.HandleFunc("POST /trigger", h.authorized(h.handleTrigger))
+	mux.HandleFunc("POST /pause", h.authorized(h.handlePause))
+	mux.HandleFunc("POST /resume", h.authorized(h.handleResume))
+	mux.HandleFunc("POST /reload", h.authorized(h.handleReload))
+	mux.HandleFunc("PUT /jobs/{name}", h.authorized(h.handleSetSchedule))
+	h.srv = &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return h
+}
+
+func (h *healthServer) start() {
+	go func() {
+		if err := h.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logf(LevelError, "Health server stopped: %v", err)
+		}
+	}()
+}
+
+func (h *healthServer) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = h.srv.Shutdown(ctx)
+}
+
+// authorized requires "Authorization: Bearer <HEALTH_API_TOKEN>" when a token
+// is configured.
+func (h *healthServer) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if h.token != "" {
+			got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+		}
+		next(w, req)
+	}
+}
+
+// handleHealthz answers 503 once LIVENESS_MAX_AGE_SEC has passed without a
+// successful run, so a Kubernetes liveness probe restarts the pod.
+func (h *healthServer) handleHealthz(w http.ResponseWriter, req *http.Request) {
+	if maxAge := h.r.cfg.livenessMaxAge; maxAge > 0 {
+		if stale, last := h.r.stale(maxAge); stale {
+			var lastSuccess *time.Time
+			if !last.IsZero() {
+				lastSuccess = &last
+			}
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{
+				"status":       "stale",
+				"paused":       false,
+				"last_success": lastSuccess,
+				"max_age_sec":  int(maxAge / time.Second),
+			})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "paused": h.r.isPaused()})
+}
+
+// handleConfig serves the resolved configuration, with the same redaction
+// as --print-config. It may reveal more about the deployment than the
+// status endpoints, so it requires HEALTH_API_TOKEN like the POST ones.
+func (h *healthServer) handleConfig(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, h.r.effectiveConfig())
+}
+
+func (h *healthServer) handleStatus(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, h.r.status())
+}
+
+// handleNextRun reports when the scheduler fires next, across all of the
+// job's schedules.
+func (h *healthServer) handleNextRun(w http.ResponseWriter, req *http.Request) {
+	if h.r.isPaused() {
+		writeJSON(w, http.StatusOK, map[string]any{"paused": true})
+		return
+	}
+	next, ok := h.r.nextRun()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no scheduled run")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"job":             h.r.cfg.jobName,
+		"next_run":        next.Format(time.RFC3339),
+		"next_run_in_sec": int64(max(time.Until(next), 0).Round(time.Second) / time.Second),
+	})
+}
+
+// handleTrigger starts an immediate run, subject to the same pause and
+// overlap rules as scheduled runs.
+func (h *healthServer) handleTrigger(w http.ResponseWriter, req *http.Request) {
+	runID, err := h.r.trigger(h.caller(req))
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusAccepted, map[string]any{"run_id": runID, "queued": true})
+	case errAlreadyRunning, errPaused:
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	}
+}
+
+func (h *healthServer) handlePause(w http.ResponseWriter, req *http.Request) {
+	h.r.setPaused(true, h.caller(req))
+	writeJSON(w, http.StatusOK, map[string]any{"paused": h.r.isPaused()})
+}
+
+func (h *healthServer) handleResume(w http.ResponseWriter, req *http.Request) {
+	h.r.setPaused(false, h.caller(req))
+	writeJSON(w, http.StatusOK, map[string]any{"paused": h.r.isPaused()})
+}
+
+// handleReload re-reads the schedule and command files like SIGHUP.
+func (h *healthServer) handleReload(w http.ResponseWriter, req *http.Request) {
+	switch err := h.r.reload(h.caller(req)); err {
+	case nil:
+		writeJSON(w, http.StatusOK, map[string]any{"reloaded": true})
+	case errNothingToReload:
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	}
+}
+
+// handleSetSchedule replaces the job's schedule with the one in the
+// request body, {"schedule":"…"}. cronrunner runs a single job, so {name}
+// must be its job name.
+func (h *healthServer) handleSetSchedule(w http.ResponseWriter, req *http.Request) {
+	if name := req.PathValue("name"); name != h.r.cfg.jobName {
+		writeError(w, http.StatusNotFound, "unknown job '"+name+"'")
+		return
+	}
+	var body struct {
+		Schedule string `json:"schedule"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 1<<16)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	ids, spec, err := h.r.setSchedule(body.Schedule, h.caller(req))
+	var se *scheduleError
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, map[string]any{"job": h.r.cfg.jobName, "schedule": spec, "entry_ids": ids})
+	case errors.As(err, &se):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusConflict, err.Error())
+	}
+}
+
+// metricsHandler serves GET /metrics in the Prometheus text format.
+func metricsHandler(r *runner) http.Handler {
+	registry := prometheus.NewRegistry()
+	// job_name rather than job, which Prometheus sets to the scrape job.
+	reg := prometheus.WrapRegistererWith(prometheus.Labels{"job_name": r.cfg.jobName}, registry)
+	reg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cronrunner_sigkills_total",
+		Help: "Commands that ignored SIGTERM for the CRON_KILL_GRACE_SEC grace period and were killed.",
+	}, func() float64 {
+		return float64(r.status().Sigkills)
+	}))
+	// The last run's resource usage; a run without it, such as one in
+	// Kubernetes Job mode, reports 0.
+	lastRun := func(value func(rec *runRecord) float64) func() float64 {
+		return func() float64 {
+			if rec := r.status().LastRun; rec != nil {
+				return value(rec)
+			}
+			return 0
+		}
+	}
+	reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cronrunner_last_run_cpu_user_seconds",
+		Help: "User CPU time of the last completed run, over all of its attempts.",
+	}, lastRun(func(rec *runRecord) float64 { return float64(rec.CPUUserMs) / 1000 })))
+	reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cronrunner_last_run_cpu_system_seconds",
+		Help: "System CPU time of the last completed run, over all of its attempts.",
+	}, lastRun(func(rec *runRecord) float64 { return float64(rec.CPUSystemMs) / 1000 })))
+	reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cronrunner_last_run_max_rss_bytes",
+		Help: "Peak resident memory of the last completed run's largest attempt; 0 where the platform doesn't report it.",
+	}, lastRun(func(rec *runRecord) float64 { return float64(rec.MaxRSSKB) * 1024 })))
+	for _, reason := range skipReasons {
+		reg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "cronrunner_skipped_runs_total",
+			Help:        "Scheduled ticks that were skipped, by reason: overlap, condition or paused.",
+			ConstLabels: prometheus.Labels{"reason": reason},
+		}, func() float64 {
+			return float64(r.status().Skipped[reason])
+		}))
+	}
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// caller describes who made an HTTP request for audit log lines: the remote
+// address and, when HEALTH_API_TOKEN is in use, a short fingerprint of the
+// token so it can be identified without being logged.
+func (h *healthServer) caller(req *http.Request) string {
+	id := "anonymous"
+	if h.token != "" {
+		sum := sha256.Sum256([]byte(h.token))
+		id = "token " + hex.EncodeToString(sum[:4])
+	}
+	return "HTTP from " + req.RemoteAddr + " (" + id + ")"
+}
+
+// handleRuns lists recent runs, newest first, paginated with ?limit= and
+// ?offset=.
+func (h *healthServer) handleRuns(w http.ResponseWriter, req *http.Request) {
+	limit, ok := queryInt(w, req, "limit", defaultRunsLimit)
+	if !ok {
+		return
+	}
+	offset, ok := queryInt(w, req, "offset", 0)
+	if !ok {
+		return
+	}
+	if limit < 1 || limit > maxRunsLimit {
+		writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxRunsLimit))
+		return
+	}
+	if offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must not be negative")
+		return
+	}
+
+	runs, err := h.r.history.list(limit, offset)
+	if err != nil {
+		logf(LevelError, "Failed to read run history: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to read run history")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"runs": runs, "limit": limit, "offset": offset})
+}
+
+// handleQueryRuns serves GET /runs: runs filtered by ?job=, ?status=,
+// ?from= and ?to= (RFC 3339), newest first, paginated with ?limit= and
+// ?offset=.
+func (h *healthServer) handleQueryRuns(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	f := runFilter{job: q.Get("job"), status: q.Get("status")}
+
+	var ok bool
+	if f.limit, ok = queryInt(w, req, "limit", defaultQueryLimit); !ok {
+		return
+	}
+	if f.offset, ok = queryInt(w, req, "offset", 0); !ok {
+		return
+	}
+	if f.limit < 1 || f.limit > maxRunsLimit {
+		writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxRunsLimit))
+		return
+	}
+	if f.offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must not be negative")
+		return
+	}
+	switch f.status {
+	case "", statusSuccess, statusFailure:
+	default:
+		writeError(w, http.StatusBadRequest, "status must be success or failure")
+		return
+	}
+	if f.from, ok = queryTime(w, req, "from"); !ok {
+		return
+	}
+	if f.to, ok = queryTime(w, req, "to"); !ok {
+		return
+	}
+	if !f.from.IsZero() && !f.to.IsZero() && f.from.After(f.to) {
+		writeError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+
+	if f.job != "" && f.job != h.r.cfg.jobName {
+		known, err := h.r.history.hasJob(f.job)
+		if err != nil {
+			logf(LevelError, "Failed to read run history: %v", err)
+			writeError(w, http.StatusInternalServerError, "failed to read run history")
+			return
+		}
+		if !known {
+			writeError(w, http.StatusBadRequest, "unknown job "+strconv.Quote(f.job))
+			return
+		}
+	}
+
+	runs, total, err := h.r.history.query(f)
+	if err != nil {
+		logf(LevelError, "Failed to read run history: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to read run history")
+		return
+	}
+	var next *int
+	if end := f.offset + len(runs); end < total {
+		next = &end
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"runs": runs, "total": total, "next_offset": next})
+}
+
+// queryTime parses an optional RFC 3339 query parameter, writing a 400
+// response and returning false if it is malformed.
+func queryTime(w http.ResponseWriter, req *http.Request, name string) (time.Time, bool) {
+	s := req.URL.Query().Get(name)
+	if s == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid "+name+" parameter: expected RFC 3339 time")
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// queryInt parses an optional integer query parameter, writing a 400
+// response and returning false if it is malformed.
+func queryInt(w http.ResponseWriter, req *http.Request, name string, def int) (int, bool) {
+	s := req.URL.Query().Get(name)
+	if s == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid "+name+" parameter")
+		return 0, false
+	}
+	return n, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
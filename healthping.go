@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// healthPinger sends the GET requests that dead man's switch services such
+// as Healthchecks.io and Dead Man's Snitch expect after every run:
+// HEALTHCHECK_PING_URL when it succeeded and HEALTHCHECK_PING_FAIL_URL when
+// it failed.
+type healthPinger struct {
+	successURL string
+	failURL    string
+	client     *http.Client
+}
+
+func newHealthPinger(cfg *config) *healthPinger {
+	return &healthPinger{
+		successURL: cfg.pingURL,
+		failURL:    cfg.pingFailURL,
+		client:     newHTTPClient(cfg.notifyHTTPTimeout),
+	}
+}
+
+// report pings before the run is considered finished, bounded by
+// NOTIFY_HTTP_TIMEOUT_SEC. Failures are logged and never affect the run's
+// outcome.
+func (h *healthPinger) report(rec runRecord) {
+	target, kind := h.successURL, "success"
+	if !rec.succeeded() {
+		target, kind = h.failURL, "failure"
+	}
+	if target == "" {
+		return
+	}
+	resp, err := h.client.Get(expandPingURL(target, rec))
+	if err != nil {
+		// The error repeats the URL, which usually holds the check's token.
+		logf(LevelError, "Failed to send %s health check ping for run %s", kind, rec.RunID)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		logf(LevelWarn, "Health check %s ping for run %s returned %s", kind, rec.RunID, resp.Status)
+		return
+	}
+	logf(LevelDebug, "Sent %s health check ping for run %s", kind, rec.RunID)
+}
+
+// expandPingURL fills in {duration}, the run's duration in seconds, and
+// {exit_code}.
+func expandPingURL(s string, rec runRecord) string {
+	duration := time.Duration(rec.DurationMs) * time.Millisecond
+	return strings.NewReplacer(
+		"{duration}", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64),
+		"{exit_code}", strconv.Itoa(rec.ExitCode),
+	).Replace(s)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultHistorySize is the number of runs kept in memory when no
+// HISTORY_DB_PATH is configured.
+const defaultHistorySize = 100
+
+// runRecord is the outcome of one scheduled or manual run, across all of
+// its attempts.
+type runRecord struct {
+	Job         string    `json:"job"`
+	RunID       string    `json:"run_id"`
+	ScheduledAt time.Time `json:"scheduled_at,omitzero"`
+	StartedAt   time.Time `json:"started_at"`
+	ExitCode    int       `json:"exit_code"`
+	DurationMs  int64     `json:"duration_ms"`
+	Attempts    int       `json:"attempts"`
+	Killed      bool      `json:"killed"`
+	MemLimitHit bool      `json:"mem_limit_hit,omitempty"`
+	NotFound    bool      `json:"command_not_found,omitempty"`
+	// Sigkill is set when the command ignored SIGTERM for the whole
+	// CRON_KILL_GRACE_SEC grace period and had to be killed.
+	Sigkill bool `json:"sigkill,omitempty"`
+	// CPUUserMs and CPUSystemMs add up the CPU time of every attempt,
+	// and MaxRSSKB is the largest peak memory of any of them. They stay
+	// zero where the command's usage isn't known, as in Kubernetes Job
+	// and Lambda mode.
+	CPUUserMs   int64 `json:"cpu_user_ms,omitempty"`
+	CPUSystemMs int64 `json:"cpu_system_ms,omitempty"`
+	MaxRSSKB    int64 `json:"max_rss_kb,omitempty"`
+}
+
+// addUsage adds the resource usage of an exited attempt to the run.
+func (rec *runRecord) addUsage(state *os.ProcessState) {
+	rec.CPUUserMs += state.UserTime().Milliseconds()
+	rec.CPUSystemMs += state.SystemTime().Milliseconds()
+	rec.MaxRSSKB = max(rec.MaxRSSKB, maxRSSKB(state))
+}
+
+// succeeded reports whether the final attempt exited 0 without being killed.
+func (rec runRecord) succeeded() bool {
+	return rec.ExitCode == 0 && !rec.Killed
+}
+
+// status classifies the run for metrics and notifications. A missing
+// command is reported apart from ordinary failures since it points at a
+// broken deployment rather than a failing job.
+func (rec runRecord) status() string {
+	switch {
+	case rec.succeeded():
+		return "success"
+	case rec.NotFound:
+		return "command_not_found"
+	}
+	return "failure"
+}
+
+// reporter is notified after every run completes.
+type reporter interface {
+	report(rec runRecord)
+}
+
+// history stores completed runs, newest first when listed.
+type history interface {
+	add(rec runRecord) error
+	list(limit, offset int) ([]runRecord, error)
+	// query returns one page of the runs matching f and the total number
+	// of matching runs.
+	query(f runFilter) ([]runRecord, int, error)
+	hasJob(job string) (bool, error)
+	// lastSuccess returns the start time of job's most recent successful
+	// run still in the history, or the zero time if there is none.
+	lastSuccess(job string) (time.Time, error)
+	close() error
+}
+
+// Values accepted by runFilter.status.
+const (
+	statusSuccess = "success"
+	statusFailure = "failure"
+)
+
+// runFilter selects runs for GET /runs. Zero values match everything; from
+// and to bound started_at inclusively.
+type runFilter struct {
+	job    string
+	status string
+	from   time.Time
+	to     time.Time
+	limit  int
+	offset int
+}
+
+func (f runFilter) match(rec runRecord) bool {
+	if f.job != "" && rec.Job != f.job {
+		return false
+	}
+	switch f.status {
+	case statusSuccess:
+		if !rec.succeeded() {
+			return false
+		}
+	case statusFailure:
+		if rec.succeeded() {
+			return false
+		}
+	}
+	if !f.from.IsZero() && rec.StartedAt.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && rec.StartedAt.After(f.to) {
+		return false
+	}
+	return true
+}
+
+// memoryHistory is a fixed-size ring buffer of the most recent runs.
+type memoryHistory struct {
+	mu      sync.Mutex
+	records []runRecord
+	next    int
+	full    bool
+}
+
+func newMemoryHistory(size int) *memoryHistory {
+	return &memoryHistory{records: make([]runRecord, size)}
+}
+
+func (h *memoryHistory) add(rec runRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records[h.next] = rec
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+	return nil
+}
+
+func (h *memoryHistory) list(limit, offset int) ([]runRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := h.next
+	if h.full {
+		n = len(h.records)
+	}
+
+	out := []runRecord{}
+	for i := offset; i < n && len(out) < limit; i++ {
+		idx := (h.next - 1 - i + len(h.records)) % len(h.records)
+		out = append(out, h.records[idx])
+	}
+	return out, nil
+}
+
+func (h *memoryHistory) query(f runFilter) ([]runRecord, int, error) {
+	all, _ := h.list(len(h.records), 0)
+	out := []runRecord{}
+	total := 0
+	for _, rec := range all {
+		if !f.match(rec) {
+			continue
+		}
+		if total >= f.offset && len(out) < f.limit {
+			out = append(out, rec)
+		}
+		total++
+	}
+	return out, total, nil
+}
+
+func (h *memoryHistory) hasJob(job string) (bool, error) {
+	all, _ := h.list(len(h.records), 0)
+	for _, rec := range all {
+		if rec.Job == job {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (h *memoryHistory) lastSuccess(job string) (time.Time, error) {
+	all, _ := h.list(len(h.records), 0)
+	for _, rec := range all {
+		if rec.Job == job && rec.succeeded() {
+			return rec.StartedAt, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+func (h *memoryHistory) close() error { return nil }
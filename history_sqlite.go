@@ -0,0 +1,198 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_name     TEXT    NOT NULL,
+	run_id       TEXT    NOT NULL,
+	scheduled_at INTEGER NOT NULL,
+	started_at   INTEGER NOT NULL,
+	exit_code    INTEGER NOT NULL,
+	duration_ms  INTEGER NOT NULL,
+	attempts     INTEGER NOT NULL,
+	killed       INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_job_started ON runs (job_name, started_at);
+`
+
+// sqliteHistory persists runs to HISTORY_DB_PATH so they survive restarts.
+// Timestamps are stored as Unix milliseconds.
+type sqliteHistory struct {
+	db      *sql.DB
+	maxRows int
+}
+
+func openSQLiteHistory(path string, maxRows int) (*sqliteHistory, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	// A single connection serialises writers and avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initialise %s: %w", path, err)
+	}
+	for _, col := range []string{"mem_limit_hit", "command_not_found", "sigkill", "cpu_user_ms", "cpu_system_ms", "max_rss_kb"} {
+		if err := addColumn(db, col, "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("migrate %s: %w", path, err)
+		}
+	}
+	return &sqliteHistory{db: db, maxRows: maxRows}, nil
+}
+
+// addColumn adds a column to the runs table of databases created by an
+// older cronrunner.
+func addColumn(db *sql.DB, name, def string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('runs')`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			return err
+		}
+		if col == name {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE runs ADD COLUMN ` + name + ` ` + def)
+	return err
+}
+
+func (h *sqliteHistory) add(rec runRecord) error {
+	_, err := h.db.Exec(
+		`INSERT INTO runs (job_name, run_id, scheduled_at, started_at, exit_code, duration_ms, attempts, killed, mem_limit_hit, command_not_found, sigkill, cpu_user_ms, cpu_system_ms, max_rss_kb)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.Job, rec.RunID, rec.ScheduledAt.UnixMilli(), rec.StartedAt.UnixMilli(),
+		rec.ExitCode, rec.DurationMs, rec.Attempts, rec.Killed, rec.MemLimitHit, rec.NotFound, rec.Sigkill,
+		rec.CPUUserMs, rec.CPUSystemMs, rec.MaxRSSKB,
+	)
+	if err != nil {
+		return err
+	}
+	if h.maxRows > 0 {
+		_, err = h.db.Exec(
+			`DELETE FROM runs WHERE id <= (SELECT id FROM runs ORDER BY id DESC LIMIT 1 OFFSET ?)`,
+			h.maxRows,
+		)
+	}
+	return err
+}
+
+const runColumns = `job_name, run_id, scheduled_at, started_at, exit_code, duration_ms, attempts, killed, mem_limit_hit, command_not_found, sigkill, cpu_user_ms, cpu_system_ms, max_rss_kb`
+
+func (h *sqliteHistory) list(limit, offset int) ([]runRecord, error) {
+	rows, err := h.db.Query(
+		`SELECT `+runColumns+`
+		 FROM runs ORDER BY started_at DESC, id DESC LIMIT ? OFFSET ?`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanRuns(rows)
+}
+
+func (h *sqliteHistory) query(f runFilter) ([]runRecord, int, error) {
+	where, args := f.where()
+
+	var total int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM runs`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := h.db.Query(
+		`SELECT `+runColumns+` FROM runs`+where+`
+		 ORDER BY started_at DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, f.limit, f.offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	runs, err := scanRuns(rows)
+	return runs, total, err
+}
+
+func (h *sqliteHistory) hasJob(job string) (bool, error) {
+	var n int
+	err := h.db.QueryRow(`SELECT COUNT(*) FROM (SELECT 1 FROM runs WHERE job_name = ? LIMIT 1)`, job).Scan(&n)
+	return n > 0, err
+}
+
+func (h *sqliteHistory) lastSuccess(job string) (time.Time, error) {
+	var ms sql.NullInt64
+	err := h.db.QueryRow(
+		`SELECT MAX(started_at) FROM runs WHERE job_name = ? AND exit_code = 0 AND killed = 0`, job,
+	).Scan(&ms)
+	if err != nil || !ms.Valid {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms.Int64), nil
+}
+
+// where translates f into a WHERE clause with placeholders, and its
+// arguments. It returns an empty clause when f matches every run.
+func (f runFilter) where() (string, []any) {
+	var conds []string
+	var args []any
+	if f.job != "" {
+		conds = append(conds, "job_name = ?")
+		args = append(args, f.job)
+	}
+	switch f.status {
+	case statusSuccess:
+		conds = append(conds, "(exit_code = 0 AND killed = 0)")
+	case statusFailure:
+		conds = append(conds, "NOT (exit_code = 0 AND killed = 0)")
+	}
+	if !f.from.IsZero() {
+		conds = append(conds, "started_at >= ?")
+		args = append(args, f.from.UnixMilli())
+	}
+	if !f.to.IsZero() {
+		conds = append(conds, "started_at <= ?")
+		args = append(args, f.to.UnixMilli())
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func scanRuns(rows *sql.Rows) ([]runRecord, error) {
+	defer rows.Close()
+
+	out := []runRecord{}
+	for rows.Next() {
+		var rec runRecord
+		var scheduled, started int64
+		if err := rows.Scan(&rec.Job, &rec.RunID, &scheduled, &started,
+			&rec.ExitCode, &rec.DurationMs, &rec.Attempts, &rec.Killed, &rec.MemLimitHit, &rec.NotFound, &rec.Sigkill,
+			&rec.CPUUserMs, &rec.CPUSystemMs, &rec.MaxRSSKB); err != nil {
+			return nil, err
+		}
+		rec.ScheduledAt = time.UnixMilli(scheduled)
+		rec.StartedAt = time.UnixMilli(started)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func (h *sqliteHistory) close() error {
+	return h.db.Close()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultHTTPTimeout is the NOTIFY_HTTP_TIMEOUT_SEC default.
+const defaultHTTPTimeout = 30 * time.Second
+
+// gcpMetadataHost is the GCP metadata server, which newProxiedTransport
+// never proxies.
+const gcpMetadataHost = "metadata.google.internal"
+
+// sharedTransport carries cronrunner's own HTTP requests. It is
+// http.DefaultTransport, which follows HTTP_PROXY and the like from
+// cronrunner's environment, until useHTTPProxy replaces it for
+// CRONRUNNER_HTTP_PROXY.
+var sharedTransport = http.DefaultTransport.(*http.Transport)
+
+// newProxiedTransport is http.DefaultTransport sending requests through
+// proxyURL, which loadConfig has already validated. Loopback and link-local
+// addresses and the GCP metadata server, where the AWS and Google
+// credentials come from, are always reached directly.
+func newProxiedTransport(proxyURL string) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return t
+	}
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		host := req.URL.Hostname()
+		if host == "localhost" || host == gcpMetadataHost {
+			return nil, nil
+		}
+		if ip := net.ParseIP(host); ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast()) {
+			return nil, nil
+		}
+		return u, nil
+	}
+	return t
+}
+
+// useHTTPProxy applies CRONRUNNER_HTTP_PROXY, if set, before any client is
+// created.
+func useHTTPProxy(cfg *config) {
+	if cfg.httpProxy != "" {
+		sharedTransport = newProxiedTransport(cfg.httpProxy)
+	}
+}
+
+// newHTTPClient returns the client used for every outgoing HTTP call, so a
+// slow or hung server can never block a run indefinitely.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: sharedTransport}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// startInternalLog sends cronrunner's own log to CRONRUNNER_LOG_FILE, if
+// set, leaving stderr to the command. It runs before anything else is
+// logged, so that errors in the configuration end up in the file too. It
+// reports whether the log was redirected.
+func startInternalLog() (bool, error) {
+	path := strings.TrimSpace(os.Getenv("CRONRUNNER_LOG_FILE"))
+	if path == "" {
+		return false, nil
+	}
+	var maxBytes int64
+	if v := strings.TrimSpace(os.Getenv("CRONRUNNER_LOG_MAX_BYTES")); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return false, fmt.Errorf("Invalid CRONRUNNER_LOG_MAX_BYTES value '%s'", v)
+		}
+		maxBytes = n
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("Invalid CRONRUNNER_LOG_FILE: %v", err)
+	}
+	f, err := openRotatingFile(path, maxBytes)
+	if err != nil {
+		return false, fmt.Errorf("Invalid CRONRUNNER_LOG_FILE: %v", err)
+	}
+	log.SetOutput(f)
+	return true, nil
+}
+
+// rotatingFile appends to a file and, once it would grow past maxBytes,
+// renames it to <path>.1, replacing the previous one, and starts a new file.
+// A maxBytes of 0 never rotates.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	f        *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxBytes int64) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxBytes: maxBytes}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to rotate CRONRUNNER_LOG_FILE: %v\n", err)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate keeps writing to the current file if the new one can't be
+// opened.
+func (r *rotatingFile) rotate() error {
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	old := r.f
+	if err := r.open(); err != nil {
+		return err
+	}
+	return old.Close()
+}
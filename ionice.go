@@ -0,0 +1,27 @@
+package main
+
+import "fmt"
+
+// I/O scheduling classes accepted by CRON_IONICE_CLASS, as numbered by
+// ionice(1).
+const (
+	ioClassNone = iota
+	ioClassRealtime
+	ioClassBestEffort
+	ioClassIdle
+)
+
+var ioClassNames = [...]string{"none", "realtime", "best-effort", "idle"}
+
+// ioPriority is the I/O scheduling class and level applied to the command.
+type ioPriority struct {
+	class int
+	level int
+}
+
+func (p *ioPriority) String() string {
+	if p.class == ioClassNone || p.class == ioClassIdle {
+		return ioClassNames[p.class]
+	}
+	return fmt.Sprintf("%s, level %d", ioClassNames[p.class], p.level)
+}
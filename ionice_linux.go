@@ -0,0 +1,23 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+const ioniceSupported = true
+
+// ioprio_set(2) constants, from linux/ioprio.h.
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// setIOPriority applies an I/O scheduling class and level to the calling
+// thread, like ionice(1); the pre-exec helper then execs the command on it.
+// Level is ignored by the kernel for the none and idle classes.
+func setIOPriority(p *ioPriority) error {
+	prio := p.class<<ioprioClassShift | p.level
+	_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(prio))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
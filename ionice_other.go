@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+const ioniceSupported = false
+
+func setIOPriority(p *ioPriority) error {
+	return errors.New("I/O priority is only supported on Linux")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Values accepted by CRON_JITTER_MODE.
+const (
+	jitterRandom = "random"
+	jitterHost   = "host"
+)
+
+// jitterSchedule delays every run of the wrapped schedule. In host mode the
+// delay is the fixed offset derived from the instance, so the runs of one
+// instance stay on a stable grid; in random mode it is drawn anew for each
+// run, up to window.
+type jitterSchedule struct {
+	cron.Schedule
+	window time.Duration
+	offset time.Duration
+}
+
+func (s jitterSchedule) Next(t time.Time) time.Time {
+	if s.offset > 0 {
+		// Step back by the offset first, so a run that fired late by
+		// design doesn't make the wrapped schedule skip to the one after.
+		next := s.Schedule.Next(t.Add(-s.offset))
+		if next.IsZero() {
+			return next
+		}
+		return next.Add(s.offset)
+	}
+	next := s.Schedule.Next(t)
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(rand.N(s.window))
+}
+
+// loadJitter reads CRON_JITTER_SEC, CRON_JITTER_MODE and CRON_INSTANCE_ID
+// into cfg.
+func loadJitter(cfg *config) error {
+	if strings.TrimSpace(os.Getenv("CRON_JITTER_SEC")) == "" {
+		if os.Getenv("CRON_JITTER_MODE") != "" {
+			return fmt.Errorf("CRON_JITTER_MODE requires CRON_JITTER_SEC")
+		}
+		return nil
+	}
+	var err error
+	if cfg.jitter, err = durationEnv("CRON_JITTER_SEC", 0); err != nil {
+		return err
+	}
+
+	cfg.jitterMode = strings.ToLower(strings.TrimSpace(os.Getenv("CRON_JITTER_MODE")))
+	switch cfg.jitterMode {
+	case "":
+		cfg.jitterMode = jitterRandom
+	case jitterRandom:
+	case jitterHost:
+		cfg.instanceID = strings.TrimSpace(os.Getenv("CRON_INSTANCE_ID"))
+		if cfg.instanceID == "" {
+			if cfg.instanceID, err = os.Hostname(); err != nil || cfg.instanceID == "" {
+				return fmt.Errorf("CRON_JITTER_MODE=host needs CRON_INSTANCE_ID: the hostname is unavailable")
+			}
+		}
+		cfg.jitterOffset = hostJitterOffset(cfg.instanceID, cfg.jitter)
+	default:
+		return fmt.Errorf("Invalid CRON_JITTER_MODE value '%s' (expected %s or %s)", cfg.jitterMode, jitterRandom, jitterHost)
+	}
+	return nil
+}
+
+// hostJitterOffset maps id to an offset in [0, window), in whole
+// milliseconds, the same on every start.
+func hostJitterOffset(id string, window time.Duration) time.Duration {
+	ms := uint64(window / time.Millisecond)
+	if ms == 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return time.Duration(h.Sum64()%ms) * time.Millisecond
+}
+
+// parseSchedule parses spec with cfg's parser and applies its jitter.
+func (cfg *config) parseSchedule(spec string) (cron.Schedule, error) {
+	sched, err := cfg.parser.Parse(spec)
+	if err != nil || cfg.jitter <= 0 {
+		return sched, err
+	}
+	switch cfg.jitterMode {
+	case jitterHost:
+		if cfg.jitterOffset == 0 {
+			return sched, nil
+		}
+		return jitterSchedule{Schedule: sched, offset: cfg.jitterOffset}, nil
+	default:
+		return jitterSchedule{Schedule: sched, window: cfg.jitter}, nil
+	}
+}
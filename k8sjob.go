@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	k8sContainerName = "job"
+	k8sPollInterval  = 2 * time.Second
+	k8sDeleteTimeout = 30 * time.Second
+	k8sNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// k8sJobRunner runs each attempt as a batch/v1 Job in the cluster cronrunner
+// is deployed in, instead of as a local child process.
+type k8sJobRunner struct {
+	client         kubernetes.Interface
+	namespace      string
+	image          string
+	serviceAccount string
+	keep           bool
+}
+
+func newK8sJobRunner(cfg *config) (*k8sJobRunner, error) {
+	restCfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load in-cluster config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := cfg.k8sJobNamespace
+	if ns == "" {
+		if b, err := os.ReadFile(k8sNamespaceFile); err == nil {
+			ns = strings.TrimSpace(string(b))
+		}
+	}
+	if ns == "" {
+		ns = "default"
+	}
+	return &k8sJobRunner{
+		client:         client,
+		namespace:      ns,
+		image:          cfg.k8sJobImage,
+		serviceAccount: cfg.k8sJobServiceAccount,
+		keep:           cfg.k8sJobKeep,
+	}, nil
+}
+
+// k8sExitError reports a Job whose container exited with a non-zero code.
+type k8sExitError struct {
+	job  string
+	code int
+}
+
+func (e *k8sExitError) Error() string {
+	return fmt.Sprintf("job %s: container exited with code %d", e.job, e.code)
+}
+
+func (e *k8sExitError) ExitCode() int { return e.code }
+
+var k8sInvalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// k8sJobName derives a DNS-1123 compliant Job name from the job name and
+// run ID.
+func k8sJobName(job, runID string) string {
+	base := strings.Trim(k8sInvalidNameChars.ReplaceAllString(strings.ToLower(job), "-"), "-")
+	if base == "" {
+		base = "cronrunner"
+	}
+	// Leave room for "-<run id>" within the 63 character limit.
+	if max := 63 - len(runID) - 1; len(base) > max {
+		base = strings.TrimRight(base[:max], "-")
+	}
+	return base + "-" + runID
+}
+
+// run creates a Job for req, streams its logs to out and waits for it to
+// finish. A non-zero container exit code is returned as a *k8sExitError.
+func (k *k8sJobRunner) run(ctx context.Context, req runRequest, out io.Writer) error {
+	name := k8sJobName(req.job, req.runID)
+	job := k.jobSpec(ctx, name, req)
+
+	created, err := k.client.BatchV1().Jobs(k.namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("create job %s: %w", name, err)
+	}
+	logf(LevelInfo, "Created Kubernetes Job %s/%s", k.namespace, name)
+	defer k.cleanup(name)
+
+	pod, err := k.waitForPod(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := k.streamLogs(ctx, pod, out); err != nil {
+		logf(LevelWarn, "Warning: failed to stream logs of pod %s: %v", pod, err)
+	}
+	if err := k.waitForJob(ctx, name, created.ResourceVersion); err != nil {
+		return err
+	}
+
+	code, err := k.exitCode(ctx, pod)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &k8sExitError{job: name, code: code}
+	}
+	return nil
+}
+
+func (k *k8sJobRunner) jobSpec(ctx context.Context, name string, req runRequest) *batchv1.Job {
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": "cronrunner",
+		"cronrunner/run-id":            req.runID,
+	}
+
+	var env []corev1.EnvVar
+	for k, v := range req.env {
+		env = append(env, corev1.EnvVar{Name: k, Value: v})
+	}
+
+	// Retries are handled by RESTART_ON_FAIL, so the Job itself never
+	// retries; the kill deadline becomes the Job's active deadline.
+	backoffLimit := int32(0)
+	var activeDeadline *int64
+	if deadline, ok := ctx.Deadline(); ok {
+		sec := int64(time.Until(deadline).Seconds()) + 1
+		activeDeadline = &sec
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: activeDeadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: k.serviceAccount,
+					Containers: []corev1.Container{{
+						Name:    k8sContainerName,
+						Image:   k.image,
+						Command: req.argv,
+						Env:     env,
+					}},
+				},
+			},
+		},
+	}
+}
+
+// waitForPod returns the name of the Job's pod once its container has
+// started, or an error if the container cannot be started.
+func (k *k8sJobRunner) waitForPod(ctx context.Context, job string) (string, error) {
+	selector := "job-name=" + job
+	for {
+		pods, err := k.client.CoreV1().Pods(k.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return "", fmt.Errorf("list pods of job %s: %w", job, err)
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != corev1.PodPending {
+				return pod.Name, nil
+			}
+			for _, cs := range pod.Status.ContainerStatuses {
+				if w := cs.State.Waiting; w != nil {
+					switch w.Reason {
+					case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError":
+						return "", fmt.Errorf("pod %s cannot start: %s: %s", pod.Name, w.Reason, w.Message)
+					}
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(k8sPollInterval):
+		}
+	}
+}
+
+// streamLogs follows the pod's container logs until the container exits.
+func (k *k8sJobRunner) streamLogs(ctx context.Context, pod string, out io.Writer) error {
+	stream, err := k.client.CoreV1().Pods(k.namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: k8sContainerName,
+		Follow:    true,
+	}).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	_, err = io.Copy(out, stream)
+	return err
+}
+
+// waitForJob watches the Job until it is complete or has failed.
+func (k *k8sJobRunner) waitForJob(ctx context.Context, name, resourceVersion string) error {
+	jobs := k.client.BatchV1().Jobs(k.namespace)
+	for {
+		w, err := jobs.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   "metadata.name=" + name,
+			ResourceVersion: resourceVersion,
+		})
+		if err != nil {
+			return fmt.Errorf("watch job %s: %w", name, err)
+		}
+		for ev := range w.ResultChan() {
+			job, ok := ev.Object.(*batchv1.Job)
+			if !ok {
+				continue
+			}
+			if ev.Type == watch.Deleted {
+				w.Stop()
+				return fmt.Errorf("job %s was deleted", name)
+			}
+			resourceVersion = job.ResourceVersion
+			if jobFinished(job) {
+				w.Stop()
+				return nil
+			}
+		}
+		// The watch timed out or was closed by the server: resume it.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func jobFinished(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// exitCode reads the container's exit code from the pod's status.
+func (k *k8sJobRunner) exitCode(ctx context.Context, name string) (int, error) {
+	for {
+		pod, err := k.client.CoreV1().Pods(k.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return -1, fmt.Errorf("get pod %s: %w", name, err)
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Name == k8sContainerName && cs.State.Terminated != nil {
+				return int(cs.State.Terminated.ExitCode), nil
+			}
+		}
+		// The Job can be marked finished a moment before the kubelet has
+		// reported the container status.
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		case <-time.After(k8sPollInterval):
+		}
+	}
+}
+
+// cleanup deletes the Job and its pods unless K8S_JOB_KEEP is set.
+func (k *k8sJobRunner) cleanup(name string) {
+	if k.keep {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), k8sDeleteTimeout)
+	defer cancel()
+
+	policy := metav1.DeletePropagationBackground
+	err := k.client.BatchV1().Jobs(k.namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &policy})
+	if err != nil {
+		logf(LevelWarn, "Warning: failed to delete Kubernetes Job %s/%s: %v", k.namespace, name, err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// termination stops one attempt's command, on a timeout or a forced
+// shutdown. With a CRON_KILL_GRACE_SEC grace period the command gets
+// termSignal first and is only killed if it is still running once the
+// grace period is over; without one it is killed straight away.
+type termination struct {
+	grace time.Duration
+
+	mu        sync.Mutex
+	p         *os.Process
+	stopping  bool
+	exited    bool
+	escalated bool
+	timer     *time.Timer
+}
+
+func newTermination(grace time.Duration) *termination {
+	if termSignal == nil {
+		grace = 0
+	}
+	return &termination{grace: grace}
+}
+
+// stop starts stopping p and the rest of its process group, so a shell's
+// children go too. Later calls, from the timeout, the shutdown or the
+// OVERLAP_STRATEGY=replace path, are no-ops.
+func (t *termination) stop(p *os.Process) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopping || t.exited {
+		return nil
+	}
+	t.stopping, t.p = true, p
+	if t.grace <= 0 {
+		return signalGroup(p, os.Kill)
+	}
+	if err := signalGroup(p, termSignal); err != nil {
+		return signalGroup(p, os.Kill)
+	}
+	t.timer = time.AfterFunc(t.grace, t.kill)
+	return nil
+}
+
+func (t *termination) kill() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.exited {
+		return
+	}
+	t.escalated = true
+	logf(LevelWarn, "Command PID %d ignored %s for %v; sending SIGKILL", t.p.Pid, signalName(termSignal), t.grace)
+	_ = signalGroup(t.p, os.Kill)
+}
+
+// finished is called once the command has exited. It reports whether the
+// command had to be killed after ignoring termSignal.
+func (t *termination) finished() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exited = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	return t.escalated
+}
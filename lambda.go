@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// lambdaInvoker runs each attempt as a synchronous invocation of
+// LAMBDA_FUNCTION_NAME instead of a local child process.
+type lambdaInvoker struct {
+	client   *lambda.Client
+	function string
+}
+
+func newLambdaInvoker(function string, timeout time.Duration) (*lambdaInvoker, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	httpClient := awshttp.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+		t.Proxy = sharedTransport.Proxy
+	})
+	if timeout > 0 {
+		httpClient = httpClient.WithTimeout(timeout)
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("load AWS configuration: %w", err)
+	}
+	client := lambda.NewFromConfig(awsCfg)
+	return &lambdaInvoker{client: client, function: function}, nil
+}
+
+// lambdaError reports an invocation that the function itself failed, or
+// that returned a non-200 status. It counts as exit code 1.
+type lambdaError struct {
+	function string
+	reason   string
+}
+
+func (e *lambdaError) Error() string {
+	return fmt.Sprintf("lambda %s: %s", e.function, e.reason)
+}
+
+func (e *lambdaError) ExitCode() int { return 1 }
+
+// run invokes the function with the JSON-encoded command as its payload and
+// writes the response payload to out.
+func (l *lambdaInvoker) run(ctx context.Context, req runRequest, out io.Writer) error {
+	payload, err := json.Marshal(req.command)
+	if err != nil {
+		return err
+	}
+
+	resp, err := l.client.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName:   aws.String(l.function),
+		InvocationType: types.InvocationTypeRequestResponse,
+		Payload:        payload,
+	})
+	if err != nil {
+		return fmt.Errorf("invoke %s: %w", l.function, err)
+	}
+
+	logf(LevelInfo, "Lambda %s returned status %d (%d byte payload)", l.function, resp.StatusCode, len(resp.Payload))
+	if len(resp.Payload) > 0 {
+		_, _ = out.Write(resp.Payload)
+		_, _ = io.WriteString(out, "\n")
+	}
+
+	if resp.FunctionError != nil {
+		return &lambdaError{function: l.function, reason: "function error: " + aws.ToString(resp.FunctionError)}
+	}
+	if resp.StatusCode != 200 {
+		return &lambdaError{function: l.function, reason: fmt.Sprintf("status %d", resp.StatusCode)}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+const truncatedMarker = "\n...(truncated)\n"
+
+// outputBudget caps how many bytes of a run's output are written, across
+// every writer drawn from it. Once the budget is spent a truncation marker is
+// written and further output is discarded.
+type outputBudget struct {
+	mu        sync.Mutex
+	remaining int64
+	truncated bool
+}
+
+func newOutputBudget(limit int64) *outputBudget {
+	return &outputBudget{remaining: limit}
+}
+
+// writer returns an io.Writer that forwards to w while the budget lasts.
+// A nil budget returns w unchanged.
+func (b *outputBudget) writer(w io.Writer) io.Writer {
+	if b == nil {
+		return w
+	}
+	return &budgetWriter{b: b, w: w}
+}
+
+func (b *outputBudget) wasTruncated() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.truncated
+}
+
+type budgetWriter struct {
+	b *outputBudget
+	w io.Writer
+}
+
+// Write always reports that all of p was consumed, so dropping output never
+// surfaces as a short write to the command's output pipe.
+func (bw *budgetWriter) Write(p []byte) (int, error) {
+	b := bw.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.truncated {
+		return len(p), nil
+	}
+	if int64(len(p)) <= b.remaining {
+		b.remaining -= int64(len(p))
+		_, err := bw.w.Write(p)
+		return len(p), err
+	}
+
+	head := p[:b.remaining]
+	b.remaining = 0
+	b.truncated = true
+	if _, err := bw.w.Write(head); err != nil {
+		return len(p), err
+	}
+	_, err := io.WriteString(bw.w, truncatedMarker)
+	return len(p), err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultLogFlushInterval applies when LOG_OUTPUT_BUFFER_SIZE is set
+// without LOG_OUTPUT_FLUSH_INTERVAL_MS.
+const defaultLogFlushInterval = time.Second
+
+// logBuffer collects the command's output for LOG_FILE in a buffer of
+// LOG_OUTPUT_BUFFER_SIZE bytes, so a chatty command causes one write per
+// full buffer instead of one per line. It is flushed when full, every
+// LOG_OUTPUT_FLUSH_INTERVAL_MS so slow jobs still show up promptly, and
+// when the attempt ends. stdout and stderr are copied concurrently, so
+// writes are serialized.
+type logBuffer struct {
+	mu      sync.Mutex
+	w       *bufio.Writer
+	dst     io.Writer
+	stopped bool
+	err     error
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newLogBuffer(dst io.Writer, size int, interval time.Duration) *logBuffer {
+	b := &logBuffer{w: bufio.NewWriterSize(dst, size), dst: dst, done: make(chan struct{})}
+	if interval > 0 {
+		b.wg.Add(1)
+		go b.flushEvery(interval)
+	}
+	return b
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		return b.dst.Write(p)
+	}
+	return b.w.Write(p)
+}
+
+func (b *logBuffer) flushEvery(interval time.Duration) {
+	defer b.wg.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			b.mu.Lock()
+			if err := b.w.Flush(); err != nil && b.err == nil {
+				b.err = err
+			}
+			b.mu.Unlock()
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// stop flushes what is left and makes later writes, such as the end
+// separator, go straight to the file. It returns the first error any flush
+// ran into.
+func (b *logBuffer) stop() error {
+	close(b.done)
+	b.wg.Wait()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	if err := b.w.Flush(); err != nil && b.err == nil {
+		b.err = err
+	}
+	return b.err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Values accepted by CRON_LOG_COLOR.
+const (
+	logColorAuto   = "auto"
+	logColorAlways = "always"
+	logColorNever  = "never"
+)
+
+const (
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiReset  = "\x1b[0m"
+)
+
+// useLogColor resolves CRON_LOG_COLOR for the runner's stderr. auto colors
+// only a terminal, and honours NO_COLOR and TERM=dumb.
+func useLogColor(mode string) bool {
+	switch mode {
+	case logColorAlways:
+		return true
+	case logColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// colorWriter colors the runner's log lines by severity. log writes one
+// whole line per call, so each Write is classified on its own: lines
+// starting with "Failed", "Invalid" or "Error", or reporting a timeout, are
+// red, "Warning" lines are yellow and everything else is left alone.
+type colorWriter struct {
+	w io.Writer
+}
+
+func (c colorWriter) Write(p []byte) (int, error) {
+	color := logLineColor(string(p))
+	if color == "" {
+		return c.w.Write(p)
+	}
+	line := bytes.TrimSuffix(p, []byte("\n"))
+	var b bytes.Buffer
+	b.Grow(len(p) + len(color) + len(ansiReset))
+	b.WriteString(color)
+	b.Write(line)
+	b.WriteString(ansiReset)
+	if len(line) < len(p) {
+		b.WriteByte('\n')
+	}
+	if _, err := c.w.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logLineColor classifies a line written with the standard log flags,
+// skipping the "2006/01/02 15:04:05 " prefix and the "[job] " that
+// CRON_LOG_JOB_NAME adds.
+func logLineColor(line string) string {
+	msg := line
+	if len(msg) > 20 && msg[4] == '/' && msg[19] == ' ' {
+		msg = msg[20:]
+	}
+	if strings.HasPrefix(msg, "[") {
+		if _, rest, ok := strings.Cut(msg, "] "); ok {
+			msg = rest
+		}
+	}
+	switch {
+	case strings.HasPrefix(msg, "Warning"):
+		return ansiYellow
+	case strings.HasPrefix(msg, "Failed"), strings.HasPrefix(msg, "Invalid"),
+		strings.HasPrefix(msg, "Error"), strings.Contains(msg, " timed out "):
+		return ansiRed
+	}
+	return ""
+}
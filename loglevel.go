@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Log levels for LOG_LEVEL, lowest first.
+const (
+	LevelDebug = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[string]int{
+	"DEBUG":   LevelDebug,
+	"INFO":    LevelInfo,
+	"WARN":    LevelWarn,
+	"WARNING": LevelWarn,
+	"ERROR":   LevelError,
+}
+
+// logLevel is the LOG_LEVEL threshold; messages below it are dropped. Until
+// the configuration is loaded everything is logged at INFO and above.
+var logLevel = LevelInfo
+
+// logf logs a message at level if LOG_LEVEL lets it through. Fatal errors
+// still go through log.Fatal and are always shown.
+func logf(level int, format string, args ...interface{}) {
+	if level < logLevel {
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// parseLogLevel reads LOG_LEVEL; an empty value means INFO.
+func parseLogLevel(v string) (int, error) {
+	name := strings.ToUpper(strings.TrimSpace(v))
+	if name == "" {
+		return LevelInfo, nil
+	}
+	level, ok := levelNames[name]
+	if !ok {
+		return 0, fmt.Errorf("Invalid LOG_LEVEL '%s': must be DEBUG, INFO, WARN or ERROR", v)
+	}
+	return level, nil
+}
+
+func logLevelName(level int) string {
+	return [...]string{"DEBUG", "INFO", "WARN", "ERROR"}[level]
+}
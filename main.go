@@ -2,199 +2,468 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
-	"io"
+	"flag"
 	"log"
+	"maps"
 	"os"
-	"os/exec"
 	"os/signal"
-	"strconv"
+	"slices"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
 func main() {
-	cronExpr := os.Getenv("CRON_EXPRESSION")
-	appCmd := os.Getenv("CRON_CMD")
-	killAfterMinStr := os.Getenv("CRON_KILL_AFTER_MIN")
-	logFilePath := os.Getenv("LOG_FILE")
-	restartOnFailEnv := os.Getenv("RESTART_ON_FAIL")
-	cronTZ := os.Getenv("CRON_TZ")
+	runPrivilegeHelper()
 
-	if cronExpr == "" {
-		log.Fatal("CRON_EXPRESSION environment variable is required")
+	logToFile, err := startInternalLog()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if appCmd == "" {
-		log.Fatal("CRON_CMD environment variable is required")
+	flag.Usage = usage
+	applyFlags := registerFlags()
+	next := flag.Int("next", 0, "print the next `n` run times of the schedule and exit")
+	printCfg := flag.Bool("print-config", false, "print the resolved configuration as JSON and exit")
+	flag.Parse()
+	configMapVars, err := loadOverrides(applyFlags)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	var killAfterMin int
-	if killAfterMinStr != "" {
-		var err error
-		killAfterMin, err = strconv.Atoi(killAfterMinStr)
-		if err != nil {
-			log.Fatalf("Invalid CRON_KILL_AFTER_MIN value: %v", err)
+	// --next only needs the schedule, so the rest of the configuration
+	// (CRON_CMD included) isn't required.
+	if *next > 0 {
+		cfg := &config{}
+		if err := loadSchedule(cfg); err != nil {
+			log.Fatal(err)
+		}
+		if err := printNextRuns(os.Stdout, cfg, *next); err != nil {
+			log.Fatal(err)
 		}
+		return
 	}
 
-	// Cronrunner's own logs go to stderr by default.
-	// If LOG_FILE is set, it will capture only the child process output per run.
+	// CRONRUNNER_SELFTEST checks the configuration and integrations and
+	// exits without starting the scheduler.
+	if parseBool(os.Getenv("CRONRUNNER_SELFTEST")) {
+		os.Exit(selfTest(os.Stdout))
+	}
+
+	// Cronrunner's own logs go to stderr by default, or to syslog with
+	// CRON_SYSLOG once the config is loaded. If LOG_FILE is set, it will
+	// capture only the child process output per run.
 
-	cronDecoded, err := base64.StdEncoding.DecodeString(cronExpr)
+	cfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Failed to decode CRON_EXPRESSION: %v", err)
+		log.Fatal(err)
+	}
+	logLevel = cfg.logLevel
+	useHTTPProxy(cfg)
+	if *printCfg {
+		if err := printConfig(os.Stdout, cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	appDecoded, err := base64.StdEncoding.DecodeString(appCmd)
-	if err != nil {
-		log.Fatalf("Failed to decode CRON_CMD: %v", err)
+	toSyslog := false
+	if cfg.syslog {
+		dest := "the local syslog daemon"
+		if cfg.syslogAddr != "" {
+			dest = cfg.syslogNetwork + "://" + cfg.syslogAddr
+		}
+		if err := startSyslog(cfg.syslogNetwork, cfg.syslogAddr, cfg.syslogFacility, cfg.syslogTag); err != nil {
+			logf(LevelError, "Failed to connect to %s, logging to stderr instead: %v", dest, err)
+		} else {
+			toSyslog = true
+			logf(LevelInfo, "Logging to %s (facility %s, tag %s)", dest, cfg.syslogFacility, cfg.syslogTag)
+		}
+	}
+	if !toSyslog && !logToFile && useLogColor(cfg.logColor) {
+		log.SetOutput(colorWriter{w: os.Stderr})
+	}
+	// The job name only goes into the text when asked for; metrics and
+	// notifications carry it anyway.
+	if cfg.logJobName {
+		log.SetPrefix("[" + cfg.jobName + "] ")
+		log.SetFlags(log.Flags() | log.Lmsgprefix)
 	}
 
-	cronSchedule := string(cronDecoded)
-	appCommand := string(appDecoded)
+	if cfg.httpProxy != "" {
+		logf(LevelInfo, "Sending cronrunner's own HTTP requests through proxy %s", redactURLSecret(cfg.httpProxy))
+	}
+	if len(configMapVars) > 0 {
+		logf(LevelInfo, "Imported %s from CRONRUNNER_CONFIGMAP_DIR", strings.Join(configMapVars, ", "))
+	}
+	if cfg.natsURL != "" {
+		logf(LevelInfo, "Starting cronrunner in NATS queue mode: %s on %s", cfg.natsSubject, cfg.natsURL)
+	} else {
+		logf(LevelInfo, "Starting cronrunner with schedule: %s (%s parser)", cfg.schedule, cfg.parserName)
+		if cfg.describeSched {
+			for _, spec := range cfg.schedules {
+				logf(LevelInfo, "Schedule '%s' runs %s", spec, describeSchedule(spec, cfg.location))
+			}
+		}
+		if cfg.scheduleFile != "" {
+			logf(LevelInfo, "Schedule read from %s", cfg.scheduleFile)
+		}
+	}
+	if cfg.command != "" {
+		logf(LevelInfo, "Command to execute: %s", cfg.displayCommand(cfg.command))
+		if cfg.cmdFile != "" {
+			logf(LevelInfo, "Command is read from %s before every run", cfg.cmdFile)
+		}
+	}
+	if cfg.shell != "" {
+		logf(LevelInfo, "Running command through shell: %s -c", cfg.shell)
+	}
+	if cfg.condition != "" {
+		logf(LevelInfo, "Running scheduled runs only if %s exits 0 (timeout: %v)", cfg.displayCommand(cfg.condition), cfg.conditionTimeout)
+	}
+	if cfg.killAfter > 0 {
+		logf(LevelInfo, "Command timeout: %v (%s)", cfg.killAfter, cfg.killAfterVar)
+	}
+	if cfg.attemptTimeout > 0 {
+		logf(LevelInfo, "Attempt timeout: %v", cfg.attemptTimeout)
+	}
+	if cfg.totalDeadline > 0 {
+		logf(LevelInfo, "Tick deadline: %v (CRON_TOTAL_DEADLINE)", cfg.totalDeadline)
+	}
+	if cfg.warnAfter > 0 {
+		logf(LevelWarn, "Warning about runs that take longer than %v", cfg.warnAfter)
+	}
+	if len(cfg.envAllowlist) > 0 {
+		logf(LevelInfo, "Child environment allowlist: %s", strings.Join(cfg.envAllowlist, ","))
+	}
+	if len(cfg.envBlocklist) > 0 {
+		logf(LevelInfo, "Child environment blocklist: %s", strings.Join(cfg.envBlocklist, ","))
+	}
+	if cfg.runAs != nil {
+		logf(LevelInfo, "Running command as %s:%s (uid %d, gid %d)", cfg.runAs.user, cfg.runAs.group, cfg.runAs.uid, cfg.runAs.gid)
+	}
+	if len(cfg.groups) > 0 {
+		if len(cfg.groupIDs) > 0 {
+			logf(LevelInfo, "Command supplementary groups: %s", strings.Join(cfg.groups, ","))
+		} else {
+			logf(LevelInfo, "Command inherits supplementary groups %s from cronrunner (not root, so they are not set explicitly)", strings.Join(cfg.groups, ","))
+		}
+	}
+	if len(cfg.dropCaps) > 0 {
+		logf(LevelInfo, "Dropping capabilities from the command: %s", strings.Join(cfg.dropCapNames, ","))
+	}
+	if cfg.noNewPrivs {
+		logf(LevelInfo, "Starting the command with no_new_privs set")
+	}
+	if cfg.newNetns {
+		logf(LevelInfo, "Running the command in a new network namespace (loopback only)")
+	}
+	if cfg.netnsPath != "" {
+		logf(LevelInfo, "Running the command in network namespace %s", cfg.netnsPath)
+	}
+	if cfg.tmpDir {
+		logf(LevelInfo, "Giving each run its own TMPDIR under %s", cfg.tmpDirBase)
+	}
+	if cfg.seccompFilter != "" {
+		logf(LevelInfo, "Filtering the command's syscalls with seccomp %s", cfg.seccompSource)
+	}
+	if cfg.nice != nil {
+		logf(LevelInfo, "Command niceness: %d", *cfg.nice)
+		if *cfg.nice < 0 && os.Geteuid() != 0 {
+			logf(LevelWarn, "Warning: CRON_NICE=%d lowers niceness, which normally requires root or CAP_SYS_NICE", *cfg.nice)
+		}
+	}
+	if p := cfg.ioPriority; p != nil {
+		logf(LevelInfo, "Command I/O priority: %s", p)
+		if p.class == ioClassRealtime && os.Geteuid() != 0 {
+			logf(LevelWarn, "Warning: CRON_IONICE_CLASS=1 (realtime) normally requires root or CAP_SYS_ADMIN")
+		}
+	}
+	if cfg.umask != nil {
+		logf(LevelInfo, "Command umask: %04o", *cfg.umask)
+	}
+	if cfg.memLimitMB > 0 {
+		logf(LevelInfo, "Command memory limit: %d MB (RLIMIT_AS)", cfg.memLimitMB)
+	}
+	if len(cfg.rlimits) > 0 {
+		limits := make([]string, len(cfg.rlimits))
+		for i, l := range cfg.rlimits {
+			limits[i] = l.String()
+		}
+		logf(LevelInfo, "Command resource limits: %s", strings.Join(limits, ", "))
+	}
+	switch {
+	case cfg.stdinFile != "" && cfg.stdinSource != "":
+		logf(LevelWarn, "Warning: both %s and CRON_STDIN_FILE are set; using CRON_STDIN_FILE", cfg.stdinSource)
+		fallthrough
+	case cfg.stdinFile != "":
+		logf(LevelInfo, "Command stdin: %s (reopened for each run)", cfg.stdinFile)
+	case cfg.stdinSource != "":
+		logf(LevelInfo, "Command stdin: %d bytes from %s", len(cfg.stdinData), cfg.stdinSource)
+	}
+	if cfg.allowConcurrent {
+		logf(LevelInfo, "ALLOW_CONCURRENT is enabled; overlapping runs are allowed")
+	}
+	if cfg.maxConsecutiveFailures > 0 {
+		logf(LevelInfo, "Circuit breaker: %s after %d consecutive failed runs", cfg.breakerAction, cfg.maxConsecutiveFailures)
+	}
+	if cfg.clearProxy {
+		logf(LevelInfo, "Removing proxy variables from the command's environment (CRON_CLEAR_PROXY)")
+	}
+	if len(cfg.proxyEnv) > 0 {
+		logf(LevelInfo, "Setting proxy variables for the command: %s", strings.Join(slices.Sorted(maps.Keys(cfg.proxyEnv)), ","))
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.caEnv)) {
+		logf(LevelInfo, "Setting %s=%s for the command", name, cfg.caEnv[name])
+	}
+	switch {
+	case cfg.jitter > 0 && cfg.jitterMode == jitterHost:
+		logf(LevelInfo, "Scheduled runs are delayed by %s, the host jitter of instance %s within a %v CRON_JITTER_SEC window", cfg.jitterOffset, cfg.instanceID, cfg.jitter)
+	case cfg.jitter > 0:
+		logf(LevelInfo, "Scheduled runs are delayed by a random jitter of up to %s", cfg.jitter)
+	}
+	if cfg.maxRuns > 0 {
+		logf(LevelInfo, "Exiting after %d runs (CRON_MAX_RUNS)", cfg.maxRuns)
+	}
+	if cfg.exitCodePath != "" {
+		logf(LevelInfo, "Writing each run's exit code to %s (%s)", cfg.exitCodePath, cfg.exitCodeFmt)
+	}
+	if cfg.pidFilePath != "" {
+		logf(LevelInfo, "Writing command PID to %s while running", cfg.pidFilePath)
+	}
+	if len(cfg.forwardSignals) > 0 {
+		logf(LevelInfo, "Forwarding signals to the running command: %s", signalList(cfg.forwardSignals))
+	}
 
-	log.Printf("Starting cronrunner with schedule: %s", cronSchedule)
-	log.Printf("Command to execute: %s", appCommand)
-	if killAfterMin > 0 {
-		log.Printf("Command timeout: %d minutes", killAfterMin)
+	if len(cfg.waitFor) > 0 {
+		logf(LevelInfo, "Waiting for %s before scheduling", strings.Join(cfg.waitFor, ", "))
+		ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+		err := waitForAddrs(ctx, cfg.waitFor, cfg.waitTimeout)
+		interrupted := ctx.Err() == context.Canceled
+		stop()
+		if interrupted {
+			logf(LevelWarn, "Interrupted while waiting for dependencies; exiting")
+			os.Exit(0)
+		}
+		if err != nil {
+			log.Fatalf("CRON_WAIT_FOR failed: %v", err)
+		}
 	}
 
 	// Configure scheduler options
 	var cronOptions []cron.Option
-	cronOptions = append(cronOptions, cron.WithSeconds())
-	if strings.TrimSpace(cronTZ) != "" {
-		loc, tzErr := time.LoadLocation(strings.TrimSpace(cronTZ))
-		if tzErr != nil {
-			log.Fatalf("Invalid CRON_TZ value '%s': %v", cronTZ, tzErr)
+	cronOptions = append(cronOptions, cron.WithParser(cfg.parser))
+	if cfg.location != nil {
+		cronOptions = append(cronOptions, cron.WithLocation(cfg.location))
+		if cfg.timezoneAlias != "" {
+			logf(LevelInfo, "Resolved CRON_TZ alias %s to %s", cfg.timezoneAlias, cfg.timezone)
 		}
-		cronOptions = append(cronOptions, cron.WithLocation(loc))
-		log.Printf("Using CRON_TZ timezone: %s", cronTZ)
+		logf(LevelInfo, "Using CRON_TZ timezone: %s", cfg.timezone)
 	}
 
-	c := cron.New(cronOptions...)
+	stopTracing, err := initTracing(cfg.otelEndpoint)
+	if err != nil {
+		log.Fatalf("Failed to set up OpenTelemetry tracing: %v", err)
+	}
+	if cfg.otelEndpoint != "" {
+		logf(LevelInfo, "Exporting run spans to %s", cfg.otelEndpoint)
+	}
 
-	// Parse RESTART_ON_FAIL: accept 1, true, TRUE, True
-	restartOnFail := false
-	if restartOnFailEnv != "" {
-		switch strings.ToLower(strings.TrimSpace(restartOnFailEnv)) {
-		case "1", "true", "yes", "y":
-			restartOnFail = true
+	c := cron.New(cronOptions...)
+	r, err := newRunner(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.sched = c
+	logLogFilePerms(cfg)
+	if cfg.cloudWatchNamespace != "" {
+		logf(LevelInfo, "Publishing CloudWatch metrics to namespace %s in %s", cfg.cloudWatchNamespace, cfg.awsRegion)
+	}
+	if cfg.statsdAddr != "" {
+		logf(LevelInfo, "Sending StatsD metrics to %s with prefix %s", cfg.statsdAddr, cfg.statsdPrefix)
+	}
+	if cfg.pushgatewayURL != "" {
+		logf(LevelInfo, "Pushing run metrics to Pushgateway %s (job %s, instance %s)", cfg.pushgatewayURL, cfg.jobName, cfg.pushgatewayInstance)
+	}
+	if cfg.webhookURL != "" {
+		logf(LevelInfo, "Sending run notifications to %s (timeout: %v)", cfg.webhookURL, cfg.webhookTimeout)
+		if t := cfg.webhookTLS; t != nil {
+			if len(t.Certificates) > 0 {
+				logf(LevelInfo, "Webhook requests use a client certificate (mTLS)")
+			}
+			if t.InsecureSkipVerify {
+				logf(LevelWarn, "Warning: NOTIFY_WEBHOOK_TLS_SKIP_VERIFY is set; webhook server certificates are not verified")
+			}
 		}
 	}
-
-	_, err = c.AddFunc(cronSchedule, func() {
-
-		log.Printf("Executing command: %s", appCommand)
-
-		parts := strings.Fields(appCommand)
-		if len(parts) == 0 {
-			log.Printf("Empty command, skipping execution")
-			return
+	if cfg.pagerDutyKey != "" {
+		logf(LevelInfo, "Sending PagerDuty events for failed runs to %s (resolve: %v)", cfg.pagerDutyURL, cfg.pagerDutyResolve)
+	}
+	if cfg.slackURL != "" {
+		logf(LevelInfo, "Posting failed runs to Slack (output lines: %d)", cfg.slackTailLines)
+	}
+	if cfg.s3Bucket != "" {
+		logf(LevelInfo, "Uploading run output to s3://%s/%s (compress: %v)", cfg.s3Bucket, cfg.s3KeyPrefix, cfg.s3Compress)
+	}
+	if r.k8s != nil {
+		logf(LevelInfo, "Running commands as Kubernetes Jobs in namespace %s with image %s", r.k8s.namespace, r.k8s.image)
+	}
+	if r.vault != nil {
+		logf(LevelInfo, "Injecting secrets from Vault path %s into the command's environment", cfg.vaultSecretPath)
+	}
+	if r.lambda != nil {
+		logf(LevelInfo, "Invoking Lambda function %s for each run", r.lambda.function)
+	}
+	if r.cloudRun != nil {
+		logf(LevelInfo, "Running Cloud Run job %s for each run (credentials: %s)", r.cloudRun.name(), r.cloudRun.credentials)
+	}
+	if r.ssh != nil {
+		if cfg.sshInsecure {
+			logf(LevelWarn, "Warning: CRON_SSH_INSECURE is enabled; the host key of %s is not verified", cfg.sshHost)
 		}
+		logf(LevelInfo, "Running commands on %s as %s over SSH", cfg.sshHost, cfg.sshUser)
+	}
+	if cfg.historyDBPath != "" {
+		logf(LevelInfo, "Persisting run history to %s", cfg.historyDBPath)
+	}
 
-		start := time.Now()
-		var hardDeadline time.Time
-		if killAfterMin > 0 {
-			hardDeadline = start.Add(time.Duration(killAfterMin) * time.Minute)
-			log.Printf("Hard kill deadline set for %s (limit: %d minutes)", hardDeadline.Format(time.RFC3339), killAfterMin)
+	var queue *natsQueue
+	if cfg.natsURL != "" {
+		queue, err = startNATSQueue(r)
+		if err != nil {
+			log.Fatalf("Failed to start NATS consumer: %v", err)
 		}
+	} else {
+		if err := r.addSchedules(cfg.schedules); err != nil {
+			log.Fatalf("Failed to add cron job: %v", err)
+		}
+	}
 
-		for attempt := 1; ; attempt++ {
-
-			var cmd *exec.Cmd
-			var ctx context.Context
-			var cancel context.CancelFunc
+	var hs *healthServer
+	if cfg.healthPort != "" {
+		hs = newHealthServer(cfg.healthPort, cfg.healthAPIToken, r)
+		hs.start()
+		logf(LevelInfo, "Health server listening on :%s", cfg.healthPort)
+	}
 
-			if killAfterMin > 0 {
-				remaining := time.Until(hardDeadline)
-				if remaining <= 0 {
-					log.Printf("Kill deadline reached; not starting attempt %d", attempt)
-					break
-				}
-				ctx, cancel = context.WithTimeout(context.Background(), remaining)
-				cmd = exec.CommandContext(ctx, parts[0], parts[1:]...)
+	c.Start()
+	logf(LevelInfo, "Cron runner started successfully")
+
+	// SIGINT and SIGTERM shut the runner down, SIGUSR1 triggers a run,
+	// SIGUSR2 toggles pause and SIGHUP reloads, unless they are listed in
+	// CRON_FORWARD_SIGNALS, in which case they go to the child. An open
+	// circuit breaker with CRON_BREAKER_ACTION=exit also shuts down, with
+	// status 1, and so does completing CRON_MAX_RUNS runs, with status 0.
+	// On Windows only the shutdown signals exist.
+	handled := append([]os.Signal{}, shutdownSignals...)
+	for _, sig := range []os.Signal{triggerSignal, pauseSignal, reloadSignal} {
+		if sig != nil {
+			handled = append(handled, sig)
+		}
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, append(handled, cfg.forwardSignals...)...)
+	exitCode := 0
+	var sig os.Signal
+loop:
+	for {
+		sig = nil
+		select {
+		case sig = <-sigs:
+		case <-r.breakerTripped():
+			exitCode = 1
+			break loop
+		case <-r.maxRunsReached():
+			logf(LevelInfo, "All %d runs of CRON_MAX_RUNS completed", cfg.maxRuns)
+			break loop
+		}
+		if containsSignal(cfg.forwardSignals, sig) {
+			if n := r.signalActive(sig); n > 0 {
+				logf(LevelInfo, "Forwarded %s to %d running command(s)", signalName(sig), n)
 			} else {
-				cmd = exec.Command(parts[0], parts[1:]...)
-			}
-
-			// Open per-run log file (if provided) and tee only child process output
-			var cStdout io.Writer = os.Stdout
-			var cStderr io.Writer = os.Stderr
-			var execLogFile *os.File
-			if logFilePath != "" {
-				f, openErr := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-				if openErr != nil {
-					log.Printf("Failed to open LOG_FILE '%s' for this run: %v", logFilePath, openErr)
-				} else {
-					execLogFile = f
-					// Write per-run start separator only to the log file
-					_, _ = io.WriteString(execLogFile, "===== RUN START "+time.Now().Format(time.RFC3339)+" =====\n")
-					cStdout = io.MultiWriter(os.Stdout, execLogFile)
-					cStderr = io.MultiWriter(os.Stderr, execLogFile)
-				}
-			}
-
-			cmd.Stdout = cStdout
-			cmd.Stderr = cStderr
-
-			err := cmd.Run()
-			duration := time.Since(start)
-
-			if cancel != nil {
-				cancel()
+				logf(LevelWarn, "Received %s but no command is running; ignoring", signalName(sig))
 			}
-
-			exitCode := 0
-			killed := false
-
-			if err != nil {
-				// Check if this was a timeout
-				if killAfterMin > 0 && ctx != nil && ctx.Err() == context.DeadlineExceeded {
-					log.Printf("Command timed out after %v; hard deadline %s reached (limit: %d minutes): %v", duration, hardDeadline.Format(time.RFC3339), killAfterMin, err)
-					killed = true
-				} else {
-					if ee, ok := err.(*exec.ExitError); ok {
-						exitCode = ee.ExitCode()
-					} else if cmd.ProcessState != nil {
-						exitCode = cmd.ProcessState.ExitCode()
-					}
-				}
-			}
-
-			// Write per-run end separator with exit code and duration, then close the log file
-			if execLogFile != nil {
-				_, _ = io.WriteString(execLogFile, "===== RUN END "+time.Now().Format(time.RFC3339)+" exit="+strconv.Itoa(exitCode)+" duration="+duration.String()+" =====\n\n")
-				_ = execLogFile.Close()
-			}
-
-			log.Printf("Command exited after %v: exit code %d, error: %v", duration, exitCode, err)
-
-			if restartOnFail && (killed || exitCode != 0) {
-				log.Printf("RESTART_ON_FAIL is enabled; restarting command...")
-				continue
-			}
-
-			log.Printf("Command completed")
-			break
+			continue
 		}
-	})
-
-	if err != nil {
-		log.Fatalf("Failed to add cron job: %v", err)
+		switch {
+		case triggerSignal != nil && sig == triggerSignal:
+			_, _ = r.trigger(signalName(sig))
+			continue
+		case pauseSignal != nil && sig == pauseSignal:
+			r.togglePaused(signalName(sig))
+			continue
+		case reloadSignal != nil && sig == reloadSignal:
+			_ = r.reload(signalName(sig))
+			continue
+		}
+		break loop
 	}
 
-	c.Start()
-	log.Printf("Cron runner started successfully")
+	logf(LevelInfo, "Shutting down cron runner...")
+	r.stop()
+	// Running commands get the shutdown signal right away, to save their
+	// state and exit before they are killed.
+	if sig != nil && cfg.forwardShutdown {
+		if n := r.signalActive(sig); n > 0 {
+			logf(LevelInfo, "Forwarded %s to %d running command(s)", signalName(sig), n)
+		}
+	}
+	if queue != nil {
+		queue.stop()
+	}
+	shutdown(c.Stop(), r, cfg.shutdownTimeout)
+	if queue != nil {
+		queue.close()
+	}
+	if hs != nil {
+		hs.shutdown()
+	}
+	r.close()
+	stopTracing()
+	logf(LevelInfo, "Cron runner stopped")
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+// shutdown waits for in-flight runs to finish once the scheduler has been
+// stopped. If they are still running after timeout (when non-zero), the
+// commands are killed.
+func shutdown(stopped context.Context, r *runner, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		<-stopped.Done()
+		<-r.wait()
+		close(done)
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		logf(LevelInfo, "Waiting up to %v for running commands to finish", timeout)
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	} else {
+		logf(LevelInfo, "Waiting for running commands to finish")
+	}
 
-	log.Printf("Shutting down cron runner...")
-	c.Stop()
-	log.Printf("Cron runner stopped")
+	select {
+	case <-done:
+		logf(LevelInfo, "Shutdown clean: all runs finished")
+	case <-expired:
+		n := r.terminateActive()
+		wait := 5 * time.Second
+		if grace := r.cfg.killGrace; grace > 0 && termSignal != nil {
+			logf(LevelWarn, "Shutdown forced: timeout of %v elapsed, sent %s to %d running command(s), SIGKILL after %v", timeout, signalName(termSignal), n, grace)
+			wait += grace
+		} else {
+			logf(LevelWarn, "Shutdown forced: timeout of %v elapsed, killed %d running command(s)", timeout, n)
+		}
+		select {
+		case <-done:
+		case <-time.After(wait):
+			logf(LevelError, "Runs did not finish after being killed; exiting anyway")
+		}
+	}
 }
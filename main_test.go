@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain lets the test binary act as the pre-exec helper, which
+// wrapPrivileges starts by re-executing it.
+func TestMain(m *testing.M) {
+	runPrivilegeHelper()
+	os.Exit(m.Run())
+}
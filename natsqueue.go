@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsInProgressInterval is how often a running message's ack deadline is
+// extended so long commands are not redelivered.
+const natsInProgressInterval = 15 * time.Second
+
+// natsRedeliveryDelay is how long a failed message waits before it is
+// redelivered, so a persistently failing command doesn't spin.
+const natsRedeliveryDelay = 10 * time.Second
+
+// natsMessage is the payload expected on NATS_SUBJECT. Every field is
+// optional: cmd defaults to CRON_CMD and run_id to a random ID.
+type natsMessage struct {
+	Cmd   string            `json:"cmd"`
+	RunID string            `json:"run_id"`
+	Env   map[string]string `json:"env"`
+}
+
+// natsQueue runs commands dequeued from a JetStream consumer instead of on
+// a cron schedule.
+type natsQueue struct {
+	r    *runner
+	nc   *nats.Conn
+	cc   jetstream.ConsumeContext
+	sema chan struct{}
+}
+
+func startNATSQueue(r *runner) (*natsQueue, error) {
+	cfg := r.cfg
+	nc, err := nats.Connect(cfg.natsURL, nats.Name("cronrunner"))
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", cfg.natsURL, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	stream, err := js.StreamNameBySubject(ctx, cfg.natsSubject)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("find stream for subject %s: %w", cfg.natsSubject, err)
+	}
+	cons, err := js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:       cfg.natsDurable,
+		FilterSubject: cfg.natsSubject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		MaxAckPending: cfg.natsMaxConcurrent,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("create consumer %s on stream %s: %w", cfg.natsDurable, stream, err)
+	}
+
+	q := &natsQueue{r: r, nc: nc, sema: make(chan struct{}, cfg.natsMaxConcurrent)}
+	q.cc, err = cons.Consume(q.handle, jetstream.PullMaxMessages(cfg.natsMaxConcurrent))
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	logf(LevelInfo, "Consuming %s from stream %s as %s (max concurrent: %d)", cfg.natsSubject, stream, cfg.natsDurable, cfg.natsMaxConcurrent)
+	return q, nil
+}
+
+// handle is called for each delivered message. It blocks while
+// NATS_MAX_CONCURRENT commands are already running.
+func (q *natsQueue) handle(msg jetstream.Msg) {
+	var m natsMessage
+	if err := json.Unmarshal(msg.Data(), &m); err != nil {
+		logf(LevelWarn, "Discarding malformed NATS message: %v", err)
+		_ = msg.Term()
+		return
+	}
+
+	req := q.r.request(m.RunID)
+	if req.runID == "" {
+		req.runID = newRunID()
+	}
+	if strings.TrimSpace(m.Cmd) != "" {
+		req.command = m.Cmd
+		req.argv = commandArgs(m.Cmd, q.r.cfg.shell)
+		if !q.r.cfg.jobNameSet {
+			req.job = jobName(m.Cmd)
+		}
+	}
+	req.env = m.Env
+
+	if err := q.r.reserve(false); err != nil {
+		logf(LevelWarn, "Not running NATS message %s: %v", req.runID, err)
+		_ = msg.NakWithDelay(natsRedeliveryDelay)
+		return
+	}
+
+	q.sema <- struct{}{}
+	go func() {
+		defer func() { <-q.sema }()
+		defer q.r.end()
+
+		stop := make(chan struct{})
+		go func() {
+			t := time.NewTicker(natsInProgressInterval)
+			defer t.Stop()
+			for {
+				select {
+				case <-t.C:
+					_ = msg.InProgress()
+				case <-stop:
+					return
+				}
+			}
+		}()
+
+		rec := q.r.execute(req)
+		close(stop)
+
+		if rec.succeeded() {
+			if err := msg.Ack(); err != nil {
+				logf(LevelError, "Failed to ack NATS message %s: %v", req.runID, err)
+			}
+			return
+		}
+		if err := msg.NakWithDelay(natsRedeliveryDelay); err != nil {
+			logf(LevelError, "Failed to nak NATS message %s: %v", req.runID, err)
+		}
+	}()
+}
+
+// stop stops fetching new messages; commands already running continue.
+func (q *natsQueue) stop() {
+	q.cc.Stop()
+}
+
+func (q *natsQueue) close() {
+	q.nc.Close()
+}
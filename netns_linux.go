@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const netnsSupported = true
+
+// netnsDir is where "ip netns add" creates named network namespaces.
+const netnsDir = "/var/run/netns"
+
+// resolveNetns turns CRON_NETNS_PATH into the namespace file to join: a
+// bare name refers to a namespace created with "ip netns add". It reports
+// an error unless the file is a network namespace cronrunner can enter.
+func resolveNetns(name string) (string, error) {
+	p := name
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(netnsDir, name)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(int(f.Fd()), &st); err != nil {
+		return "", err
+	}
+	if st.Type != unix.NSFS_MAGIC {
+		return "", fmt.Errorf("'%s' is not a namespace file", p)
+	}
+	return p, checkCapability(unix.CAP_SYS_ADMIN, "CAP_SYS_ADMIN")
+}
+
+// checkNewNetns verifies at startup that cronrunner may create a network
+// namespace for CRON_NEW_NETNS.
+func checkNewNetns() error {
+	return checkCapability(unix.CAP_SYS_ADMIN, "CAP_SYS_ADMIN")
+}
+
+// netnsAttr puts the child into a new network namespace for
+// CRON_NEW_NETNS. Joining CRON_NETNS_PATH needs setns between fork and
+// exec, which wrapPrivileges arranges through the helper instead.
+func netnsAttr(cfg *config, attr *syscall.SysProcAttr) {
+	if cfg.newNetns {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+}
+
+// joinNetns moves the calling thread into the network namespace at path.
+func joinNetns(path string) error {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return unix.Setns(fd, unix.CLONE_NEWNET)
+}
+
+// loopbackUp brings up "lo", which starts out down in a new network
+// namespace, so the command can still talk to services on localhost.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
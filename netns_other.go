@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+const netnsSupported = false
+
+func resolveNetns(name string) (string, error) {
+	return "", errors.New("network namespaces are only supported on Linux")
+}
+
+func checkNewNetns() error {
+	return errors.New("network namespaces are only supported on Linux")
+}
+
+func netnsAttr(cfg *config, attr *syscall.SysProcAttr) {}
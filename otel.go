@@ -0,0 +1,102 @@
+//go:build otel
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const otelSupported = true
+
+const otelShutdownTimeout = 5 * time.Second
+
+// initTracing exports spans over OTLP/HTTP to endpoint and returns a
+// function that flushes pending spans. It does nothing without an endpoint.
+func initTracing(endpoint string) (func(), error) {
+	if endpoint == "" {
+		return func() {}, nil
+	}
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(endpoint),
+		otlptracehttp.WithProxy(sharedTransport.Proxy))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("cronrunner"))),
+	)
+	otel.SetTracerProvider(tp)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), otelShutdownTimeout)
+		defer cancel()
+		_ = tp.Shutdown(ctx)
+	}, nil
+}
+
+// runTrace is the span covering one run, across all of its attempts.
+type runTrace struct {
+	ctx  context.Context
+	span trace.Span
+}
+
+// startRunTrace starts the span of a run; command is the command as it may
+// be exported, after CRON_REDACT_CMD_LOG.
+func startRunTrace(req runRequest, command string) *runTrace {
+	ctx, span := otel.Tracer("cronrunner").Start(context.Background(), "cron.run "+req.job,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("cron.job", req.job),
+			attribute.String("cron.run_id", req.runID),
+			attribute.String("cron.command", command),
+		),
+	)
+	return &runTrace{ctx: ctx, span: span}
+}
+
+// env returns the W3C trace context for the child, as TRACEPARENT and
+// TRACESTATE, so work it does can be attached to the run's span.
+func (t *runTrace) env() map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(t.ctx, carrier)
+	env := map[string]string{}
+	if v := carrier.Get("traceparent"); v != "" {
+		env["TRACEPARENT"] = v
+	}
+	if v := carrier.Get("tracestate"); v != "" {
+		env["TRACESTATE"] = v
+	}
+	sc := t.span.SpanContext()
+	if sc.IsValid() {
+		env["CRON_TRACE_ID"] = sc.TraceID().String()
+		env["CRON_SPAN_ID"] = sc.SpanID().String()
+	}
+	return env
+}
+
+// end records the outcome of the run and ends the span.
+func (t *runTrace) end(rec runRecord) {
+	t.span.SetAttributes(
+		attribute.Int("cron.exit_code", rec.ExitCode),
+		attribute.Int64("cron.duration_ms", rec.DurationMs),
+		attribute.Int("cron.attempts", rec.Attempts),
+		attribute.Bool("cron.killed", rec.Killed),
+	)
+	if rec.succeeded() {
+		t.span.SetStatus(codes.Ok, "")
+	} else {
+		t.span.SetStatus(codes.Error, rec.status())
+	}
+	t.span.End()
+}
@@ -0,0 +1,19 @@
+//go:build !otel
+
+package main
+
+// Tracing is only compiled in with -tags otel, keeping the OpenTelemetry
+// SDK out of the default binary.
+const otelSupported = false
+
+func initTracing(endpoint string) (func(), error) {
+	return func() {}, nil
+}
+
+type runTrace struct{}
+
+func startRunTrace(req runRequest, command string) *runTrace { return nil }
+
+func (t *runTrace) env() map[string]string { return nil }
+
+func (t *runTrace) end(rec runRecord) {}
@@ -0,0 +1,61 @@
+package main
+
+// OVERLAP_STRATEGY values: what a scheduled tick does while the previous
+// run is still going and ALLOW_CONCURRENT is off.
+const (
+	overlapDrop    = "drop"
+	overlapQueue   = "queue"
+	overlapReplace = "replace"
+)
+
+// overlap handles a tick that found the previous run still in progress.
+// drop skips it; queue remembers one pending run, which end starts as
+// soon as the current run has finished; replace stops the current run and
+// queues the new one in its place.
+func (r *runner) overlap() {
+	switch r.cfg.overlapStrategy {
+	case overlapQueue:
+		if r.queuePending(false) {
+			logf(LevelInfo, "Previous run still in progress; this run will start when it finishes")
+		} else {
+			logf(LevelInfo, "Previous run still in progress and a run is already queued; skipping this run")
+			r.countSkip(skipOverlap)
+		}
+	case overlapReplace:
+		if !r.queuePending(true) {
+			logf(LevelInfo, "Previous run is already being replaced; skipping this run")
+			r.countSkip(skipOverlap)
+			return
+		}
+		n := r.terminateActive()
+		logf(LevelWarn, "Previous run still in progress; stopping it (%d command(s)) to start this run", n)
+	default:
+		logf(LevelInfo, "Previous run still in progress; skipping this run")
+		r.countSkip(skipOverlap)
+	}
+}
+
+// queuePending marks a run as pending and reports whether one wasn't
+// already. With supersede the current run is told to stop restarting.
+func (r *runner) queuePending(supersede bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	queued := !r.pending
+	r.pending = true
+	if supersede {
+		r.superseded = true
+		select {
+		case r.interrupt <- struct{}{}:
+		default:
+		}
+	}
+	return queued
+}
+
+// isSuperseded reports whether OVERLAP_STRATEGY=replace has asked the
+// current run to give way.
+func (r *runner) isSuperseded() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.superseded
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyNotifier triggers a PagerDuty incident through the Events API
+// v2 when a run fails and, with PAGERDUTY_RESOLVE, resolves it once the
+// job succeeds again. Events share the dedup key cronrunner/<job>, so
+// repeated failures update one incident instead of opening new ones.
+type pagerDutyNotifier struct {
+	url        string
+	routingKey string
+	resolve    bool
+	source     string
+	client     *http.Client
+
+	// mu serializes events so a trigger and the resolve that follows it
+	// arrive in order. open records the jobs with an incident this
+	// process triggered, or may have: a job missing from it has not
+	// been seen since startup, and the first success after a restart
+	// resolves whatever an earlier process left open.
+	mu   sync.Mutex
+	open map[string]bool
+}
+
+func newPagerDutyNotifier(cfg *config) *pagerDutyNotifier {
+	source, err := os.Hostname()
+	if err != nil || source == "" {
+		source = "cronrunner"
+	}
+	return &pagerDutyNotifier{
+		url:        cfg.pagerDutyURL,
+		routingKey: cfg.pagerDutyKey,
+		resolve:    cfg.pagerDutyResolve,
+		source:     source,
+		client:     newHTTPClient(cfg.notifyHTTPTimeout),
+		open:       make(map[string]bool),
+	}
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string    `json:"summary"`
+	Source        string    `json:"source"`
+	Severity      string    `json:"severity"`
+	Timestamp     string    `json:"timestamp"`
+	Component     string    `json:"component"`
+	CustomDetails runRecord `json:"custom_details"`
+}
+
+// report sends the event before the run is considered finished, bounded
+// by NOTIFY_HTTP_TIMEOUT_SEC. Failures are logged and never affect the
+// run's outcome.
+func (p *pagerDutyNotifier) report(rec runRecord) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	event := pagerDutyEvent{RoutingKey: p.routingKey, DedupKey: "cronrunner/" + rec.Job}
+	if rec.succeeded() {
+		if open, seen := p.open[rec.Job]; !p.resolve || (seen && !open) {
+			p.open[rec.Job] = false
+			return
+		}
+		event.EventAction = "resolve"
+	} else {
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:       fmt.Sprintf("cronrunner job %s failed with exit code %d after %v", rec.Job, rec.ExitCode, time.Duration(rec.DurationMs)*time.Millisecond),
+			Source:        p.source,
+			Severity:      "error",
+			Timestamp:     rec.StartedAt.UTC().Format(time.RFC3339),
+			Component:     rec.Job,
+			CustomDetails: rec,
+		}
+	}
+	if p.send(rec.RunID, event) {
+		p.open[rec.Job] = event.EventAction == "trigger"
+	}
+}
+
+// send POSTs the event and reports whether PagerDuty accepted it.
+func (p *pagerDutyNotifier) send(runID string, event pagerDutyEvent) bool {
+	body, err := json.Marshal(event)
+	if err != nil {
+		logf(LevelError, "Failed to encode PagerDuty %s event for run %s: %v", event.EventAction, runID, err)
+		return false
+	}
+	resp, err := p.client.Post(p.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logf(LevelError, "Failed to send PagerDuty %s event for run %s: %v", event.EventAction, runID, err)
+		return false
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		logf(LevelWarn, "PagerDuty %s event for run %s returned %s", event.EventAction, runID, resp.Status)
+		return false
+	}
+	logf(LevelDebug, "Sent PagerDuty %s event for %s", event.EventAction, event.DedupKey)
+	return true
+}
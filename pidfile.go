@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"sync"
+)
+
+// pidFile maintains CRON_PID_FILE while a command is running. Only one run
+// owns the file at a time: if runs overlap, the later run leaves the file
+// pointing at the earlier one and logs that it was not written.
+type pidFile struct {
+	path string
+
+	mu    sync.Mutex
+	owner int
+}
+
+func newPIDFile(path string) *pidFile {
+	// A file left behind by a crashed runner no longer refers to a live child.
+	if _, err := os.Stat(path); err == nil {
+		logf(LevelInfo, "Removing stale CRON_PID_FILE '%s'", path)
+		_ = os.Remove(path)
+	}
+	return &pidFile{path: path}
+}
+
+// acquire records pid in the file unless another run already owns it.
+func (p *pidFile) acquire(pid int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.owner != 0 {
+		logf(LevelWarn, "CRON_PID_FILE already holds running PID %d; not recording PID %d", p.owner, pid)
+		return
+	}
+
+	if err := writeFileAtomic(p.path, []byte(strconv.Itoa(pid)+"\n")); err != nil {
+		logf(LevelError, "Failed to write CRON_PID_FILE '%s': %v", p.path, err)
+		return
+	}
+	p.owner = pid
+}
+
+// release removes the file if it was written for pid.
+func (p *pidFile) release(pid int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.owner != pid {
+		return
+	}
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+		logf(LevelError, "Failed to remove CRON_PID_FILE '%s': %v", p.path, err)
+	}
+	p.owner = 0
+}
+
+// close removes the file on shutdown regardless of which run owns it.
+func (p *pidFile) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.owner != 0 {
+		_ = os.Remove(p.path)
+		p.owner = 0
+	}
+}
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces secrets in the --print-config output.
+const redactedValue = "[REDACTED]"
+
+// printedConfig is the JSON form of the resolved configuration printed by
+// --print-config. Durations are Go duration strings, where 0s means the
+// limit is off, and sections for integrations that aren't configured are
+// left out.
+type printedConfig struct {
+	Schedule     printedSchedule     `json:"schedule"`
+	Command      printedCommand      `json:"command"`
+	Timeouts     printedTimeouts     `json:"timeouts"`
+	Runs         printedRuns         `json:"runs"`
+	Logging      printedLogging      `json:"logging"`
+	Environment  printedEnvironment  `json:"environment"`
+	Process      printedProcess      `json:"process"`
+	Integrations printedIntegrations `json:"integrations"`
+}
+
+type printedSchedule struct {
+	Expressions   []string `json:"expressions"`
+	Descriptions  []string `json:"descriptions,omitempty"`
+	File          string   `json:"file,omitempty"`
+	Parser        string   `json:"parser"`
+	Timezone      string   `json:"timezone"`
+	TimezoneAlias string   `json:"timezone_alias,omitempty"`
+	Jitter        string   `json:"jitter,omitempty"`
+	JitterMode    string   `json:"jitter_mode,omitempty"`
+	JitterOffset  string   `json:"jitter_offset,omitempty"`
+	InstanceID    string   `json:"instance_id,omitempty"`
+}
+
+type printedCommand struct {
+	Command    string   `json:"command,omitempty"`
+	File       string   `json:"file,omitempty"`
+	Argv       []string `json:"argv,omitempty"`
+	Shell      string   `json:"shell,omitempty"`
+	Condition  string   `json:"condition,omitempty"`
+	JobName    string   `json:"job_name"`
+	WorkDir    string   `json:"workdir,omitempty"`
+	Chroot     string   `json:"chroot,omitempty"`
+	TmpDir     bool     `json:"tmpdir"`
+	TmpDirBase string   `json:"tmpdir_base,omitempty"`
+	Stdin      string   `json:"stdin,omitempty"`
+	StdinFile  string   `json:"stdin_file,omitempty"`
+	PTY        bool     `json:"pty"`
+	Setsid     bool     `json:"setsid,omitempty"`
+}
+
+type printedTimeouts struct {
+	KillAfter        string `json:"kill_after"`
+	KillAfterSetting string `json:"kill_after_setting,omitempty"`
+	Attempt          string `json:"attempt"`
+	TotalDeadline    string `json:"total_deadline,omitempty"`
+	KillGrace        string `json:"kill_grace"`
+	WarnAfter        string `json:"warn_after,omitempty"`
+	Shutdown         string `json:"shutdown"`
+	Wait             string `json:"wait,omitempty"`
+	Condition        string `json:"condition,omitempty"`
+	HTTP             string `json:"http"`
+	Webhook          string `json:"webhook,omitempty"`
+	Pushgateway      string `json:"pushgateway,omitempty"`
+	S3               string `json:"s3,omitempty"`
+	Lambda           string `json:"lambda,omitempty"`
+	LivenessMaxAge   string `json:"liveness_max_age,omitempty"`
+}
+
+type printedRuns struct {
+	RestartOnFail          bool     `json:"restart_on_fail"`
+	RestartBackoff         string   `json:"restart_backoff,omitempty"`
+	RestartBackoffBase     string   `json:"restart_backoff_base,omitempty"`
+	RestartBackoffMax      string   `json:"restart_backoff_max,omitempty"`
+	RetryExitCodes         []int    `json:"retry_exit_codes,omitempty"`
+	NoRetryExitCodes       []int    `json:"no_retry_exit_codes,omitempty"`
+	AllowConcurrent        bool     `json:"allow_concurrent"`
+	OverlapStrategy        string   `json:"overlap_strategy"`
+	MaxRuns                int      `json:"max_runs,omitempty"`
+	MaxConsecutiveFailures int      `json:"max_consecutive_failures,omitempty"`
+	BreakerAction          string   `json:"breaker_action,omitempty"`
+	WaitFor                []string `json:"wait_for,omitempty"`
+}
+
+type printedLogging struct {
+	File           string `json:"file,omitempty"`
+	CreateDirs     bool   `json:"create_dirs,omitempty"`
+	Fsync          bool   `json:"fsync,omitempty"`
+	BufferSize     int    `json:"output_buffer_size,omitempty"`
+	FlushInterval  string `json:"output_flush_interval,omitempty"`
+	FileMode       string `json:"file_mode,omitempty"`
+	FileOwner      string `json:"file_owner,omitempty"`
+	MaxRunBytes    int64  `json:"max_run_bytes,omitempty"`
+	CapConsole     bool   `json:"cap_console,omitempty"`
+	RedactPatterns int    `json:"redact_patterns,omitempty"`
+	RedactCmdLog   string `json:"redact_cmd_log,omitempty"`
+	Level          string `json:"level"`
+	Separator      string `json:"separator"`
+	Color          string `json:"color"`
+	Syslog         bool   `json:"syslog"`
+	SyslogAddr     string `json:"syslog_addr,omitempty"`
+	SyslogFacility string `json:"syslog_facility,omitempty"`
+	SyslogTag      string `json:"syslog_tag,omitempty"`
+	PIDFile        string `json:"pid_file,omitempty"`
+	ExitCodeFile   string `json:"exitcode_file,omitempty"`
+	ExitCodeFormat string `json:"exitcode_format,omitempty"`
+	ExitCodeOutput bool   `json:"exit_code_output,omitempty"`
+	EventFile      string `json:"event_file,omitempty"`
+}
+
+type printedEnvironment struct {
+	Allowlist  []string          `json:"allowlist,omitempty"`
+	Blocklist  []string          `json:"blocklist,omitempty"`
+	Proxy      map[string]string `json:"proxy,omitempty"`
+	ClearProxy bool              `json:"clear_proxy,omitempty"`
+	CA         map[string]string `json:"ca,omitempty"`
+}
+
+type printedProcess struct {
+	User            string   `json:"user,omitempty"`
+	Group           string   `json:"group,omitempty"`
+	Groups          []string `json:"groups,omitempty"`
+	DropCaps        []string `json:"drop_caps,omitempty"`
+	NoNewPrivs      bool     `json:"no_new_privs,omitempty"`
+	NewNetns        bool     `json:"new_netns,omitempty"`
+	Netns           string   `json:"netns,omitempty"`
+	Seccomp         string   `json:"seccomp,omitempty"`
+	Nice            *int     `json:"nice,omitempty"`
+	IONice          string   `json:"ionice,omitempty"`
+	MemLimitMB      int      `json:"mem_limit_mb,omitempty"`
+	Rlimits         []string `json:"rlimits,omitempty"`
+	Umask           string   `json:"umask,omitempty"`
+	ForwardSignals  []string `json:"forward_signals,omitempty"`
+	ForwardShutdown bool     `json:"forward_shutdown"`
+}
+
+type printedIntegrations struct {
+	Enabled     []string            `json:"enabled"`
+	HTTPProxy   string              `json:"http_proxy,omitempty"`
+	Health      *printedHealth      `json:"health,omitempty"`
+	Webhook     *printedWebhook     `json:"webhook,omitempty"`
+	Slack       *printedSlack       `json:"slack,omitempty"`
+	PagerDuty   *printedPagerDuty   `json:"pagerduty,omitempty"`
+	Ping        *printedPing        `json:"healthcheck_ping,omitempty"`
+	StatsD      *printedStatsD      `json:"statsd,omitempty"`
+	Pushgateway *printedPushgateway `json:"pushgateway,omitempty"`
+	CloudWatch  *printedCloudWatch  `json:"cloudwatch,omitempty"`
+	S3          *printedS3          `json:"s3,omitempty"`
+	NATS        *printedNATS        `json:"nats,omitempty"`
+	K8sJob      *printedK8sJob      `json:"k8s_job,omitempty"`
+	Lambda      *printedLambda      `json:"lambda,omitempty"`
+	CloudRun    *printedCloudRun    `json:"cloud_run,omitempty"`
+	SSH         *printedSSH         `json:"ssh,omitempty"`
+	Vault       *printedVault       `json:"vault,omitempty"`
+	OTel        *printedOTel        `json:"otel,omitempty"`
+}
+
+type printedHealth struct {
+	Port           string `json:"port"`
+	APIToken       string `json:"api_token,omitempty"`
+	HistoryDB      string `json:"history_db,omitempty"`
+	HistoryMaxRows int    `json:"history_max_rows,omitempty"`
+}
+
+type printedWebhook struct {
+	URL       string `json:"url"`
+	CustomTLS bool   `json:"custom_tls"`
+	Warnings  bool   `json:"warnings"`
+}
+
+type printedSlack struct {
+	URL        string `json:"url"`
+	Channel    string `json:"channel,omitempty"`
+	Username   string `json:"username,omitempty"`
+	TailLines  int    `json:"tail_lines"`
+	HistoryURL string `json:"history_url,omitempty"`
+}
+
+type printedPagerDuty struct {
+	RoutingKey string `json:"routing_key"`
+	Resolve    bool   `json:"resolve"`
+	URL        string `json:"url"`
+}
+
+// printedPing masks the ping URLs, which identify the check and are all it
+// takes to ping it.
+type printedPing struct {
+	URL     string `json:"url,omitempty"`
+	FailURL string `json:"fail_url,omitempty"`
+}
+
+type printedStatsD struct {
+	Addr   string `json:"addr"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+type printedPushgateway struct {
+	URL      string `json:"url"`
+	Instance string `json:"instance,omitempty"`
+}
+
+type printedCloudWatch struct {
+	Namespace string `json:"namespace"`
+	Region    string `json:"region,omitempty"`
+}
+
+type printedS3 struct {
+	Bucket    string `json:"bucket"`
+	KeyPrefix string `json:"key_prefix,omitempty"`
+	Compress  bool   `json:"compress"`
+}
+
+type printedNATS struct {
+	URL           string `json:"url"`
+	Subject       string `json:"subject,omitempty"`
+	Durable       string `json:"durable,omitempty"`
+	MaxConcurrent int    `json:"max_concurrent,omitempty"`
+}
+
+type printedK8sJob struct {
+	Image          string `json:"image,omitempty"`
+	Namespace      string `json:"namespace,omitempty"`
+	ServiceAccount string `json:"service_account,omitempty"`
+	Keep           bool   `json:"keep"`
+}
+
+type printedLambda struct {
+	Function string `json:"function"`
+	Region   string `json:"region,omitempty"`
+}
+
+type printedCloudRun struct {
+	Project string `json:"project"`
+	Region  string `json:"region"`
+	Job     string `json:"job"`
+}
+
+type printedSSH struct {
+	Host       string `json:"host"`
+	User       string `json:"user"`
+	KeyFile    string `json:"key_file"`
+	KnownHosts string `json:"known_hosts,omitempty"`
+	Insecure   bool   `json:"insecure,omitempty"`
+}
+
+type printedVault struct {
+	SecretPath string `json:"secret_path"`
+	LeaseRenew bool   `json:"lease_renew"`
+}
+
+type printedOTel struct {
+	Endpoint string `json:"endpoint"`
+}
+
+// printConfig writes cfg to w as indented JSON for --print-config.
+func printConfig(w io.Writer, cfg *config) error {
+	b, err := json.MarshalIndent(newPrintedConfig(cfg), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func newPrintedConfig(cfg *config) printedConfig {
+	p := printedConfig{
+		Schedule: printedSchedule{
+			Expressions:   cfg.schedules,
+			File:          cfg.scheduleFile,
+			Parser:        cfg.parserName,
+			Timezone:      cfg.timezone,
+			TimezoneAlias: cfg.timezoneAlias,
+		},
+		Command: printedCommand{
+			Command:    cfg.command,
+			File:       cfg.cmdFile,
+			Argv:       cfg.argv,
+			Shell:      cfg.shell,
+			Condition:  cfg.condition,
+			JobName:    cfg.jobName,
+			WorkDir:    cfg.workDir,
+			Chroot:     cfg.chroot,
+			TmpDir:     cfg.tmpDir,
+			TmpDirBase: cfg.tmpDirBase,
+			StdinFile:  cfg.stdinFile,
+			PTY:        cfg.allocatePTY,
+			Setsid:     cfg.setsid,
+		},
+		Timeouts: printedTimeouts{
+			KillAfter:        cfg.killAfter.String(),
+			KillAfterSetting: cfg.killAfterVar,
+			Attempt:          cfg.attemptTimeout.String(),
+			TotalDeadline:    durationIfSet(cfg.totalDeadline),
+			KillGrace:        cfg.killGrace.String(),
+			WarnAfter:        durationIfSet(cfg.warnAfter),
+			Shutdown:         cfg.shutdownTimeout.String(),
+			HTTP:             cfg.notifyHTTPTimeout.String(),
+		},
+		Runs: printedRuns{
+			RestartOnFail:          cfg.restartOnFail,
+			RestartBackoff:         cfg.restartBackoff,
+			RestartBackoffBase:     durationIfSet(cfg.restartBackoffBase),
+			RestartBackoffMax:      durationIfSet(cfg.restartBackoffMax),
+			RetryExitCodes:         cfg.retryExitCodes,
+			NoRetryExitCodes:       cfg.noRetryExitCodes,
+			AllowConcurrent:        cfg.allowConcurrent,
+			OverlapStrategy:        cfg.overlapStrategy,
+			MaxRuns:                cfg.maxRuns,
+			MaxConsecutiveFailures: cfg.maxConsecutiveFailures,
+			WaitFor:                cfg.waitFor,
+		},
+		Logging: printedLogging{
+			File:           cfg.logFilePath,
+			CreateDirs:     cfg.logFilePath != "" && cfg.logCreateDirs,
+			Fsync:          cfg.logFsync,
+			BufferSize:     cfg.logBufferSize,
+			FlushInterval:  durationIfSet(cfg.logFlushInterval),
+			MaxRunBytes:    cfg.logMaxRunBytes,
+			CapConsole:     cfg.logCapConsole,
+			RedactPatterns: len(cfg.redactPatterns),
+			RedactCmdLog:   cfg.redactCmdLog,
+			Level:          logLevelName(cfg.logLevel),
+			Separator:      cfg.logSeparator,
+			Color:          cfg.logColor,
+			Syslog:         cfg.syslog,
+			SyslogFacility: cfg.syslogFacility,
+			SyslogTag:      cfg.syslogTag,
+			PIDFile:        cfg.pidFilePath,
+			ExitCodeFile:   cfg.exitCodePath,
+			ExitCodeFormat: cfg.exitCodeFmt,
+			ExitCodeOutput: cfg.exitCodeOutput,
+			EventFile:      cfg.eventFile,
+		},
+		Environment: printedEnvironment{
+			Allowlist:  cfg.envAllowlist,
+			Blocklist:  cfg.envBlocklist,
+			ClearProxy: cfg.clearProxy,
+			CA:         cfg.caEnv,
+		},
+		Process: printedProcess{
+			Groups:          cfg.groups,
+			DropCaps:        cfg.dropCapNames,
+			NoNewPrivs:      cfg.noNewPrivs,
+			NewNetns:        cfg.newNetns,
+			Netns:           cfg.netnsPath,
+			Seccomp:         cfg.seccompSource,
+			Nice:            cfg.nice,
+			MemLimitMB:      cfg.memLimitMB,
+			ForwardShutdown: cfg.forwardShutdown,
+		},
+	}
+	p.Schedule.Descriptions = describeSchedules(p.Schedule.Expressions, cfg.location)
+	if p.Schedule.Timezone == "" {
+		p.Schedule.Timezone = time.Local.String()
+	}
+	if len(cfg.stdinData) > 0 {
+		p.Command.Stdin = redactedValue
+	}
+	if cfg.logFileMode != 0 {
+		p.Logging.FileMode = fmt.Sprintf("%04o", cfg.logFileMode)
+	}
+	if o := cfg.logFileOwner; o != nil {
+		p.Logging.FileOwner = o.user + ":" + o.group
+	}
+
+	if cfg.jitter > 0 {
+		p.Schedule.Jitter = cfg.jitter.String()
+		p.Schedule.JitterMode = cfg.jitterMode
+		if cfg.jitterMode == jitterHost {
+			p.Schedule.JitterOffset = cfg.jitterOffset.String()
+			p.Schedule.InstanceID = cfg.instanceID
+		}
+	}
+	if cfg.maxConsecutiveFailures > 0 {
+		p.Runs.BreakerAction = cfg.breakerAction
+	}
+	if cfg.condition != "" {
+		p.Timeouts.Condition = cfg.conditionTimeout.String()
+	}
+	if len(cfg.waitFor) > 0 {
+		p.Timeouts.Wait = cfg.waitTimeout.String()
+	}
+	if cfg.syslogAddr != "" {
+		p.Logging.SyslogAddr = cfg.syslogNetwork + "://" + cfg.syslogAddr
+	}
+	if len(cfg.proxyEnv) > 0 {
+		p.Environment.Proxy = maps.Clone(cfg.proxyEnv)
+		for k, v := range p.Environment.Proxy {
+			p.Environment.Proxy[k] = redactURLSecret(v)
+		}
+	}
+
+	if cfg.runAs != nil {
+		p.Process.User = cfg.runAs.user
+		p.Process.Group = cfg.runAs.group
+	}
+	if cfg.ioPriority != nil {
+		p.Process.IONice = cfg.ioPriority.String()
+	}
+	for _, l := range cfg.rlimits {
+		p.Process.Rlimits = append(p.Process.Rlimits, l.String())
+	}
+	if cfg.umask != nil {
+		p.Process.Umask = fmt.Sprintf("%04o", *cfg.umask)
+	}
+	for _, sig := range cfg.forwardSignals {
+		p.Process.ForwardSignals = append(p.Process.ForwardSignals, signalName(sig))
+	}
+
+	in := &p.Integrations
+	in.Enabled = []string{}
+	enable := func(name string) { in.Enabled = append(in.Enabled, name) }
+	if cfg.httpProxy != "" {
+		in.HTTPProxy = redactURLSecret(cfg.httpProxy)
+	}
+	if cfg.syslog {
+		enable("syslog")
+	}
+	if cfg.healthPort != "" {
+		enable("health")
+		in.Health = &printedHealth{
+			Port:           cfg.healthPort,
+			HistoryDB:      cfg.historyDBPath,
+			HistoryMaxRows: cfg.historyMaxRows,
+		}
+		if cfg.healthAPIToken != "" {
+			in.Health.APIToken = redactedValue
+		}
+		p.Timeouts.LivenessMaxAge = durationIfSet(cfg.livenessMaxAge)
+	}
+	if cfg.webhookURL != "" {
+		enable("webhook")
+		in.Webhook = &printedWebhook{URL: redactURLSecret(cfg.webhookURL), CustomTLS: cfg.webhookTLS != nil, Warnings: cfg.warnWebhook}
+		p.Timeouts.Webhook = cfg.webhookTimeout.String()
+	}
+	if cfg.slackURL != "" {
+		// The webhook URL is the credential.
+		enable("slack")
+		in.Slack = &printedSlack{URL: redactedValue, Channel: cfg.slackChannel, Username: cfg.slackUsername, TailLines: cfg.slackTailLines, HistoryURL: cfg.slackHistoryURL}
+	}
+	if cfg.pagerDutyKey != "" {
+		enable("pagerduty")
+		in.PagerDuty = &printedPagerDuty{RoutingKey: redactedValue, Resolve: cfg.pagerDutyResolve, URL: redactURLSecret(cfg.pagerDutyURL)}
+	}
+	if cfg.pingURL != "" || cfg.pingFailURL != "" {
+		enable("healthcheck_ping")
+		in.Ping = &printedPing{}
+		if cfg.pingURL != "" {
+			in.Ping.URL = redactedValue
+		}
+		if cfg.pingFailURL != "" {
+			in.Ping.FailURL = redactedValue
+		}
+	}
+	if cfg.statsdAddr != "" {
+		enable("statsd")
+		in.StatsD = &printedStatsD{Addr: cfg.statsdAddr, Prefix: cfg.statsdPrefix}
+	}
+	if cfg.pushgatewayURL != "" {
+		enable("pushgateway")
+		in.Pushgateway = &printedPushgateway{URL: redactURLSecret(cfg.pushgatewayURL), Instance: cfg.pushgatewayInstance}
+		p.Timeouts.Pushgateway = cfg.pushgatewayTimeout.String()
+	}
+	if cfg.cloudWatchNamespace != "" {
+		enable("cloudwatch")
+		in.CloudWatch = &printedCloudWatch{Namespace: cfg.cloudWatchNamespace, Region: cfg.awsRegion}
+	}
+	if cfg.s3Bucket != "" {
+		enable("s3")
+		in.S3 = &printedS3{Bucket: cfg.s3Bucket, KeyPrefix: cfg.s3KeyPrefix, Compress: cfg.s3Compress}
+		p.Timeouts.S3 = cfg.s3Timeout.String()
+	}
+	if cfg.natsURL != "" {
+		enable("nats")
+		in.NATS = &printedNATS{
+			URL:           redactURLSecret(cfg.natsURL),
+			Subject:       cfg.natsSubject,
+			Durable:       cfg.natsDurable,
+			MaxConcurrent: cfg.natsMaxConcurrent,
+		}
+	}
+	if cfg.k8sJobMode {
+		enable("k8s_job")
+		in.K8sJob = &printedK8sJob{
+			Image:          cfg.k8sJobImage,
+			Namespace:      cfg.k8sJobNamespace,
+			ServiceAccount: cfg.k8sJobServiceAccount,
+			Keep:           cfg.k8sJobKeep,
+		}
+	}
+	if cfg.lambdaFunctionName != "" {
+		enable("lambda")
+		in.Lambda = &printedLambda{Function: cfg.lambdaFunctionName, Region: cfg.awsRegion}
+		p.Timeouts.Lambda = cfg.lambdaTimeout.String()
+	}
+	if cfg.cloudRunJob != "" {
+		enable("cloud_run")
+		in.CloudRun = &printedCloudRun{Project: cfg.gcpProject, Region: cfg.gcpRegion, Job: cfg.cloudRunJob}
+	}
+	if cfg.sshHost != "" {
+		enable("ssh")
+		in.SSH = &printedSSH{Host: cfg.sshHost, User: cfg.sshUser, KeyFile: cfg.sshKeyFile, KnownHosts: cfg.sshKnownHosts, Insecure: cfg.sshInsecure}
+	}
+	if cfg.vaultSecretPath != "" {
+		enable("vault")
+		in.Vault = &printedVault{SecretPath: cfg.vaultSecretPath, LeaseRenew: cfg.vaultLeaseRenew}
+	}
+	if cfg.otelEndpoint != "" {
+		enable("otel")
+		in.OTel = &printedOTel{Endpoint: redactURLSecret(cfg.otelEndpoint)}
+	}
+	return p
+}
+
+// effectiveConfig is the configuration served on GET /config: the
+// startup configuration with the schedule and command currently in use,
+// which a reload may have changed, and the command masked as
+// CRON_REDACT_CMD_LOG says.
+func (r *runner) effectiveConfig() printedConfig {
+	cfg := r.cfg
+	p := newPrintedConfig(cfg)
+
+	r.reloadMu.Lock()
+	if r.spec != "" {
+		p.Schedule.Expressions = strings.Split(r.spec, scheduleSeparator+" ")
+		p.Schedule.Descriptions = describeSchedules(p.Schedule.Expressions, cfg.location)
+	}
+	r.reloadMu.Unlock()
+	if r.cmdFile != nil {
+		p.Command.Command = r.cmdFile.current()
+		p.Command.Argv = commandArgs(p.Command.Command, cfg.shell)
+	}
+
+	if cfg.redactCmdLog != "" {
+		p.Command.Command = cfg.displayCommand(p.Command.Command)
+		p.Command.Argv = nil
+		p.Command.Condition = cfg.displayCommand(p.Command.Condition)
+	}
+	return p
+}
+
+func durationIfSet(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// redactURLSecret hides the user information of a URL, which may hold a
+// password or token. Values that don't parse as URLs are returned as is.
+func redactURLSecret(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	u.User = nil
+	return u.Scheme + "://" + redactedValue + "@" + strings.TrimPrefix(u.String(), u.Scheme+"://")
+}
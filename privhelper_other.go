@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// wrapPrivileges is a no-op: the settings the helper applies on Unix are
+// rejected at startup on Windows.
+func wrapPrivileges(cfg *config, cmd *exec.Cmd) {}
+
+func runPrivilegeHelper() {}
@@ -0,0 +1,302 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// privHelperArg marks cronrunner re-executed as the pre-exec helper. Go
+// can't run code between fork and exec, so the settings that have to be in
+// place when the command starts are made by cronrunner itself, started in
+// the command's place: it sets up the process and then execs the real
+// command in place, keeping the PID. These are CRON_UMASK, CRON_NICE,
+// CRON_IONICE_*, CRON_MEM_LIMIT_MB and CRON_RLIMIT_*, and on Linux
+// CRON_DROP_CAPS, CRON_NO_NEW_PRIVS, CRON_NETNS_PATH, CRON_NEW_NETNS and
+// the seccomp filter.
+const privHelperArg = "__cronrunner-drop-privileges"
+
+// wrapPrivileges rewrites cmd to start through the helper when any of those
+// settings is configured. A credential from CRON_RUN_AS_USER and the
+// CRON_CHROOT root are moved into the helper too: dropping capabilities,
+// negative niceness and raising limits need the privileges that switching
+// users gives up, and the helper itself lives outside the chroot.
+func wrapPrivileges(cfg *config, cmd *exec.Cmd) {
+	if !needsPrivilegeHelper(cfg) || cmd.Err != nil {
+		return
+	}
+	exe, err := helperExecutable()
+	if err != nil {
+		cmd.Err = fmt.Errorf("failed to locate cronrunner to start the command through: %w", err)
+		return
+	}
+	args := []string{cmd.Args[0], privHelperArg}
+	if cfg.umask != nil {
+		args = append(args, "-umask", fmt.Sprintf("%04o", *cfg.umask))
+	}
+	if cfg.nice != nil {
+		args = append(args, "-nice", strconv.Itoa(*cfg.nice))
+	}
+	if p := cfg.ioPriority; p != nil {
+		args = append(args, "-ionice", joinInts([]int{p.class, p.level}))
+	}
+	for _, l := range cfg.rlimits {
+		args = append(args, "-rlimit", l.resource+"="+strconv.FormatUint(l.value, 10))
+	}
+	if cfg.memLimitMB > 0 {
+		args = append(args, "-rlimit", "AS="+strconv.FormatUint(uint64(cfg.memLimitMB)<<20, 10))
+	}
+	if cfg.seccompFilter != "" {
+		args = append(args, "-seccomp", cfg.seccompFilter)
+	}
+	if cfg.netnsPath != "" {
+		args = append(args, "-netns", cfg.netnsPath)
+	}
+	if cfg.newNetns {
+		args = append(args, "-lo-up")
+	}
+	if len(cfg.dropCaps) > 0 {
+		args = append(args, "-caps", joinInts(cfg.dropCaps))
+	}
+	if cfg.noNewPrivs {
+		args = append(args, "-no-new-privs")
+	}
+	if cred := cmd.SysProcAttr.Credential; cred != nil {
+		groups := make([]int, len(cred.Groups))
+		for i, g := range cred.Groups {
+			groups[i] = int(g)
+		}
+		args = append(args, "-uid", strconv.Itoa(int(cred.Uid)), "-gid", strconv.Itoa(int(cred.Gid)), "-groups", joinInts(groups))
+		cmd.SysProcAttr.Credential = nil
+	}
+	if root := cmd.SysProcAttr.Chroot; root != "" {
+		args = append(args, "-chroot", root, "-dir", cmd.Dir)
+		cmd.SysProcAttr.Chroot = ""
+		cmd.Dir = ""
+	}
+	cmd.Args = append(append(args, "--", cmd.Path), cmd.Args...)
+	cmd.Path = exe
+}
+
+func needsPrivilegeHelper(cfg *config) bool {
+	return cfg.umask != nil || cfg.nice != nil || cfg.ioPriority != nil || len(cfg.rlimits) > 0 || cfg.memLimitMB > 0 ||
+		len(cfg.dropCaps) > 0 || cfg.noNewPrivs || cfg.netnsPath != "" || cfg.newNetns || cfg.seccompFilter != ""
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+// runPrivilegeHelper runs the helper side of wrapPrivileges when cronrunner
+// was started as one, and never returns in that case. Failures exit 126,
+// like a command that can't be executed.
+func runPrivilegeHelper() {
+	if len(os.Args) < 2 || os.Args[1] != privHelperArg {
+		return
+	}
+	// Capabilities, no_new_privs, niceness and the I/O priority are per
+	// thread on Linux; everything, including the final exec, has to happen
+	// on this one.
+	runtime.LockOSThread()
+	if err := setUpAndExec(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "cronrunner: %v\n", err)
+		if errors.Is(err, syscall.ENOENT) {
+			os.Exit(exitNotFound)
+		}
+		os.Exit(exitNotExecutable)
+	}
+}
+
+// helperOptions are the settings wrapPrivileges hands to the helper.
+type helperOptions struct {
+	umask      int
+	nice       *int
+	ioPriority *ioPriority
+	rlimits    []rlimit
+	uid, gid   int
+	groups     []int
+	root, dir  string
+
+	// Linux only.
+	caps       []int
+	netns      string
+	seccomp    string
+	noNewPrivs bool
+	loUp       bool
+}
+
+func parseHelperArgs(args []string) (*helperOptions, []string, error) {
+	o := &helperOptions{umask: -1, uid: -1, gid: -1}
+	for len(args) > 0 && args[0] != "--" {
+		flag := args[0]
+		switch flag {
+		case "-no-new-privs":
+			o.noNewPrivs = true
+			args = args[1:]
+			continue
+		case "-lo-up":
+			o.loUp = true
+			args = args[1:]
+			continue
+		}
+		if len(args) < 2 {
+			return nil, nil, fmt.Errorf("missing value for %s", flag)
+		}
+		v := args[1]
+		args = args[2:]
+		var err error
+		switch flag {
+		case "-umask":
+			var n int64
+			n, err = strconv.ParseInt(v, 8, 32)
+			o.umask = int(n)
+		case "-nice":
+			var n int
+			n, err = strconv.Atoi(v)
+			o.nice = &n
+		case "-ionice":
+			var ns []int
+			if ns, err = splitInts(v); err == nil && len(ns) != 2 {
+				err = fmt.Errorf("invalid I/O priority %s", v)
+			}
+			if err == nil {
+				o.ioPriority = &ioPriority{class: ns[0], level: ns[1]}
+			}
+		case "-rlimit":
+			res, value, _ := strings.Cut(v, "=")
+			var n uint64
+			if n, err = strconv.ParseUint(value, 10, 64); err == nil && !slices.Contains(rlimitResources, res) {
+				err = fmt.Errorf("unknown resource limit %s", res)
+			}
+			o.rlimits = append(o.rlimits, rlimit{resource: res, value: n})
+		case "-caps":
+			o.caps, err = splitInts(v)
+		case "-uid":
+			o.uid, err = strconv.Atoi(v)
+		case "-gid":
+			o.gid, err = strconv.Atoi(v)
+		case "-groups":
+			o.groups, err = splitInts(v)
+		case "-netns":
+			o.netns = v
+		case "-seccomp":
+			o.seccomp = v
+		case "-chroot":
+			o.root = v
+		case "-dir":
+			o.dir = v
+		default:
+			err = fmt.Errorf("unknown flag %s", flag)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(args) < 3 {
+		return nil, nil, fmt.Errorf("missing command")
+	}
+	return o, args[1:], nil
+}
+
+func setUpAndExec(args []string) error {
+	o, command, err := parseHelperArgs(args)
+	if err != nil {
+		return fmt.Errorf("privilege helper: %v", err)
+	}
+	path, argv := command[0], command[1:]
+
+	// The namespace is set up first: the path lives outside the chroot,
+	// and both need privileges that are dropped below.
+	if err := enterNetns(o); err != nil {
+		return err
+	}
+	if o.root != "" {
+		if err := syscall.Chroot(o.root); err != nil {
+			return fmt.Errorf("failed to chroot to %s: %w", o.root, err)
+		}
+		dir := o.dir
+		if dir == "" {
+			dir = "/"
+		}
+		if err := syscall.Chdir(dir); err != nil {
+			return fmt.Errorf("failed to change to %s: %w", dir, err)
+		}
+	}
+	if o.umask >= 0 {
+		syscall.Umask(o.umask)
+	}
+	// Negative niceness, the realtime I/O class and raising a hard limit
+	// need privileges, so these come before the user is switched.
+	if o.nice != nil {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, *o.nice); err != nil {
+			return fmt.Errorf("failed to set niceness %d: %w", *o.nice, err)
+		}
+	}
+	if p := o.ioPriority; p != nil {
+		if err := setIOPriority(p); err != nil {
+			return fmt.Errorf("failed to set I/O priority %s: %w", p, err)
+		}
+	}
+	for _, l := range o.rlimits {
+		if err := raiseHardRlimit(l); err != nil {
+			return fmt.Errorf("failed to set resource limit %s: %w", l, err)
+		}
+	}
+	if err := restrictBeforeSwitch(o); err != nil {
+		return err
+	}
+	if o.uid >= 0 {
+		if err := syscall.Setgroups(o.groups); err != nil {
+			return fmt.Errorf("failed to set groups: %w", err)
+		}
+		if err := syscall.Setgid(o.gid); err != nil {
+			return fmt.Errorf("failed to set gid %d: %w", o.gid, err)
+		}
+		if err := syscall.Setuid(o.uid); err != nil {
+			return fmt.Errorf("failed to set uid %d: %w", o.uid, err)
+		}
+	}
+	if err := restrictAfterSwitch(o); err != nil {
+		return err
+	}
+	// The limits bind the helper as well, and the Go runtime fails once it
+	// can't map more memory under RLIMIT_AS, so they are set last, before
+	// only the seccomp filter.
+	env := os.Environ()
+	for _, l := range o.rlimits {
+		if err := setRlimit(l); err != nil {
+			return fmt.Errorf("failed to set resource limit %s: %w", l, err)
+		}
+	}
+	if err := lockDown(o); err != nil {
+		return err
+	}
+	return syscall.Exec(path, argv, env)
+}
+
+func splitInts(s string) ([]int, error) {
+	var ns []int
+	for _, f := range strings.Split(s, ",") {
+		if f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
@@ -0,0 +1,53 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	runAsSupported = true
+	niceSupported  = true
+	umaskSupported = true
+)
+
+// sysProcAttr builds the process attributes applied to each child.
+func sysProcAttr(cfg *config, argv []string) *syscall.SysProcAttr {
+	// The command leads its own process group, so that a signal forwarded
+	// to it also reaches the processes it starts.
+	attr := &syscall.SysProcAttr{Chroot: cfg.chroot, Setpgid: true}
+	if cfg.setsid {
+		// CRON_SETSID: a new session, without a controlling terminal, is
+		// also a new process group led by the command, so group signals
+		// work the same. Setpgid on top of it would fail.
+		attr.Setpgid = false
+		attr.Setsid = true
+	}
+	if cfg.runAs != nil {
+		attr.Credential = &syscall.Credential{Uid: cfg.runAs.uid, Gid: cfg.runAs.gid}
+	}
+	if len(cfg.groupIDs) > 0 {
+		if attr.Credential == nil {
+			attr.Credential = &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+		}
+		attr.Credential.Groups = cfg.groupIDs
+	}
+	netnsAttr(cfg, attr)
+	return attr
+}
+
+// signalGroup sends sig to the process group led by p. A command started
+// with CRON_ALLOCATE_PTY leads its own session, and with it a group of the
+// same ID.
+func signalGroup(p *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	if err := syscall.Kill(-p.Pid, s); err != nil {
+		return p.Signal(sig)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	runAsSupported = false
+	niceSupported  = false
+	umaskSupported = false
+)
+
+// sysProcAttr builds the process attributes applied to each child. cmd.exe
+// doesn't parse its command line with the quoting rules exec applies to
+// arguments, so "cmd /c <command>" is passed through verbatim instead.
+func sysProcAttr(cfg *config, argv []string) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{}
+	if len(argv) == 3 && shellKind(argv[0]) == shellCmd && strings.EqualFold(argv[1], "/c") {
+		attr.CmdLine = syscall.EscapeArg(argv[0]) + " /c " + argv[2]
+	}
+	return attr
+}
+
+// signalGroup signals p alone; Windows has no process groups to signal.
+func signalGroup(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
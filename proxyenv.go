@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// proxyVarNames are the variables CRON_CLEAR_PROXY removes from the
+// command's environment, in both the lower- and upper-case spellings tools
+// look for.
+var proxyVarNames = []string{
+	"http_proxy", "HTTP_PROXY", "https_proxy", "HTTPS_PROXY",
+	"all_proxy", "ALL_PROXY", "ftp_proxy", "FTP_PROXY", "no_proxy", "NO_PROXY",
+}
+
+// loadProxyEnv reads CRON_HTTP_PROXY, CRON_HTTPS_PROXY and CRON_NO_PROXY
+// into the variables set for the command. They only affect the command,
+// not cronrunner's own HTTP clients.
+func loadProxyEnv() (map[string]string, error) {
+	env := map[string]string{}
+	for _, p := range []struct{ setting, name string }{
+		{"CRON_HTTP_PROXY", "http_proxy"},
+		{"CRON_HTTPS_PROXY", "https_proxy"},
+		{"CRON_NO_PROXY", "no_proxy"},
+	} {
+		v := strings.TrimSpace(os.Getenv(p.setting))
+		if v == "" {
+			continue
+		}
+		if p.name != "no_proxy" {
+			if _, err := url.Parse(v); err != nil {
+				return nil, fmt.Errorf("Invalid %s: %v", p.setting, err)
+			}
+		}
+		env[p.name] = v
+		env[strings.ToUpper(p.name)] = v
+	}
+	return env, nil
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+)
+
+const ptySupported = false
+
+type ptySession struct{}
+
+func attachPTY(cfg *config, cmd *exec.Cmd, out io.Writer) (*ptySession, error) {
+	return nil, errors.New("PTY allocation is not supported on this platform")
+}
+
+func (p *ptySession) started() {}
+
+func (p *ptySession) close() {}
@@ -0,0 +1,84 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+const ptySupported = true
+
+// ptyDrainTimeout bounds how long a finished run waits for the rest of the
+// PTY output. Without it a background process still holding the terminal
+// open would keep the run alive.
+const ptyDrainTimeout = 2 * time.Second
+
+// ptySession is the terminal a child runs attached to with
+// CRON_ALLOCATE_PTY. The child's stdout and stderr both go to the terminal,
+// so they reach out as a single stream.
+type ptySession struct {
+	master *os.File
+	tty    *os.File
+	done   chan struct{}
+}
+
+// attachPTY opens a terminal of the configured size and wires cmd to it,
+// making it the child's controlling terminal in a new session. Stdin stays
+// as configured when CRON_STDIN or CRON_STDIN_FILE is set. The master is
+// copied to out until the child closes the terminal.
+func attachPTY(cfg *config, cmd *exec.Cmd, out io.Writer) (*ptySession, error) {
+	master, tty, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	if err := pty.Setsize(master, &pty.Winsize{Cols: cfg.ptyCols, Rows: cfg.ptyRows}); err != nil {
+		_ = master.Close()
+		_ = tty.Close()
+		return nil, err
+	}
+
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	ctty := 1
+	if cmd.Stdin == nil {
+		cmd.Stdin = tty
+		ctty = 0
+	}
+	// A new session also starts a new process group; asking for one on
+	// top of it would fail.
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = ctty
+
+	p := &ptySession{master: master, tty: tty, done: make(chan struct{})}
+	go func() {
+		// Reading the master fails with EIO once every process has closed
+		// the terminal, which is the normal end of output.
+		_, _ = io.Copy(out, master)
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// started releases the parent's copy of the terminal so the master sees the
+// end of output when the child exits. It is also called when the start
+// fails.
+func (p *ptySession) started() {
+	_ = p.tty.Close()
+}
+
+// close waits for the remaining output, up to ptyDrainTimeout, and closes
+// the master.
+func (p *ptySession) close() {
+	select {
+	case <-p.done:
+	case <-time.After(ptyDrainTimeout):
+	}
+	_ = p.master.Close()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushgatewayReporter pushes the runner's metrics to a Prometheus
+// Pushgateway after every run, for instances that may exit before a scrape
+// would ever see them. Each push replaces the metrics previously pushed
+// under the same job/instance grouping key.
+type pushgatewayReporter struct {
+	pusher *push.Pusher
+
+	runs          *prometheus.CounterVec
+	duration      prometheus.Gauge
+	exitCode      prometheus.Gauge
+	lastRun       prometheus.Gauge
+	lastSuccess   prometheus.Gauge
+	lastAttempts  prometheus.Gauge
+	lastTimedOut  prometheus.Gauge
+	lastSucceeded prometheus.Gauge
+}
+
+func newPushgatewayReporter(url, job, instance string, timeout time.Duration) *pushgatewayReporter {
+	p := &pushgatewayReporter{
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cronrunner_runs_total",
+			Help: "Completed runs since cronrunner started, by status.",
+		}, []string{"status"}),
+		duration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cronrunner_last_run_duration_seconds",
+			Help: "Duration of the most recent run.",
+		}),
+		exitCode: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cronrunner_last_run_exit_code",
+			Help: "Exit code of the most recent run.",
+		}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cronrunner_last_run_timestamp_seconds",
+			Help: "Start time of the most recent run.",
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cronrunner_last_success_timestamp_seconds",
+			Help: "Start time of the most recent successful run.",
+		}),
+		lastAttempts: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cronrunner_last_run_attempts",
+			Help: "Number of attempts made by the most recent run.",
+		}),
+		lastTimedOut: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cronrunner_last_run_timed_out",
+			Help: "1 if the most recent run was killed by a timeout.",
+		}),
+		lastSucceeded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cronrunner_last_run_success",
+			Help: "1 if the most recent run succeeded.",
+		}),
+	}
+	p.pusher = push.New(url, job).
+		Grouping("instance", instance).
+		Client(newHTTPClient(timeout)).
+		Collector(p.runs).
+		Collector(p.duration).
+		Collector(p.exitCode).
+		Collector(p.lastRun).
+		Collector(p.lastSuccess).
+		Collector(p.lastAttempts).
+		Collector(p.lastTimedOut).
+		Collector(p.lastSucceeded)
+	return p
+}
+
+// defaultPushgatewayInstance is the hostname, which is the container or pod
+// name in most deployments.
+func defaultPushgatewayInstance() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "cronrunner"
+	}
+	return host
+}
+
+func (p *pushgatewayReporter) report(rec runRecord) {
+	p.runs.WithLabelValues(rec.status()).Inc()
+	p.duration.Set(float64(rec.DurationMs) / 1000)
+	p.exitCode.Set(float64(rec.ExitCode))
+	p.lastRun.Set(float64(rec.StartedAt.Unix()))
+	p.lastAttempts.Set(float64(rec.Attempts))
+	p.lastTimedOut.Set(boolGauge(rec.Killed))
+	p.lastSucceeded.Set(boolGauge(rec.succeeded()))
+	if rec.succeeded() {
+		p.lastSuccess.Set(float64(rec.StartedAt.Unix()))
+	}
+
+	if err := p.pusher.Push(); err != nil {
+		logf(LevelError, "Failed to push metrics to Pushgateway for run %s: %v", rec.RunID, err)
+	}
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
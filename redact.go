@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redactedOutput replaces every CRON_REDACT_PATTERNS match in the output.
+const redactedOutput = "***"
+
+// Values accepted by CRON_REDACT_CMD_LOG.
+const (
+	redactCmdName     = "name"
+	redactCmdPatterns = "patterns"
+)
+
+// maxRedactLine bounds how much of a line without a newline is held back
+// before it is redacted and written anyway.
+const maxRedactLine = 64 << 10
+
+// parseRedactPatterns compiles CRON_REDACT_PATTERNS, one regular
+// expression per line, so that patterns may contain commas.
+func parseRedactPatterns(v string) ([]*regexp.Regexp, error) {
+	var out []*regexp.Regexp
+	for _, line := range strings.Split(v, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// redactor masks CRON_REDACT_PATTERNS in the output of one attempt, for
+// every writer drawn from it. Matching is done a line at a time, so a
+// secret split across two writes is still found.
+type redactor struct {
+	patterns []*regexp.Regexp
+	writers  []*redactWriter
+}
+
+// newRedactor returns nil when there are no patterns.
+func newRedactor(patterns []*regexp.Regexp) *redactor {
+	if len(patterns) == 0 {
+		return nil
+	}
+	return &redactor{patterns: patterns}
+}
+
+// writer returns an io.Writer that redacts output before forwarding it
+// to w. A nil redactor returns w unchanged.
+func (r *redactor) writer(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	rw := &redactWriter{r: r, w: w}
+	r.writers = append(r.writers, rw)
+	return rw
+}
+
+// flush writes out the last, unterminated line of every writer once the
+// command has exited.
+func (r *redactor) flush() {
+	if r == nil {
+		return
+	}
+	for _, rw := range r.writers {
+		rw.flush()
+	}
+}
+
+func (r *redactor) redact(line []byte) []byte {
+	for _, re := range r.patterns {
+		line = re.ReplaceAllLiteral(line, []byte(redactedOutput))
+	}
+	return line
+}
+
+type redactWriter struct {
+	r   *redactor
+	w   io.Writer
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	rw.buf = append(rw.buf, p...)
+	var out []byte
+	for {
+		i := bytes.IndexByte(rw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		out = append(out, rw.r.redact(rw.buf[:i])...)
+		out = append(out, '\n')
+		rw.buf = rw.buf[i+1:]
+	}
+	if len(rw.buf) > maxRedactLine {
+		out = append(out, rw.r.redact(rw.buf)...)
+		rw.buf = nil
+	}
+	rw.buf = append([]byte(nil), rw.buf...)
+	if len(out) == 0 {
+		return len(p), nil
+	}
+	if _, err := rw.w.Write(out); err != nil {
+		return len(p), err
+	}
+	return len(p), nil
+}
+
+func (rw *redactWriter) flush() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if len(rw.buf) > 0 {
+		_, _ = rw.w.Write(rw.r.redact(rw.buf))
+		rw.buf = nil
+	}
+}
+
+// displayCommand is command as it may be logged or exported, after
+// CRON_REDACT_CMD_LOG: with name only its first word is kept, with
+// patterns CRON_REDACT_PATTERNS are applied to it.
+func (cfg *config) displayCommand(command string) string {
+	switch cfg.redactCmdLog {
+	case redactCmdName:
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return command
+		}
+		// A leading VAR=value assignment may be the secret itself.
+		name := fields[0]
+		if strings.Contains(name, "=") {
+			name = redactedOutput
+		}
+		if len(fields) > 1 {
+			name += " " + redactedOutput
+		}
+		return name
+	case redactCmdPatterns:
+		return string((&redactor{patterns: cfg.redactPatterns}).redact([]byte(command)))
+	}
+	return command
+}
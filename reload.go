@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+)
+
+// errNothingToReload is returned by reload when neither the schedule nor the
+// command comes from a file: environment variables can't change while
+// cronrunner runs.
+var errNothingToReload = errors.New("nothing to reload: CRON_EXPRESSION and CRON_CMD come from the environment")
+
+// errNotScheduled is returned by setSchedule in NATS queue mode, where runs
+// come from messages instead of a schedule.
+var errNotScheduled = errors.New("nothing is scheduled in NATS queue mode")
+
+// scheduleError is a schedule rejected by setSchedule.
+type scheduleError struct{ err error }
+
+func (e *scheduleError) Error() string { return e.err.Error() }
+
+// addSchedules registers the run on the scheduler once per schedule. All
+// entries call r.run, so the overlap guard covers them together. If one
+// can't be added, the ones added so far are removed again.
+func (r *runner) addSchedules(specs []string) error {
+	ids := make([]cron.EntryID, 0, len(specs))
+	for _, spec := range specs {
+		sched, err := r.cfg.parseSchedule(spec)
+		if err != nil {
+			r.removeSchedules(ids)
+			return fmt.Errorf("'%s': %v", spec, err)
+		}
+		ids = append(ids, r.sched.Schedule(sched, cron.FuncJob(r.run)))
+	}
+	r.entryIDs, r.spec = ids, strings.Join(specs, scheduleSeparator+" ")
+	return nil
+}
+
+func (r *runner) removeSchedules(ids []cron.EntryID) {
+	for _, id := range ids {
+		r.sched.Remove(id)
+	}
+}
+
+// reload re-reads CRON_EXPRESSION_FILE and CRON_CMD_FILE, on SIGHUP or
+// POST /reload. A changed schedule replaces the scheduler entry; runs in
+// progress finish with what they started with. If the new schedule or
+// command is invalid, the error is logged and returned and the old one
+// stays in place.
+func (r *runner) reload(source string) error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	scheduled := r.cfg.scheduleFile != "" && r.cfg.natsURL == ""
+	if !scheduled && r.cmdFile == nil {
+		logf(LevelWarn, "Reload requested (%s), but %v", source, errNothingToReload)
+		return errNothingToReload
+	}
+	logf(LevelInfo, "Reloading configuration (%s)", source)
+
+	if scheduled {
+		next := &config{}
+		if err := loadSchedule(next); err != nil {
+			logf(LevelError, "Reload failed, keeping schedule '%s': %v", r.spec, err)
+			return err
+		}
+		if next.schedule != r.spec {
+			old, oldSpec := r.entryIDs, r.spec
+			if err := r.addSchedules(next.schedules); err != nil {
+				logf(LevelError, "Reload failed, keeping schedule '%s': %v", oldSpec, err)
+				return err
+			}
+			r.removeSchedules(old)
+			logf(LevelInfo, "Schedule changed from '%s' to '%s'", oldSpec, r.spec)
+		} else {
+			logf(LevelInfo, "Schedule unchanged: %s", r.spec)
+		}
+	}
+	if r.cmdFile != nil {
+		if _, err := r.cmdFile.reload(); err != nil {
+			logf(LevelError, "Reload failed, keeping the previous command: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// setSchedule replaces the schedule with expr for PUT /jobs/{name}, the
+// same way a reload with a changed CRON_EXPRESSION_FILE does, and returns
+// the new scheduler entries and schedule. An invalid expr is a *scheduleError and leaves
+// the old schedule in place.
+func (r *runner) setSchedule(expr, source string) ([]cron.EntryID, string, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	if r.cfg.natsURL != "" {
+		return nil, "", errNotScheduled
+	}
+	specs, err := r.cfg.parseSchedules("schedule", expr)
+	if err == nil && len(specs) == 0 {
+		err = errors.New("schedule is required")
+	}
+	if err != nil {
+		logf(LevelWarn, "Rejected schedule change via %s, keeping '%s': %v", source, r.spec, err)
+		return nil, "", &scheduleError{err}
+	}
+	old, oldSpec := r.entryIDs, r.spec
+	if err := r.addSchedules(specs); err != nil {
+		logf(LevelError, "Schedule change via %s failed, keeping '%s': %v", source, oldSpec, err)
+		return nil, "", &scheduleError{err}
+	}
+	r.removeSchedules(old)
+	logf(LevelInfo, "Schedule changed from '%s' to '%s' via %s (entries %v replaced by %v)", oldSpec, r.spec, source, old, r.entryIDs)
+	return r.entryIDs, r.spec, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rlimInfinity is RLIM_INFINITY, written as "unlimited".
+const rlimInfinity = math.MaxUint64
+
+// rlimit is one CRON_RLIMIT_* setting: the resource name as used in the
+// variable (NOFILE, CORE, AS or CPU) and the value applied as both the soft
+// and the hard limit.
+type rlimit struct {
+	resource string
+	value    uint64
+}
+
+func (l rlimit) String() string {
+	if l.value == rlimInfinity {
+		return l.resource + "=unlimited"
+	}
+	switch l.resource {
+	case "CORE", "AS":
+		return l.resource + "=" + formatBytes(l.value)
+	case "CPU":
+		return l.resource + "=" + (time.Duration(l.value) * time.Second).String()
+	}
+	return l.resource + "=" + strconv.FormatUint(l.value, 10)
+}
+
+// rlimitResources lists the supported CRON_RLIMIT_* suffixes in the order
+// they are applied and logged.
+var rlimitResources = []string{"NOFILE", "CORE", "AS", "CPU"}
+
+// loadRlimits reads the CRON_RLIMIT_* variables. NOFILE takes a count, CORE
+// and AS a size such as 512M or 1G and CPU seconds or a duration such as
+// 10m; all of them accept "unlimited".
+func loadRlimits() ([]rlimit, error) {
+	var limits []rlimit
+	for _, res := range rlimitResources {
+		name := "CRON_RLIMIT_" + res
+		v := strings.TrimSpace(os.Getenv(name))
+		if v == "" {
+			continue
+		}
+		n, err := parseRlimit(res, v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s value '%s': %v", name, v, err)
+		}
+		limits = append(limits, rlimit{resource: res, value: n})
+	}
+	return limits, nil
+}
+
+func parseRlimit(res, v string) (uint64, error) {
+	switch strings.ToLower(v) {
+	case "unlimited", "infinity":
+		return rlimInfinity, nil
+	}
+	switch res {
+	case "CORE", "AS":
+		return parseBytes(v)
+	case "CPU":
+		if sec, err := strconv.ParseUint(v, 10, 64); err == nil {
+			return sec, nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("expected seconds or a duration such as 10m")
+		}
+		return uint64((d + time.Second - 1) / time.Second), nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected a positive integer or unlimited")
+	}
+	return n, nil
+}
+
+var byteUnits = []struct {
+	suffix string
+	shift  uint
+}{{"T", 40}, {"G", 30}, {"M", 20}, {"K", 10}}
+
+// parseBytes reads a size with an optional K, M, G or T suffix (powers of
+// 1024; a trailing "B" or "iB" is allowed).
+func parseBytes(v string) (uint64, error) {
+	s := strings.ToUpper(v)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	shift := uint(0)
+	for _, u := range byteUnits {
+		if num, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, shift = num, u.shift
+			break
+		}
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err != nil || n > math.MaxUint64>>shift {
+		return 0, fmt.Errorf("expected a size such as 512M, 1G or unlimited")
+	}
+	return n << shift, nil
+}
+
+func formatBytes(n uint64) string {
+	for _, u := range byteUnits {
+		if n != 0 && n%(1<<u.shift) == 0 {
+			return strconv.FormatUint(n>>u.shift, 10) + u.suffix
+		}
+	}
+	return strconv.FormatUint(n, 10)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	memLimitSupported = true
+	rlimitSupported   = true
+)
+
+var rlimitIDs = map[string]int{
+	"NOFILE": unix.RLIMIT_NOFILE,
+	"CORE":   unix.RLIMIT_CORE,
+	"AS":     unix.RLIMIT_AS,
+	"CPU":    unix.RLIMIT_CPU,
+}
+
+// raiseHardRlimit raises the hard limit of the pre-exec helper to l's value
+// if it is lower, while the helper still has the privileges for it;
+// setRlimit then only lowers limits.
+func raiseHardRlimit(l rlimit) error {
+	var cur syscall.Rlimit
+	if err := syscall.Getrlimit(rlimitIDs[l.resource], &cur); err != nil {
+		return err
+	}
+	if cur.Max >= l.value {
+		return nil
+	}
+	return syscall.Setrlimit(rlimitIDs[l.resource], &syscall.Rlimit{Cur: cur.Cur, Max: l.value})
+}
+
+// setRlimit sets l as both the soft and the hard limit of the pre-exec
+// helper, which the command inherits. It goes through syscall rather than
+// x/sys/unix so that syscall.Exec doesn't put back the NOFILE limit the Go
+// runtime raised at startup.
+func setRlimit(l rlimit) error {
+	return syscall.Setrlimit(rlimitIDs[l.resource], &syscall.Rlimit{Cur: l.value, Max: l.value})
+}
+
+// likelyHitMemLimit guesses whether a run that failed under
+// CRON_MEM_LIMIT_MB did so because allocations were refused. There is no
+// direct signal for this; crashes typical of a failed allocation (abort,
+// segfault, bus error) are taken as evidence.
+func likelyHitMemLimit(state *os.ProcessState) bool {
+	if state == nil || state.Success() {
+		return false
+	}
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return false
+	}
+	if ws.Signaled() {
+		switch ws.Signal() {
+		case syscall.SIGABRT, syscall.SIGSEGV, syscall.SIGBUS:
+			return true
+		}
+		return false
+	}
+	// Shells report a child killed by SIGABRT/SIGSEGV as 128+signal.
+	switch ws.ExitStatus() {
+	case 128 + int(syscall.SIGABRT), 128 + int(syscall.SIGSEGV), 128 + int(syscall.SIGBUS):
+		return true
+	}
+	return false
+}
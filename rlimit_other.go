@@ -0,0 +1,25 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+const (
+	memLimitSupported = false
+	rlimitSupported   = false
+)
+
+func raiseHardRlimit(l rlimit) error {
+	return errors.New("resource limits are only supported on Linux")
+}
+
+func setRlimit(l rlimit) error {
+	return errors.New("resource limits are only supported on Linux")
+}
+
+func likelyHitMemLimit(state *os.ProcessState) bool {
+	return false
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+)
+
+// runAs is the identity the command is started with when CRON_RUN_AS_USER
+// is set.
+type runAs struct {
+	user  string
+	group string
+	uid   uint32
+	gid   uint32
+}
+
+// resolveRunAs looks up userName (and optionally groupName, defaulting to the
+// user's primary group), accepting names or numeric IDs. It fails if the
+// runner could not switch to that identity because it is not root.
+func resolveRunAs(userName, groupName string) (*runAs, error) {
+	ra, err := lookupIdentity(userName, groupName)
+	if err != nil {
+		return nil, err
+	}
+	if euid := os.Geteuid(); euid != 0 && (uint32(euid) != ra.uid || uint32(os.Getegid()) != ra.gid) {
+		return nil, fmt.Errorf("cronrunner runs as uid %d and needs root to switch to %s:%s", euid, ra.user, ra.group)
+	}
+	return ra, nil
+}
+
+// lookupIdentity resolves a user and optional group, names or numeric IDs,
+// to their uid and gid.
+func lookupIdentity(userName, groupName string) (*runAs, error) {
+	u, err := user.Lookup(userName)
+	if err != nil {
+		if _, numErr := strconv.Atoi(userName); numErr != nil {
+			return nil, fmt.Errorf("unknown user '%s': %v", userName, err)
+		}
+		if u, err = user.LookupId(userName); err != nil {
+			return nil, fmt.Errorf("unknown user '%s': %v", userName, err)
+		}
+	}
+
+	gidStr := u.Gid
+	group := gidStr
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			if _, numErr := strconv.Atoi(groupName); numErr != nil {
+				return nil, fmt.Errorf("unknown group '%s': %v", groupName, err)
+			}
+			if g, err = user.LookupGroupId(groupName); err != nil {
+				return nil, fmt.Errorf("unknown group '%s': %v", groupName, err)
+			}
+		}
+		gidStr = g.Gid
+		group = g.Name
+	} else if g, err := user.LookupGroupId(gidStr); err == nil {
+		group = g.Name
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user '%s' has non-numeric uid '%s'", userName, u.Uid)
+	}
+	gid, err := strconv.ParseUint(gidStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("group '%s' has non-numeric gid '%s'", group, gidStr)
+	}
+
+	return &runAs{user: u.Username, group: group, uid: uint32(uid), gid: uint32(gid)}, nil
+}
+
+// resolveGroups looks up CRON_SUPPLEMENTARY_GROUPS, names or numeric IDs,
+// and returns their gids. Setting the child's groups needs root; without
+// it the list is still accepted when the runner already belongs to every
+// group, since the child inherits them anyway, and nil is returned.
+func resolveGroups(names []string) ([]uint32, error) {
+	gids := make([]uint32, 0, len(names))
+	for _, name := range names {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			if _, numErr := strconv.Atoi(name); numErr != nil {
+				return nil, fmt.Errorf("unknown group '%s': %v", name, err)
+			}
+			if g, err = user.LookupGroupId(name); err != nil {
+				return nil, fmt.Errorf("unknown group '%s': %v", name, err)
+			}
+		}
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("group '%s' has non-numeric gid '%s'", name, g.Gid)
+		}
+		gids = append(gids, uint32(gid))
+	}
+
+	if os.Geteuid() == 0 {
+		return gids, nil
+	}
+	own, err := os.Getgroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cronrunner's own groups: %v", err)
+	}
+	own = append(own, os.Getegid())
+	for i, gid := range gids {
+		if !slices.Contains(own, int(gid)) {
+			return nil, fmt.Errorf("cronrunner runs as uid %d, is not a member of group '%s' and needs root to add it", os.Geteuid(), names[i])
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,165 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLookupIdentity(t *testing.T) {
+	tests := []struct {
+		user, group string
+		want        runAs
+	}{
+		{"root", "", runAs{user: "root", group: "root", uid: 0, gid: 0}},
+		{"0", "", runAs{user: "root", group: "root", uid: 0, gid: 0}},
+		{"root", "root", runAs{user: "root", group: "root", uid: 0, gid: 0}},
+		{"root", "0", runAs{user: "root", group: "root", uid: 0, gid: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user+":"+tt.group, func(t *testing.T) {
+			got, err := lookupIdentity(tt.user, tt.group)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("lookupIdentity(%q, %q) = %+v, want %+v", tt.user, tt.group, *got, tt.want)
+			}
+		})
+	}
+	if _, err := lookupIdentity("cronrunner-no-such-user", ""); err == nil {
+		t.Error("lookupIdentity accepted an unknown user")
+	}
+	if _, err := lookupIdentity("root", "cronrunner-no-such-group"); err == nil {
+		t.Error("lookupIdentity accepted an unknown group")
+	}
+}
+
+func TestResolveRunAsNeedsRoot(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("running as root, which can switch to any user")
+	}
+	if _, err := resolveRunAs("root", ""); err == nil {
+		t.Error("resolveRunAs accepted root without running as root")
+	}
+}
+
+func TestSysProcAttrCredential(t *testing.T) {
+	attr := sysProcAttr(&config{runAs: &runAs{uid: 65534, gid: 65533}, groupIDs: []uint32{4, 100}}, nil)
+	if c := attr.Credential; c == nil || c.Uid != 65534 || c.Gid != 65533 || !slices.Equal(c.Groups, []uint32{4, 100}) {
+		t.Errorf("credential = %+v, want uid 65534, gid 65533 and groups 4, 100", c)
+	}
+	attr = sysProcAttr(&config{groupIDs: []uint32{4}}, nil)
+	if c := attr.Credential; c == nil || c.Uid != uint32(os.Getuid()) || c.Gid != uint32(os.Getgid()) || !slices.Equal(c.Groups, []uint32{4}) {
+		t.Errorf("credential = %+v, want our own uid and gid with group 4", c)
+	}
+	if attr := sysProcAttr(&config{}, nil); attr.Credential != nil {
+		t.Errorf("credential = %+v without CRON_RUN_AS_USER, want nil", attr.Credential)
+	}
+}
+
+// childStatus runs a command as configured in env that copies its
+// /proc/<pid>/status, and returns the fields of the lines it has.
+func childStatus(t *testing.T, env map[string]string) map[string][]string {
+	t.Helper()
+	// The command may run as another user, who has to be able to reach the
+	// output file.
+	out := filepath.Join(t.TempDir(), "status")
+	for dir := filepath.Dir(out); dir != os.TempDir() && dir != "/"; dir = filepath.Dir(dir) {
+		if err := os.Chmod(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(out, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(out, 0666); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRunner(t, "cat /proc/$$/status > "+out, env)
+	if rec := r.execute(r.request(newRunID())); !rec.succeeded() {
+		t.Fatalf("command failed with exit code %d", rec.ExitCode)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := make(map[string][]string)
+	for _, line := range strings.Split(string(b), "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			status[name] = strings.Fields(value)
+		}
+	}
+	return status
+}
+
+func TestRunAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users needs root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skipf("no nobody user: %v", err)
+	}
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantGroups []string
+	}{
+		{
+			name:       "user",
+			env:        map[string]string{"CRON_RUN_AS_USER": "nobody"},
+			wantGroups: nil,
+		},
+		{
+			name:       "numeric group",
+			env:        map[string]string{"CRON_RUN_AS_USER": nobody.Uid, "CRON_RUN_AS_GROUP": "0"},
+			wantGroups: nil,
+		},
+		{
+			name:       "supplementary groups",
+			env:        map[string]string{"CRON_RUN_AS_USER": "nobody", "CRON_SUPPLEMENTARY_GROUPS": "0"},
+			wantGroups: []string{"0"},
+		},
+		{
+			// CRON_UMASK starts the command through the helper, which then
+			// switches users instead of the Credential.
+			name:       "through the helper",
+			env:        map[string]string{"CRON_RUN_AS_USER": "nobody", "CRON_SUPPLEMENTARY_GROUPS": "0", "CRON_UMASK": "027"},
+			wantGroups: []string{"0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantGid := nobody.Gid
+			if g, ok := tt.env["CRON_RUN_AS_GROUP"]; ok {
+				wantGid = g
+			}
+			st := childStatus(t, tt.env)
+			// Real, effective, saved and filesystem IDs are all switched.
+			for _, id := range st["Uid"] {
+				if id != nobody.Uid {
+					t.Errorf("Uid: %v, want %s", st["Uid"], nobody.Uid)
+					break
+				}
+			}
+			for _, id := range st["Gid"] {
+				if id != wantGid {
+					t.Errorf("Gid: %v, want %s", st["Gid"], wantGid)
+					break
+				}
+			}
+			if groups := st["Groups"]; !slices.Equal(groups, tt.wantGroups) {
+				t.Errorf("Groups: %v, want %v", groups, tt.wantGroups)
+			}
+			if umask, ok := tt.env["CRON_UMASK"]; ok && !slices.Equal(st["Umask"], []string{"0" + umask}) {
+				t.Errorf("Umask: %v, want 0%s", st["Umask"], umask)
+			}
+		})
+	}
+}
@@ -0,0 +1,1012 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// runner executes the configured command for each cron tick and keeps track
+// of the child processes that are currently running.
+type runner struct {
+	cfg       *config
+	sched     *cron.Cron
+	pids      *pidFile
+	history   history
+	reporters []reporter
+	events    *eventLog
+	s3        *s3Uploader
+	webhook   *webhookNotifier
+	slack     *slackNotifier
+	k8s       *k8sJobRunner
+	lambda    *lambdaInvoker
+	cloudRun  *cloudRunJob
+	ssh       *sshRunner
+	vault     *vaultSecrets
+	cmdFile   *commandFile
+
+	wg sync.WaitGroup
+
+	// reloadMu serializes reloads; entryIDs and spec are the scheduler
+	// entries and the schedule they were added with.
+	reloadMu sync.Mutex
+	entryIDs []cron.EntryID
+	spec     string
+
+	mu       sync.Mutex
+	active   map[int]*activeCommand
+	running  int
+	stopping bool
+	stopped  chan struct{}
+	paused   bool
+	lastRun  *runRecord
+
+	// pending is a run queued by OVERLAP_STRATEGY; superseded and
+	// interrupt tell the current run that replace has stopped it.
+	pending    bool
+	superseded bool
+	interrupt  chan struct{}
+
+	// startedAt and lastSuccess back the LIVENESS_MAX_AGE_SEC check;
+	// lastSuccess is seeded from the history so it survives restarts with
+	// HISTORY_DB_PATH.
+	startedAt   time.Time
+	lastSuccess time.Time
+
+	failures    int
+	breakerOpen bool
+	tripped     chan struct{}
+
+	// sigkills counts commands that ignored SIGTERM and were killed.
+	sigkills int
+
+	// skipped counts scheduled ticks that didn't run, by skipReasons.
+	skipped map[string]int
+
+	// started and completed count runs for CRON_MAX_RUNS.
+	started   int
+	completed int
+	finished  chan struct{}
+}
+
+func newRunner(cfg *config) (*runner, error) {
+	r := &runner{
+		cfg:       cfg,
+		active:    make(map[int]*activeCommand),
+		tripped:   make(chan struct{}, 1),
+		finished:  make(chan struct{}, 1),
+		stopped:   make(chan struct{}),
+		skipped:   make(map[string]int),
+		interrupt: make(chan struct{}, 1),
+	}
+	if cfg.pidFilePath != "" {
+		r.pids = newPIDFile(cfg.pidFilePath)
+	}
+	if cfg.cmdFile != "" {
+		var validate func(string) error
+		if !cfg.remote() {
+			validate = func(command string) error {
+				return validateCommand(commandArgs(command, cfg.shell), cfg.shell, cfg.chroot)
+			}
+		}
+		r.cmdFile = newCommandFile(cfg.cmdFile, cfg.command, validate, cfg.displayCommand)
+	}
+	if cfg.historyDBPath != "" {
+		h, err := openSQLiteHistory(cfg.historyDBPath, cfg.historyMaxRows)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open HISTORY_DB_PATH: %v", err)
+		}
+		r.history = h
+	} else {
+		r.history = newMemoryHistory(defaultHistorySize)
+	}
+	r.startedAt = time.Now()
+	if t, err := r.history.lastSuccess(cfg.jobName); err != nil {
+		logf(LevelError, "Failed to read the last successful run from history: %v", err)
+	} else {
+		r.lastSuccess = t
+	}
+	if cfg.exitCodePath != "" {
+		f, err := newExitCodeFile(cfg.exitCodePath, cfg.exitCodeFmt)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot create CRON_EXITCODE_FILE directory: %v", err)
+		}
+		r.reporters = append(r.reporters, f)
+	}
+	if cfg.exitCodeOutput {
+		r.reporters = append(r.reporters, exitCodeOutput{})
+	}
+	if cfg.eventFile != "" {
+		events, err := newEventLog(cfg.eventFile)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot create CRON_EVENT_FILE directory: %v", err)
+		}
+		r.events = events
+	}
+	if cfg.cloudWatchNamespace != "" {
+		cw, err := newCloudWatchReporter(cfg.cloudWatchNamespace, cfg.awsRegion, cfg.notifyHTTPTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set up CloudWatch metrics: %v", err)
+		}
+		r.reporters = append(r.reporters, cw)
+	}
+	if cfg.statsdAddr != "" {
+		sd, err := newStatsdReporter(cfg.statsdAddr, cfg.statsdPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set up StatsD metrics: %v", err)
+		}
+		r.reporters = append(r.reporters, sd)
+	}
+	if cfg.pushgatewayURL != "" {
+		r.reporters = append(r.reporters, newPushgatewayReporter(cfg.pushgatewayURL, cfg.jobName, cfg.pushgatewayInstance, cfg.pushgatewayTimeout))
+	}
+	if cfg.webhookURL != "" {
+		r.webhook = newWebhookNotifier(cfg.webhookURL, cfg.webhookTimeout, cfg.webhookTLS)
+		r.reporters = append(r.reporters, r.webhook)
+	}
+	if cfg.slackURL != "" {
+		r.slack = newSlackNotifier(cfg)
+		r.reporters = append(r.reporters, r.slack)
+	}
+	if cfg.pagerDutyKey != "" {
+		r.reporters = append(r.reporters, newPagerDutyNotifier(cfg))
+	}
+	if cfg.pingURL != "" || cfg.pingFailURL != "" {
+		r.reporters = append(r.reporters, newHealthPinger(cfg))
+	}
+	if cfg.s3Bucket != "" {
+		u, err := newS3Uploader(cfg.s3Bucket, cfg.s3KeyPrefix, cfg.s3Compress, cfg.s3Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set up S3 output upload: %v", err)
+		}
+		r.s3 = u
+	}
+	if cfg.k8sJobMode {
+		k, err := newK8sJobRunner(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set up K8S_JOB_MODE: %v", err)
+		}
+		r.k8s = k
+	}
+	if cfg.vaultSecretPath != "" {
+		v, err := newVaultSecrets(cfg.vaultSecretPath, cfg.vaultLeaseRenew, cfg.notifyHTTPTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set up Vault secrets: %v", err)
+		}
+		r.vault = v
+	}
+	if cfg.lambdaFunctionName != "" {
+		l, err := newLambdaInvoker(cfg.lambdaFunctionName, cfg.lambdaTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set up LAMBDA_FUNCTION_NAME: %v", err)
+		}
+		r.lambda = l
+	}
+	if cfg.cloudRunJob != "" {
+		c, err := newCloudRunJob(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set up GCP_CLOUD_RUN_JOB: %v", err)
+		}
+		r.cloudRun = c
+	}
+	if cfg.sshHost != "" {
+		s, err := newSSHRunner(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set up CRON_SSH_HOST: %v", err)
+		}
+		r.ssh = s
+	}
+	return r, nil
+}
+
+// signalActive relays sig to the process group of every running child and
+// reports how many commands it reached.
+func (r *runner) signalActive(sig os.Signal) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for pid, c := range r.active {
+		if err := signalGroup(c.p, sig); err != nil {
+			logf(LevelError, "Failed to forward %s to PID %d: %v", signalName(sig), pid, err)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// terminateActive stops every running command as a timeout would: with
+// SIGTERM and, after CRON_KILL_GRACE_SEC, SIGKILL, or with SIGKILL alone.
+func (r *runner) terminateActive() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for pid, c := range r.active {
+		if err := c.term.stop(c.p); err != nil {
+			logf(LevelError, "Failed to stop PID %d: %v", pid, err)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// activeCommand is a running command and how it is stopped.
+type activeCommand struct {
+	p    *os.Process
+	term *termination
+}
+
+func (r *runner) track(p *os.Process, term *termination) {
+	r.mu.Lock()
+	r.active[p.Pid] = &activeCommand{p: p, term: term}
+	r.mu.Unlock()
+	if r.pids != nil {
+		r.pids.acquire(p.Pid)
+	}
+}
+
+func (r *runner) untrack(p *os.Process) {
+	if r.pids != nil {
+		r.pids.release(p.Pid)
+	}
+	r.mu.Lock()
+	delete(r.active, p.Pid)
+	r.mu.Unlock()
+}
+
+// exitCoder is implemented by errors that carry the command's exit code.
+type exitCoder interface {
+	ExitCode() int
+}
+
+// Reasons a run is not started.
+var (
+	errShuttingDown   = errors.New("shutting down")
+	errPaused         = errors.New("paused")
+	errAlreadyRunning = errors.New("already running")
+	errMaxRuns        = errors.New("CRON_MAX_RUNS reached")
+)
+
+// begin reserves a slot for a new run. Unless ALLOW_CONCURRENT is set, only
+// one run may be in progress at a time.
+func (r *runner) begin() error {
+	return r.reserve(!r.cfg.allowConcurrent)
+}
+
+// reserve registers a new run, refusing it during shutdown or pause and, if
+// exclusive, while another run is in progress.
+func (r *runner) reserve(exclusive bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopping {
+		return errShuttingDown
+	}
+	if r.paused {
+		return errPaused
+	}
+	if exclusive && r.running > 0 {
+		return errAlreadyRunning
+	}
+	if r.cfg.maxRuns > 0 && r.started >= r.cfg.maxRuns {
+		return errMaxRuns
+	}
+	r.started++
+	r.running++
+	r.wg.Add(1)
+	if exclusive {
+		r.superseded = false
+		select {
+		case <-r.interrupt:
+		default:
+		}
+	}
+	return nil
+}
+
+// end releases the run's slot and, once no run is left, starts the one
+// OVERLAP_STRATEGY queued.
+func (r *runner) end() {
+	r.mu.Lock()
+	r.running--
+	next := r.pending && r.running == 0 && !r.stopping
+	if r.running == 0 {
+		r.pending = false
+	}
+	r.mu.Unlock()
+	r.wg.Done()
+	if next {
+		logf(LevelInfo, "Starting the queued run")
+		go r.runTick(time.Time{})
+	}
+}
+
+// trigger starts an out-of-schedule run in the background and returns its
+// run ID, or the reason it could not be started.
+func (r *runner) trigger(source string) (string, error) {
+	if err := r.begin(); err != nil {
+		logf(LevelWarn, "Manual run requested via %s but not started: %v", source, err)
+		return "", err
+	}
+	runID := newRunID()
+	logf(LevelInfo, "Manual run %s requested via %s", runID, source)
+	go func() {
+		defer r.end()
+		r.execute(r.request(runID))
+	}()
+	return runID, nil
+}
+
+// setPaused pauses or resumes scheduling and reports whether the state
+// changed. While paused any tick or manual trigger is skipped; a run
+// already in progress is left to finish. The scheduler keeps firing, so
+// the skipped ticks are counted.
+func (r *runner) setPaused(paused bool, source string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopping || r.paused == paused {
+		return false
+	}
+	r.paused = paused
+	if paused {
+		logf(LevelInfo, "Scheduler paused via %s; new runs will be skipped", source)
+	} else {
+		if r.breakerOpen {
+			logf(LevelInfo, "Circuit breaker reset")
+		}
+		r.failures = 0
+		r.breakerOpen = false
+		logf(LevelInfo, "Scheduler resumed via %s", source)
+	}
+	return true
+}
+
+// togglePaused flips the paused state.
+func (r *runner) togglePaused(source string) {
+	r.setPaused(!r.isPaused(), source)
+}
+
+func (r *runner) isPaused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paused
+}
+
+// runnerStatus is the snapshot served on /status.
+type runnerStatus struct {
+	Paused   bool           `json:"paused"`
+	Running  int            `json:"running"`
+	LastRun  *runRecord     `json:"last_run"`
+	Breaker  *breakerStatus `json:"circuit_breaker,omitempty"`
+	Sigkills int            `json:"sigkills"`
+	Skipped  map[string]int `json:"skipped"`
+}
+
+func (r *runner) status() runnerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return runnerStatus{
+		Paused:   r.paused,
+		Running:  r.running,
+		LastRun:  r.lastRun,
+		Breaker:  r.breakerState(),
+		Sigkills: r.sigkills,
+		Skipped:  r.skipCounts(),
+	}
+}
+
+// Reasons a scheduled tick is skipped, as counted on /status and /metrics.
+const (
+	skipOverlap   = "overlap"
+	skipCondition = "condition"
+	skipPaused    = "paused"
+)
+
+var skipReasons = []string{skipOverlap, skipCondition, skipPaused}
+
+func (r *runner) countSkip(reason string) {
+	r.mu.Lock()
+	r.skipped[reason]++
+	r.mu.Unlock()
+	r.events.skip(r.cfg.jobName, r.currentCommand(), reason)
+}
+
+// currentCommand is the command as logged, from the last read of
+// CRON_CMD_FILE if there is one.
+func (r *runner) currentCommand() string {
+	if r.cmdFile != nil {
+		return r.cfg.displayCommand(r.cmdFile.current())
+	}
+	return r.cfg.displayCommand(r.cfg.command)
+}
+
+// skipCounts copies the skip counters, with every reason present. It is
+// called with r.mu held.
+func (r *runner) skipCounts() map[string]int {
+	counts := make(map[string]int, len(skipReasons))
+	for _, reason := range skipReasons {
+		counts[reason] = r.skipped[reason]
+	}
+	return counts
+}
+
+// nextRun returns the earliest time any of the job's schedules fires next.
+// It is false when nothing is scheduled: while paused, in NATS mode, or
+// before the scheduler has started.
+func (r *runner) nextRun() (time.Time, bool) {
+	if r.sched == nil || r.isPaused() {
+		return time.Time{}, false
+	}
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	var next time.Time
+	for _, id := range r.entryIDs {
+		if t := r.sched.Entry(id).Next; !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// stale reports whether the job has gone longer than maxAge without a
+// successful run, and when that last success was. It never reports a runner
+// stale before its first run or while it is paused; a job that has not
+// succeeded yet is measured from the runner's start.
+func (r *runner) stale(maxAge time.Duration) (bool, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lastRun == nil || r.paused {
+		return false, r.lastSuccess
+	}
+	since := r.lastSuccess
+	if since.Before(r.startedAt) {
+		since = r.startedAt
+	}
+	return time.Since(since) > maxAge, r.lastSuccess
+}
+
+// stop prevents any further runs from starting.
+func (r *runner) stop() {
+	r.mu.Lock()
+	if !r.stopping {
+		r.stopping = true
+		close(r.stopped)
+	}
+	r.mu.Unlock()
+}
+
+func (r *runner) isStopping() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopping
+}
+
+// wait returns a channel that is closed once every run, scheduled or
+// manual, has finished.
+func (r *runner) wait() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+func (r *runner) close() {
+	if r.pids != nil {
+		r.pids.close()
+	}
+	if r.slack != nil {
+		r.slack.wait()
+	}
+	if err := r.history.close(); err != nil {
+		logf(LevelError, "Failed to close run history: %v", err)
+	}
+}
+
+func (r *runner) record(req runRequest, rec runRecord) {
+	r.mu.Lock()
+	r.lastRun = &rec
+	if rec.succeeded() && rec.StartedAt.After(r.lastSuccess) {
+		r.lastSuccess = rec.StartedAt
+	}
+	trip := r.countRun(rec)
+	r.completed++
+	completed := r.completed
+	r.mu.Unlock()
+
+	if err := r.history.add(rec); err != nil {
+		logf(LevelError, "Failed to record run %s in history: %v", rec.RunID, err)
+	}
+	for _, rep := range r.reporters {
+		rep.report(rec)
+	}
+	r.events.run(r.cfg.displayCommand(req.command), rec)
+	if trip {
+		r.tripBreaker()
+	}
+	if max := r.cfg.maxRuns; max > 0 {
+		logf(LevelInfo, "Completed run %d of CRON_MAX_RUNS=%d, %d remaining", completed, max, max-completed)
+		if completed >= max {
+			select {
+			case r.finished <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// maxRunsReached is signalled once CRON_MAX_RUNS runs have completed.
+func (r *runner) maxRunsReached() <-chan struct{} {
+	return r.finished
+}
+
+// run is the cron callback: it executes the command, restarting it on
+// failure when RESTART_ON_FAIL is set, until it succeeds or the kill
+// deadline is reached.
+func (r *runner) run() {
+	r.runTick(time.Now().Truncate(time.Second))
+}
+
+// runTick does the work of run for a tick scheduled at scheduledAt, which
+// is zero for the run OVERLAP_STRATEGY queued.
+func (r *runner) runTick(scheduledAt time.Time) {
+	switch err := r.begin(); err {
+	case nil:
+	case errPaused:
+		logf(LevelInfo, "Scheduler paused; skipping this run")
+		r.countSkip(skipPaused)
+		return
+	case errAlreadyRunning:
+		r.overlap()
+		return
+	default:
+		return
+	}
+	defer r.end()
+
+	// CRON_TOTAL_DEADLINE counts from the tick, so it covers the condition
+	// check as well.
+	begun := time.Now()
+
+	// A tick skipped by CRON_CONDITION_CMD is neither a failure nor one of
+	// the CRON_MAX_RUNS runs.
+	if !r.conditionMet(r.tickDeadline(begun)) {
+		r.mu.Lock()
+		r.started--
+		r.skipped[skipCondition]++
+		r.mu.Unlock()
+		r.events.skip(r.cfg.jobName, r.currentCommand(), skipCondition)
+		return
+	}
+	req := r.request(newRunID())
+	req.begun = begun
+	req.scheduledAt = scheduledAt
+	r.execute(req)
+}
+
+// runRequest describes what a single run executes. Scheduled and manual runs
+// use the configured command; queue messages may supply their own command
+// and extra environment variables.
+type runRequest struct {
+	runID   string
+	command string
+	argv    []string
+	job     string
+	env     map[string]string
+	// begun is when the tick started, before CRON_CONDITION_CMD; zero for
+	// runs that start right away.
+	begun time.Time
+	// scheduledAt is the time of the tick that started the run; zero for
+	// manual, queued and NATS runs.
+	scheduledAt time.Time
+}
+
+// tickDeadline is when CRON_TOTAL_DEADLINE ends a tick that began at
+// begun, or zero without one.
+func (r *runner) tickDeadline(begun time.Time) time.Time {
+	if r.cfg.totalDeadline <= 0 {
+		return time.Time{}
+	}
+	return begun.Add(r.cfg.totalDeadline)
+}
+
+// request builds a runRequest for the configured command. With
+// CRON_CMD_FILE the file is read again; the job name stays the one derived
+// at startup so the run history isn't split by edits.
+func (r *runner) request(runID string) runRequest {
+	req := runRequest{
+		runID:   runID,
+		command: r.cfg.command,
+		argv:    r.cfg.argv,
+		job:     r.cfg.jobName,
+	}
+	if r.cmdFile != nil {
+		req.command = r.cmdFile.command()
+		req.argv = commandArgs(req.command, r.cfg.shell)
+	}
+	return req
+}
+
+// execute runs req once a slot has been reserved with begin, and returns the
+// outcome of the run.
+func (r *runner) execute(req runRequest) runRecord {
+	cfg := r.cfg
+	killAfter := cfg.killAfter
+	argv := req.argv
+
+	logf(LevelInfo, "Executing command: %s", cfg.displayCommand(req.command))
+
+	meta := RunMeta{
+		Format:      cfg.logSeparator,
+		Job:         req.job,
+		RunID:       req.runID,
+		ScheduledAt: req.scheduledAt,
+	}
+
+	if len(argv) == 0 {
+		logf(LevelWarn, "Empty command, skipping execution")
+		return runRecord{Job: meta.Job, RunID: meta.RunID, ExitCode: -1}
+	}
+
+	start := time.Now()
+
+	// The hard deadline is the earlier of the run's timeout and
+	// CRON_TOTAL_DEADLINE; deadlineLimit names the one that applies.
+	var hardDeadline time.Time
+	var deadlineLimit string
+	if killAfter > 0 {
+		hardDeadline = start.Add(killAfter)
+		deadlineLimit = fmt.Sprintf("%v, %s", killAfter, cfg.killAfterVar)
+	}
+	begun := req.begun
+	if begun.IsZero() {
+		begun = start
+	}
+	if d := r.tickDeadline(begun); !d.IsZero() && (hardDeadline.IsZero() || d.Before(hardDeadline)) {
+		hardDeadline = d
+		deadlineLimit = fmt.Sprintf("%v, CRON_TOTAL_DEADLINE", cfg.totalDeadline)
+	}
+	if !hardDeadline.IsZero() {
+		logf(LevelDebug, "Hard kill deadline set for %s (limit: %s)", hardDeadline.Format(time.RFC3339), deadlineLimit)
+	}
+
+	rec := runRecord{
+		Job:         meta.Job,
+		RunID:       meta.RunID,
+		ScheduledAt: meta.ScheduledAt,
+		StartedAt:   start,
+	}
+
+	var tr *runTrace
+	if cfg.otelEndpoint != "" {
+		tr = startRunTrace(req, cfg.displayCommand(req.command))
+		defer func() { tr.end(rec) }()
+	}
+
+	// Trace context and secrets are only given to the local child, never to
+	// cronrunner itself.
+	runEnv := map[string]string{}
+	maps.Copy(runEnv, cfg.proxyEnv)
+	maps.Copy(runEnv, cfg.caEnv)
+	maps.Copy(runEnv, tr.env())
+	if r.vault != nil {
+		secrets, err := r.vault.env()
+		if err != nil {
+			logf(LevelError, "Failed to fetch secrets from Vault; not running the command: %v", err)
+			rec.ExitCode = -1
+			rec.DurationMs = time.Since(start).Milliseconds()
+			r.record(req, rec)
+			return rec
+		}
+		maps.Copy(runEnv, secrets)
+	}
+	// CRON_TMPDIR is shared by the run's attempts and removed once the
+	// last one has exited.
+	if cfg.tmpDir && !cfg.remote() {
+		dir, err := newRunTmpDir(cfg, meta.RunID)
+		if err != nil {
+			logf(LevelError, "Failed to create CRON_TMPDIR directory; not running the command: %v", err)
+			rec.ExitCode = -1
+			rec.DurationMs = time.Since(start).Milliseconds()
+			r.record(req, rec)
+			return rec
+		}
+		defer dir.remove()
+		maps.Copy(runEnv, dir.env())
+	}
+	var captured *syncBuffer
+	if r.s3 != nil {
+		captured = &syncBuffer{}
+	}
+	var tail *tailBuffer
+	if r.slack != nil {
+		tail = r.slack.tail(meta.RunID)
+	}
+
+	// CRON_LOG_MAX_RUN_BYTES applies to the whole run, across attempts.
+	var fileBudget, consoleBudget *outputBudget
+	if cfg.logMaxRunBytes > 0 {
+		fileBudget = newOutputBudget(cfg.logMaxRunBytes)
+		if cfg.logCapConsole {
+			consoleBudget = newOutputBudget(cfg.logMaxRunBytes)
+		}
+	}
+
+	defer func() {
+		if fileBudget.wasTruncated() {
+			logf(LevelWarn, "Run %s output exceeded CRON_LOG_MAX_RUN_BYTES=%d; the remainder was not logged", meta.RunID, cfg.logMaxRunBytes)
+		}
+		if captured != nil {
+			r.s3.upload(meta, captured.Bytes())
+		}
+	}()
+
+	stopWarn := r.startWarnTimer(meta, start, hardDeadline)
+
+	for attempt := 1; ; attempt++ {
+		attemptStart := time.Now()
+
+		// Each attempt ends at its own timeout or at the run's hard
+		// deadline, whichever comes first.
+		var deadline time.Time
+		attemptLimited := false
+		if !hardDeadline.IsZero() {
+			if time.Until(hardDeadline) <= 0 {
+				logf(LevelWarn, "Kill deadline reached; not starting attempt %d", attempt)
+				if attempt == 1 {
+					// CRON_CONDITION_CMD used up the whole CRON_TOTAL_DEADLINE:
+					// the run timed out without the command ever starting.
+					// Like any other killed run it keeps exit code 0.
+					rec.Killed = true
+				}
+				break
+			}
+			deadline = hardDeadline
+		}
+		if cfg.attemptTimeout > 0 {
+			d := time.Now().Add(cfg.attemptTimeout)
+			if deadline.IsZero() || d.Before(deadline) {
+				deadline = d
+				attemptLimited = true
+			}
+		}
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if deadline.IsZero() {
+			ctx, cancel = context.WithCancel(context.Background())
+		} else {
+			ctx, cancel = context.WithDeadline(context.Background(), deadline)
+		}
+
+		// Open per-run log file (if provided) and tee only child process output
+		// to it and to the S3 capture buffer.
+		var sinks, outSinks []io.Writer
+		var execLogFile *os.File
+		var logBuf *logBuffer
+		if cfg.logFilePath != "" {
+			f, openErr := openLogFile(cfg)
+			if openErr != nil {
+				logf(LevelError, "Failed to open LOG_FILE '%s' for this run: %v", cfg.logFilePath, openErr)
+			} else {
+				execLogFile = f
+				var fileSink io.Writer = execLogFile
+				if cfg.logBufferSize > 0 {
+					logBuf = newLogBuffer(execLogFile, cfg.logBufferSize, cfg.logFlushInterval)
+					fileSink = logBuf
+				}
+				sinks = append(sinks, fileSink)
+				outSinks = append(outSinks, fileBudget.writer(fileSink))
+			}
+		}
+		if captured != nil {
+			sinks = append(sinks, captured)
+			outSinks = append(outSinks, captured)
+		}
+		if tail != nil {
+			outSinks = append(outSinks, tail)
+		}
+		// Write per-run start separator only to the log file and capture
+		_ = writeRunSeparator(io.MultiWriter(sinks...), eventRunStart, meta)
+
+		// CRON_REDACT_PATTERNS are applied before the output reaches any
+		// of its destinations.
+		redact := newRedactor(cfg.redactPatterns)
+		stdout := redact.writer(io.MultiWriter(append([]io.Writer{consoleBudget.writer(consoleStdout)}, outSinks...)...))
+		stderr := redact.writer(io.MultiWriter(append([]io.Writer{consoleBudget.writer(os.Stderr)}, outSinks...)...))
+
+		var err error
+		var state *os.ProcessState
+		sigkilled := false
+		switch {
+		case r.k8s != nil:
+			err = r.k8s.run(ctx, req, stdout)
+		case r.lambda != nil:
+			err = r.lambda.run(ctx, req, stdout)
+		case r.cloudRun != nil:
+			err = r.cloudRun.run(ctx, req, stdout)
+		case r.ssh != nil:
+			err = r.ssh.run(ctx, req, stdout, stderr)
+		default:
+			cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+			cmd.Dir = cfg.workDir
+			if cfg.chroot != "" {
+				// exec resolved the command on the host; it runs inside the
+				// chroot.
+				cmd.Path, cmd.Err = chrootLookPath(cfg.chroot, argv[0])
+			}
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
+			baseEnv := buildChildEnv(cfg.envAllowlist, cfg.envBlocklist)
+			if cfg.clearProxy {
+				baseEnv = withoutEnv(baseEnv, proxyVarNames)
+			}
+			cmd.Env = withEnv(withEnv(baseEnv, runEnv), req.env)
+			cmd.SysProcAttr = sysProcAttr(cfg, argv)
+			wrapPrivileges(cfg, cmd)
+			stdin, closeStdin := openStdin(cfg)
+			cmd.Stdin = stdin
+			term := newTermination(cfg.killGrace)
+			cmd.Cancel = func() error { return term.stop(cmd.Process) }
+
+			var pty *ptySession
+			if cfg.allocatePTY {
+				pty, err = attachPTY(cfg, cmd, stdout)
+				if err != nil {
+					err = fmt.Errorf("allocate PTY: %w", err)
+				}
+			}
+			if err == nil {
+				err = cmd.Start()
+			}
+			if pty != nil {
+				pty.started()
+			}
+			if err != nil {
+				err = &startError{name: argv[0], err: err}
+				logf(LevelError, "Failed to start command: %v", err)
+			} else {
+				r.track(cmd.Process, term)
+				err = cmd.Wait()
+				r.untrack(cmd.Process)
+				if term.finished() {
+					sigkilled = true
+					r.mu.Lock()
+					r.sigkills++
+					r.mu.Unlock()
+				}
+			}
+			closeStdin()
+			if pty != nil {
+				pty.close()
+			}
+			state = cmd.ProcessState
+		}
+		redact.flush()
+		duration := time.Since(attemptStart)
+		timedOut := ctx.Err() == context.DeadlineExceeded
+		cancel()
+
+		exitCode := 0
+		killed := false
+
+		if err != nil {
+			// Check if this was a timeout
+			if timedOut && attemptLimited {
+				logf(LevelError, "Attempt %d timed out after %v (limit: %v per attempt): %v", attempt, duration, cfg.attemptTimeout, err)
+				killed = true
+			} else if timedOut {
+				logf(LevelError, "Command timed out after %v; hard deadline %s reached (limit: %s): %v", time.Since(start), hardDeadline.Format(time.RFC3339), deadlineLimit, err)
+				killed = true
+			} else {
+				var ee exitCoder
+				if errors.As(err, &ee) {
+					exitCode = ee.ExitCode()
+				} else if state != nil {
+					exitCode = state.ExitCode()
+				} else if cfg.remote() {
+					exitCode = -1
+				}
+			}
+		}
+
+		// Write per-run end separator with exit code and duration, then close the log file
+		meta.ExitCode = exitCode
+		meta.Duration = duration
+		if logBuf != nil {
+			if err := logBuf.stop(); err != nil {
+				logf(LevelWarn, "Warning: failed to write buffered output to LOG_FILE '%s': %v", cfg.logFilePath, err)
+			}
+		}
+		_ = writeRunSeparator(io.MultiWriter(sinks...), eventRunEnd, meta)
+		if execLogFile != nil {
+			// CRON_LOG_FSYNC makes the end separator durable before the
+			// next attempt or a crash of the node.
+			if cfg.logFsync {
+				if err := execLogFile.Sync(); err != nil {
+					logf(LevelWarn, "Warning: failed to sync LOG_FILE '%s': %v", cfg.logFilePath, err)
+				}
+			}
+			_ = execLogFile.Close()
+		}
+
+		level := LevelInfo
+		if err != nil {
+			level = LevelWarn
+		}
+		logf(level, "Command exited after %v: exit code %d, error: %v", duration, exitCode, err)
+		if state != nil {
+			if rss := maxRSSKB(state); rss > 0 {
+				logf(LevelInfo, "Resource usage: %v user, %v system CPU, %d KiB max RSS", state.UserTime(), state.SystemTime(), rss)
+			} else {
+				logf(LevelInfo, "Resource usage: %v user, %v system CPU", state.UserTime(), state.SystemTime())
+			}
+			rec.addUsage(state)
+		}
+
+		rec.Attempts = attempt
+		rec.ExitCode = exitCode
+		rec.Killed = killed
+		rec.Sigkill = rec.Sigkill || sigkilled
+		var se *startError
+		rec.NotFound = errors.As(err, &se) && se.notFound()
+		rec.MemLimitHit = cfg.memLimitMB > 0 && likelyHitMemLimit(state)
+		if rec.MemLimitHit {
+			logf(LevelWarn, "Command likely exceeded CRON_MEM_LIMIT_MB=%d", cfg.memLimitMB)
+		}
+
+		if cfg.shouldRestart(exitCode, killed) {
+			if r.isStopping() {
+				logf(LevelInfo, "Shutting down; not restarting command")
+				break
+			}
+			if r.isSuperseded() {
+				logf(LevelInfo, "Replaced by a newer run; not restarting command")
+				break
+			}
+			d, ok := r.restartDelay(attempt, hardDeadline)
+			if !ok {
+				logf(LevelWarn, "Kill deadline would pass during the %v restart delay; not starting attempt %d", d, attempt+1)
+				break
+			}
+			if d > 0 {
+				logf(LevelInfo, "RESTART_ON_FAIL is enabled; restarting command in %v...", d)
+				if !r.sleepUnlessStopping(d) {
+					if r.isStopping() {
+						logf(LevelInfo, "Shutting down; not restarting command")
+					} else {
+						logf(LevelInfo, "Replaced by a newer run; not restarting command")
+					}
+					break
+				}
+				continue
+			}
+			logf(LevelInfo, "RESTART_ON_FAIL is enabled; restarting command...")
+			continue
+		}
+		if cfg.restartOnFail && exitCode != 0 {
+			logf(LevelInfo, "Exit code %d is not retried under RESTART_ON_FAIL; not restarting command", exitCode)
+		}
+
+		logf(LevelInfo, "Command completed")
+		break
+	}
+
+	stopWarn()
+
+	rec.DurationMs = time.Since(start).Milliseconds()
+	r.record(req, rec)
+	return rec
+}
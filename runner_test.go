@@ -0,0 +1,171 @@
+//go:build unix
+
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// newTestRunner loads the configuration from env, on top of an hourly
+// schedule, command as CRON_CMD and /bin/sh as CRON_SHELL, and builds a
+// runner from it.
+func newTestRunner(t *testing.T, command string, env map[string]string) *runner {
+	t.Helper()
+	t.Setenv("CRON_EXPRESSION", base64.StdEncoding.EncodeToString([]byte("@every 1h")))
+	t.Setenv("CRON_CMD", base64.StdEncoding.EncodeToString([]byte(command)))
+	t.Setenv("CRON_SHELL", "/bin/sh")
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	r, err := newRunner(cfg)
+	if err != nil {
+		t.Fatalf("newRunner: %v", err)
+	}
+	return r
+}
+
+func TestExecuteDeadlineSpentBeforeFirstAttempt(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	r := newTestRunner(t, "touch "+marker, map[string]string{
+		"CRON_TOTAL_DEADLINE": "1s",
+	})
+	req := r.request(newRunID())
+	req.begun = time.Now().Add(-2 * time.Second)
+
+	rec := r.execute(req)
+	if !rec.Killed || rec.ExitCode != 0 || rec.Attempts != 0 {
+		t.Errorf("got killed=%v exit code %d after %d attempts, want killed=true exit code 0 after 0", rec.Killed, rec.ExitCode, rec.Attempts)
+	}
+	if rec.succeeded() {
+		t.Error("run recorded as a success")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("command ran after the deadline had passed")
+	}
+}
+
+func TestExecuteDeadlineKillsAttempt(t *testing.T) {
+	r := newTestRunner(t, "sleep 10", map[string]string{
+		"CRON_TOTAL_DEADLINE": "500ms",
+	})
+	start := time.Now()
+	rec := r.execute(r.request(newRunID()))
+	if !rec.Killed || rec.ExitCode != 0 || rec.Attempts != 1 {
+		t.Errorf("got killed=%v exit code %d after %d attempts, want killed=true exit code 0 after 1", rec.Killed, rec.ExitCode, rec.Attempts)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("run took %v, want it killed at the 500ms deadline", d)
+	}
+}
+
+func TestExecuteDeadlineEndsBackoff(t *testing.T) {
+	r := newTestRunner(t, "exit 3", map[string]string{
+		"RESTART_ON_FAIL":          "true",
+		"RESTART_BACKOFF_STRATEGY": "constant",
+		"RESTART_BACKOFF_BASE_SEC": "1s",
+		"CRON_TOTAL_DEADLINE":      "1500ms",
+	})
+	start := time.Now()
+	rec := r.execute(r.request(newRunID()))
+	// Attempt 1 fails at once and the 1s wait still fits; after attempt 2
+	// less than 1s is left, so the run ends without waiting again.
+	if rec.Attempts != 2 || rec.ExitCode != 3 || rec.Killed {
+		t.Errorf("got exit code %d killed=%v after %d attempts, want exit code 3 after 2", rec.ExitCode, rec.Killed, rec.Attempts)
+	}
+	if d := time.Since(start); d >= 1500*time.Millisecond {
+		t.Errorf("run took %v, want it to end before the 1.5s deadline", d)
+	}
+}
+
+func TestExecuteAttemptTimeoutWithinDeadline(t *testing.T) {
+	r := newTestRunner(t, "sleep 10", map[string]string{
+		"RESTART_ON_FAIL":          "true",
+		"CRON_ATTEMPT_TIMEOUT_MIN": "300ms",
+		"CRON_TOTAL_DEADLINE":      "1s",
+	})
+	start := time.Now()
+	rec := r.execute(r.request(newRunID()))
+	// Attempts are killed after 300ms each and restarted right away, until
+	// the last one is cut short by the deadline.
+	if !rec.Killed || rec.Attempts < 3 || rec.Attempts > 4 {
+		t.Errorf("got killed=%v after %d attempts, want killed=true after 3 or 4", rec.Killed, rec.Attempts)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("run took %v, want it to end at the 1s deadline", d)
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	r := newTestRunner(t, "true", map[string]string{
+		"RESTART_ON_FAIL":          "true",
+		"RESTART_BACKOFF_STRATEGY": "exponential",
+		"RESTART_BACKOFF_BASE_SEC": "10",
+	})
+	tests := []struct {
+		name     string
+		attempt  int
+		deadline time.Time
+		want     time.Duration
+		ok       bool
+	}{
+		{"no deadline", 3, time.Time{}, 40 * time.Second, true},
+		{"fits", 1, time.Now().Add(time.Minute), 10 * time.Second, true},
+		{"passes deadline", 4, time.Now().Add(time.Minute), 80 * time.Second, false},
+		{"ends at deadline", 2, time.Now().Add(20 * time.Second), 20 * time.Second, false},
+		{"deadline spent", 1, time.Now().Add(-time.Second), 10 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := r.restartDelay(tt.attempt, tt.deadline)
+			if d != tt.want || ok != tt.ok {
+				t.Errorf("restartDelay(%d) = %v, %v; want %v, %v", tt.attempt, d, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPausedTicksAreCounted(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	r := newTestRunner(t, "touch "+marker, nil)
+	r.sched = cron.New()
+	r.sched.Schedule(cron.Every(time.Second), cron.FuncJob(r.run))
+	r.sched.Start()
+	defer r.sched.Stop()
+
+	if !r.setPaused(true, "test") {
+		t.Fatal("setPaused(true) didn't pause")
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if n := r.status().Skipped[skipPaused]; n < 1 {
+		t.Errorf("skipped[%s] = %d after a tick while paused, want at least 1", skipPaused, n)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("command ran while paused")
+	}
+}
+
+func TestScheduledAt(t *testing.T) {
+	r := newTestRunner(t, "true", nil)
+	before := time.Now().Truncate(time.Second)
+	r.run()
+	recs, err := r.history.list(1, 0)
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("history holds %v, %v; want the tick's run", recs, err)
+	}
+	if at := recs[0].ScheduledAt; at.Before(before) || at.After(recs[0].StartedAt) {
+		t.Errorf("scheduled at %v for a tick at %v started at %v", at, before, recs[0].StartedAt)
+	}
+	if rec := r.execute(r.request(newRunID())); !rec.ScheduledAt.IsZero() {
+		t.Errorf("manual run scheduled at %v, want the zero time", rec.ScheduledAt)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const s3UploadTimeout = 2 * time.Minute
+
+// s3Uploader stores the output of each run at
+// s3://<bucket>/<prefix>/<job>/<run_id>.log (".log.gz" when compressed).
+type s3Uploader struct {
+	client   *s3.Client
+	bucket   string
+	prefix   string
+	compress bool
+}
+
+func newS3Uploader(bucket, prefix string, compress bool, timeout time.Duration) (*s3Uploader, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithHTTPClient(newHTTPClient(timeout)))
+	if err != nil {
+		return nil, fmt.Errorf("load AWS configuration: %w", err)
+	}
+	return &s3Uploader{
+		client:   s3.NewFromConfig(awsCfg),
+		bucket:   bucket,
+		prefix:   prefix,
+		compress: compress,
+	}, nil
+}
+
+// upload stores output for the run described by meta. Failures are logged
+// and never affect the run's outcome.
+func (u *s3Uploader) upload(meta RunMeta, output []byte) {
+	key := path.Join(u.prefix, meta.Job, meta.RunID+".log")
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(u.bucket),
+		ContentType: aws.String("text/plain; charset=utf-8"),
+	}
+
+	if u.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(output)
+		if err := zw.Close(); err != nil {
+			logf(LevelWarn, "Warning: failed to compress output of run %s for S3: %v", meta.RunID, err)
+			return
+		}
+		output = buf.Bytes()
+		key += ".gz"
+		input.ContentEncoding = aws.String("gzip")
+	}
+	input.Key = aws.String(key)
+	input.Body = bytes.NewReader(output)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3UploadTimeout)
+	defer cancel()
+	if _, err := u.client.PutObject(ctx, input); err != nil {
+		logf(LevelWarn, "Warning: failed to upload output of run %s to s3://%s/%s: %v", meta.RunID, u.bucket, key, err)
+		return
+	}
+	logf(LevelInfo, "Uploaded output of run %s to s3://%s/%s", meta.RunID, u.bucket, key)
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes made by the
+// stdout and stderr copiers of exec.Cmd.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Values accepted by CRON_PARSER.
+const (
+	parserSeconds  = "seconds"
+	parserStandard = "standard"
+)
+
+// scheduleParser returns the parser selected by CRON_PARSER. Both accept
+// descriptors such as "@every 30s", "@hourly" and "@daily"; they differ in
+// whether expressions have a leading seconds field.
+func scheduleParser(name string) (cron.Parser, error) {
+	switch name {
+	case "", parserSeconds:
+		return cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor), nil
+	case parserStandard:
+		return cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor), nil
+	}
+	return cron.Parser{}, fmt.Errorf("Invalid CRON_PARSER value '%s' (expected %s or %s)", name, parserSeconds, parserStandard)
+}
+
+// scheduleSeparator separates the schedules in a CRON_EXPRESSION that
+// lists several, such as "0 9 * * *; 0 17 * * *". Each becomes its own
+// scheduler entry running the same job.
+const scheduleSeparator = ";"
+
+// splitSchedules splits a decoded CRON_EXPRESSION on scheduleSeparator,
+// dropping empty entries.
+func splitSchedules(expr string) []string {
+	var out []string
+	for _, s := range strings.Split(expr, scheduleSeparator) {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// normalizeSchedule prepares a decoded CRON_EXPRESSION for the seconds-enabled
+// parser. Standard 5-field expressions get a leading "0" seconds field so they
+// fire at the top of the minute; 6-field expressions and descriptors such as
+// "@every 1h30m" or "@daily" are passed through untouched.
+func normalizeSchedule(expr string) string {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		return expr
+	}
+	if fields := strings.Fields(expr); len(fields) == 5 {
+		return "0 " + strings.Join(fields, " ")
+	}
+	return expr
+}
+
+// readScheduleFile returns the schedule held in the CRON_EXPRESSION_FILE at
+// path, as plain text. Blank lines and lines starting with # are ignored,
+// so the file can carry a comment; every other line is a schedule, and
+// the lines are joined with scheduleSeparator.
+func readScheduleFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var exprs []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		exprs = append(exprs, line)
+	}
+	if len(exprs) == 0 {
+		return "", fmt.Errorf("'%s' is empty", path)
+	}
+	return strings.Join(exprs, scheduleSeparator), nil
+}
+
+// printNextRuns writes the next n times the configured schedules fire, in
+// CRON_TZ or the local timezone, for --next. With several schedules the
+// times are merged, and a time two of them share is listed once. Host
+// jitter is included; random jitter can't be, so it is only mentioned.
+func printNextRuns(w io.Writer, cfg *config, n int) error {
+	if len(cfg.schedules) == 0 {
+		return fmt.Errorf("CRON_EXPRESSION environment variable (or --cron, or CRON_EXPRESSION_FILE) is required")
+	}
+	scheds := make([]cron.Schedule, 0, len(cfg.schedules))
+	random := cfg.jitter > 0 && cfg.jitterMode == jitterRandom
+	for _, spec := range cfg.schedules {
+		parse := cfg.parseSchedule
+		if random {
+			parse = cfg.parser.Parse
+		}
+		sched, err := parse(spec)
+		if err != nil {
+			return err
+		}
+		scheds = append(scheds, sched)
+	}
+	loc := cfg.location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	fmt.Fprintf(w, "Next %d runs of '%s' (%s parser, %s):\n", n, cfg.schedule, cfg.parserName, loc)
+	switch {
+	case random:
+		fmt.Fprintf(w, "  (each delayed by a random jitter of up to %s)\n", cfg.jitter)
+	case cfg.jitter > 0:
+		fmt.Fprintf(w, "  (including the %s host jitter of instance %s)\n", cfg.jitterOffset, cfg.instanceID)
+	}
+	t := time.Now().In(loc)
+	for i := 0; i < n; i++ {
+		var next time.Time
+		for _, sched := range scheds {
+			if st := sched.Next(t); !st.IsZero() && (next.IsZero() || st.Before(next)) {
+				next = st
+			}
+		}
+		t = next
+		if t.IsZero() {
+			fmt.Fprintln(w, "  (no further runs)")
+			break
+		}
+		fmt.Fprintf(w, "  %s\n", t.Format("Mon 2006-01-02 15:04:05 MST"))
+	}
+	return nil
+}
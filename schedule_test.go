@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeSchedule(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"@every 30s", "@every 30s"},
+		{"@every 2h", "@every 2h"},
+		{"@every 1h30m", "@every 1h30m"},
+		{"  @every 1h30m ", "@every 1h30m"},
+		{"@daily", "@daily"},
+		{"@hourly", "@hourly"},
+		{"@midnight", "@midnight"},
+		{"*/5 * * * *", "0 */5 * * * *"},
+		{"0 9 * * MON-FRI", "0 0 9 * * MON-FRI"},
+		{" 30  2 * *   * ", "0 30 2 * * *"},
+		{"15 */5 * * * *", "15 */5 * * * *"},
+		{"0 0 9 * * MON-FRI", "0 0 9 * * MON-FRI"},
+		{"* * * *", "* * * *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if got := normalizeSchedule(tt.expr); got != tt.want {
+				t.Errorf("normalizeSchedule(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleParserDescriptors(t *testing.T) {
+	from := time.Date(2026, 1, 1, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"@daily", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"@every 90s", from.Add(90 * time.Second)},
+		{"@every 1h30m", from.Add(90 * time.Minute)},
+	}
+	for _, name := range []string{"", parserSeconds, parserStandard} {
+		p, err := scheduleParser(name)
+		if err != nil {
+			t.Fatalf("scheduleParser(%q): %v", name, err)
+		}
+		if name == "" {
+			name = "default"
+		}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.expr, func(t *testing.T) {
+				sched, err := p.Parse(tt.expr)
+				if err != nil {
+					t.Fatalf("Parse(%q): %v", tt.expr, err)
+				}
+				if got := sched.Next(from); !got.Equal(tt.want) {
+					t.Errorf("Next(%v) = %v, want %v", from, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestScheduleParserFields(t *testing.T) {
+	seconds, err := scheduleParser(parserSeconds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standard, err := scheduleParser(parserStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2026, 1, 1, 10, 30, 15, 0, time.UTC)
+	if sched, err := seconds.Parse("*/20 * * * * *"); err != nil {
+		t.Errorf("seconds parser rejected a 6-field expression: %v", err)
+	} else if got, want := sched.Next(from), from.Add(5*time.Second); !got.Equal(want) {
+		t.Errorf("seconds parser: Next(%v) = %v, want %v", from, got, want)
+	}
+	if _, err := seconds.Parse("*/5 * * * *"); err == nil {
+		t.Error("seconds parser accepted a 5-field expression that wasn't normalized")
+	}
+	if sched, err := standard.Parse("*/5 * * * *"); err != nil {
+		t.Errorf("standard parser rejected a 5-field expression: %v", err)
+	} else if got, want := sched.Next(from), time.Date(2026, 1, 1, 10, 35, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("standard parser: Next(%v) = %v, want %v", from, got, want)
+	}
+	if _, err := standard.Parse("*/20 * * * * *"); err == nil {
+		t.Error("standard parser accepted a 6-field expression")
+	}
+}
+
+func TestScheduleParserInvalid(t *testing.T) {
+	if _, err := scheduleParser("quartz"); err == nil {
+		t.Error("scheduleParser accepted an unknown CRON_PARSER value")
+	}
+}
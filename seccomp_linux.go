@@ -0,0 +1,496 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const seccompSupported = true
+
+// seccompProfile is the Docker/OCI seccomp profile format read from
+// CRON_SECCOMP_PROFILE. The architectures and archMap fields are ignored:
+// the filter only covers the architecture cronrunner was built for.
+type seccompProfile struct {
+	DefaultAction   string        `json:"defaultAction"`
+	DefaultErrnoRet *uint16       `json:"defaultErrnoRet"`
+	Syscalls        []seccompRule `json:"syscalls"`
+}
+
+type seccompRule struct {
+	Name     string           `json:"name"`
+	Names    []string         `json:"names"`
+	Action   string           `json:"action"`
+	ErrnoRet *uint16          `json:"errnoRet"`
+	Args     []seccompArg     `json:"args"`
+	Includes seccompCondition `json:"includes"`
+	Excludes seccompCondition `json:"excludes"`
+}
+
+type seccompArg struct {
+	Index    uint   `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo"`
+	Op       string `json:"op"`
+}
+
+// seccompCondition is a rule's includes/excludes condition.
+type seccompCondition struct {
+	Arches    []string `json:"arches"`
+	Caps      []string `json:"caps"`
+	MinKernel string   `json:"minKernel"`
+}
+
+// seccompDefaultPreset is CRON_SECCOMP_PRESET=default: everything is
+// allowed except syscalls that administer the machine, load kernel code,
+// inspect other processes or escape namespaces, which fail with EPERM.
+var seccompDefaultPreset = seccompProfile{
+	DefaultAction: "SCMP_ACT_ALLOW",
+	Syscalls: []seccompRule{{
+		Names: []string{
+			"acct", "add_key", "bpf", "clock_adjtime", "clock_settime", "create_module",
+			"delete_module", "finit_module", "fsconfig", "fsmount", "fsopen", "fspick",
+			"get_kernel_syms", "init_module", "ioperm", "iopl", "kcmp", "kexec_file_load",
+			"kexec_load", "keyctl", "lookup_dcookie", "mount", "mount_setattr", "move_mount",
+			"nfsservctl", "open_by_handle_at", "open_tree", "perf_event_open", "pivot_root",
+			"process_vm_readv", "process_vm_writev", "ptrace", "query_module", "quotactl",
+			"reboot", "request_key", "setns", "settimeofday", "swapoff", "swapon", "_sysctl",
+			"syslog", "umount", "umount2", "unshare", "uselib", "userfaultfd", "ustat", "vhangup",
+		},
+		Action: "SCMP_ACT_ERRNO",
+	}},
+}
+
+// loadSeccomp compiles CRON_SECCOMP_PROFILE or CRON_SECCOMP_PRESET into the
+// encoded BPF program handed to the privilege helper. Rules whose
+// includes/excludes depend on capabilities are resolved against the
+// capabilities the command will have.
+func loadSeccomp(cfg *config, profilePath, preset string) (string, error) {
+	if !cfg.noNewPrivs {
+		if err := checkCapability(unix.CAP_SYS_ADMIN, "CAP_SYS_ADMIN"); err != nil {
+			return "", fmt.Errorf("%v (or set CRON_NO_NEW_PRIVS=true)", err)
+		}
+	}
+	var data []byte
+	profile := seccompDefaultPreset
+	if profilePath != "" {
+		var err error
+		if data, err = os.ReadFile(profilePath); err != nil {
+			return "", err
+		}
+		profile = seccompProfile{}
+		if err := json.Unmarshal(data, &profile); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxErr):
+				return "", fmt.Errorf("%v at line %d", err, lineAt(data, syntaxErr.Offset))
+			case errors.As(err, &typeErr):
+				return "", fmt.Errorf("%v at line %d", err, lineAt(data, typeErr.Offset))
+			}
+			return "", err
+		}
+	} else if preset != "default" {
+		return "", fmt.Errorf("unknown preset '%s': must be default", preset)
+	}
+	prog, err := compileSeccomp(&profile, data, commandCapabilities(cfg))
+	if err != nil {
+		return "", err
+	}
+	return encodeSeccomp(prog), nil
+}
+
+// lineAt returns the 1-based line of offset in data.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// lineOf returns the line of the first occurrence of the JSON string s in
+// data, for error messages; 0 when data is a built-in preset.
+func lineOf(data []byte, s string) int {
+	i := bytes.Index(data, []byte(strconv.Quote(s)))
+	if i < 0 {
+		return 0
+	}
+	return lineAt(data, int64(i))
+}
+
+func seccompError(data []byte, what, value string) error {
+	if line := lineOf(data, value); line > 0 {
+		return fmt.Errorf("unknown %s %s at line %d", what, value, line)
+	}
+	return fmt.Errorf("unknown %s %s", what, value)
+}
+
+// commandCapabilities reports whether the command will hold a capability:
+// cronrunner's own, minus CRON_DROP_CAPS, and none once CRON_RUN_AS_USER
+// switches to a non-root user.
+func commandCapabilities(cfg *config) func(c int) bool {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return func(int) bool { return false }
+	}
+	return func(c int) bool {
+		if (cfg.runAs != nil && cfg.runAs.uid != 0) || slices.Contains(cfg.dropCaps, c) || c < 0 || c > 63 {
+			return false
+		}
+		return data[c/32].Effective&(1<<(c%32)) != 0
+	}
+}
+
+// seccompAction translates a profile action into a SECCOMP_RET value.
+func seccompAction(data []byte, action string, errnoRet *uint16) (uint32, error) {
+	errno := uint32(unix.EPERM)
+	if errnoRet != nil {
+		errno = uint32(*errnoRet)
+	}
+	switch action {
+	case "SCMP_ACT_ALLOW":
+		return unix.SECCOMP_RET_ALLOW, nil
+	case "SCMP_ACT_ERRNO":
+		return unix.SECCOMP_RET_ERRNO | errno, nil
+	case "SCMP_ACT_KILL", "SCMP_ACT_KILL_THREAD":
+		return unix.SECCOMP_RET_KILL_THREAD, nil
+	case "SCMP_ACT_KILL_PROCESS":
+		return unix.SECCOMP_RET_KILL_PROCESS, nil
+	case "SCMP_ACT_TRAP":
+		return unix.SECCOMP_RET_TRAP, nil
+	case "SCMP_ACT_TRACE":
+		return unix.SECCOMP_RET_TRACE | errno, nil
+	case "SCMP_ACT_LOG":
+		return unix.SECCOMP_RET_LOG, nil
+	}
+	return 0, seccompError(data, "action", action)
+}
+
+// applies evaluates a rule's includes and excludes conditions.
+func (r *seccompRule) applies(hasCap func(int) bool, kernel [2]int) bool {
+	capHeld := func(name string) bool {
+		c, ok := capabilityNames[strings.TrimPrefix(strings.ToUpper(name), "CAP_")]
+		return ok && hasCap(c)
+	}
+	in, ex := r.Includes, r.Excludes
+	if len(in.Arches) > 0 && !slices.Contains(in.Arches, runtime.GOARCH) {
+		return false
+	}
+	if slices.Contains(ex.Arches, runtime.GOARCH) {
+		return false
+	}
+	for _, c := range in.Caps {
+		if !capHeld(c) {
+			return false
+		}
+	}
+	if slices.ContainsFunc(ex.Caps, capHeld) {
+		return false
+	}
+	if in.MinKernel != "" && !kernelAtLeast(kernel, in.MinKernel) {
+		return false
+	}
+	if ex.MinKernel != "" && kernelAtLeast(kernel, ex.MinKernel) {
+		return false
+	}
+	return true
+}
+
+// kernelVersion returns the running kernel's major and minor version.
+func kernelVersion() [2]int {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return [2]int{}
+	}
+	return parseKernelVersion(unix.ByteSliceToString(uts.Release[:]))
+}
+
+func parseKernelVersion(s string) [2]int {
+	var v [2]int
+	for i, f := range strings.SplitN(s, ".", 3) {
+		if i > 1 {
+			break
+		}
+		n := strings.IndexFunc(f, func(r rune) bool { return r < '0' || r > '9' })
+		if n >= 0 {
+			f = f[:n]
+		}
+		v[i], _ = strconv.Atoi(f)
+	}
+	return v
+}
+
+func kernelAtLeast(kernel [2]int, min string) bool {
+	m := parseKernelVersion(min)
+	return kernel[0] > m[0] || (kernel[0] == m[0] && kernel[1] >= m[1])
+}
+
+// Offsets into struct seccomp_data.
+const (
+	seccompDataNr   = 0
+	seccompDataArch = 4
+	seccompDataArgs = 16
+)
+
+// bpfProgram assembles a classic BPF program with forward jumps to labels.
+type bpfProgram struct {
+	insns  []unix.SockFilter
+	labels []int
+	jumps  []bpfJump
+}
+
+type bpfJump struct {
+	insn   int
+	jt, jf int // label, or -1 to fall through
+}
+
+func (p *bpfProgram) label() int {
+	p.labels = append(p.labels, -1)
+	return len(p.labels) - 1
+}
+
+func (p *bpfProgram) bind(l int) { p.labels[l] = len(p.insns) }
+
+func (p *bpfProgram) stmt(code uint16, k uint32) {
+	p.insns = append(p.insns, unix.SockFilter{Code: code, K: k})
+}
+
+func (p *bpfProgram) jump(op uint16, k uint32, jt, jf int) {
+	p.jumps = append(p.jumps, bpfJump{insn: len(p.insns), jt: jt, jf: jf})
+	p.stmt(unix.BPF_JMP|op|unix.BPF_K, k)
+}
+
+func (p *bpfProgram) load(off uint32) { p.stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, off) }
+func (p *bpfProgram) ret(v uint32)    { p.stmt(unix.BPF_RET|unix.BPF_K, v) }
+
+func (p *bpfProgram) resolve() ([]unix.SockFilter, error) {
+	offset := func(from, l int) (uint8, error) {
+		if l < 0 {
+			return 0, nil
+		}
+		d := p.labels[l] - from - 1
+		if d < 0 || d > 255 {
+			return 0, fmt.Errorf("rule too large for a BPF jump")
+		}
+		return uint8(d), nil
+	}
+	for _, j := range p.jumps {
+		var err error
+		ins := &p.insns[j.insn]
+		if ins.Jt, err = offset(j.insn, j.jt); err != nil {
+			return nil, err
+		}
+		if ins.Jf, err = offset(j.insn, j.jf); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.insns) > unix.BPF_MAXINSNS {
+		return nil, fmt.Errorf("filter has %d instructions, more than the kernel's limit of %d", len(p.insns), unix.BPF_MAXINSNS)
+	}
+	return p.insns, nil
+}
+
+// compileSeccomp turns profile into a BPF program. data is the profile's
+// source, used to point errors at a line. Rules are matched in order and
+// the first match decides; calls matching none get the default action.
+func compileSeccomp(profile *seccompProfile, data []byte, hasCap func(int) bool) ([]unix.SockFilter, error) {
+	defaultRet, err := seccompAction(data, profile.DefaultAction, profile.DefaultErrnoRet)
+	if err != nil {
+		return nil, err
+	}
+	kernel := kernelVersion()
+
+	p := &bpfProgram{}
+	// Calls made under another architecture (32-bit compat, x32) have
+	// different numbers and would slip past the rules, so they are killed.
+	p.load(seccompDataArch)
+	p.insns = append(p.insns, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 1, K: seccompArch})
+	p.ret(unix.SECCOMP_RET_KILL_PROCESS)
+	p.load(seccompDataNr)
+	if seccompX32Bit != 0 {
+		p.insns = append(p.insns, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K, Jf: 1, K: seccompX32Bit})
+		p.ret(unix.SECCOMP_RET_KILL_PROCESS)
+	}
+
+	for i := range profile.Syscalls {
+		rule := &profile.Syscalls[i]
+		names := rule.Names
+		if rule.Name != "" {
+			names = append([]string{rule.Name}, names...)
+		}
+		ret, err := seccompAction(data, rule.Action, rule.ErrnoRet)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range rule.Args {
+			if a.Index > 5 {
+				return nil, fmt.Errorf("invalid argument index %d in rule %d: syscalls have 6 arguments", a.Index, i+1)
+			}
+			if !validSeccompOp(a.Op) {
+				return nil, seccompError(data, "comparison", a.Op)
+			}
+		}
+		for _, name := range names {
+			if !seccompKnownSyscalls[name] {
+				return nil, seccompError(data, "syscall name", name)
+			}
+		}
+		if !rule.applies(hasCap, kernel) {
+			continue
+		}
+		// As in Docker, arguments with distinct indexes must all match;
+		// several comparisons of the same argument are alternatives.
+		argSets := [][]seccompArg{rule.Args}
+		if hasDuplicateIndex(rule.Args) {
+			argSets = argSets[:0]
+			for _, a := range rule.Args {
+				argSets = append(argSets, []seccompArg{a})
+			}
+		}
+		for _, name := range names {
+			nr, ok := seccompSyscalls[name]
+			if !ok {
+				continue // exists, but not on this architecture
+			}
+			for _, args := range argSets {
+				emitSeccompRule(p, nr, args, ret)
+			}
+		}
+	}
+	p.ret(defaultRet)
+	return p.resolve()
+}
+
+func validSeccompOp(op string) bool {
+	switch op {
+	case "SCMP_CMP_EQ", "SCMP_CMP_NE", "SCMP_CMP_LT", "SCMP_CMP_LE",
+		"SCMP_CMP_GT", "SCMP_CMP_GE", "SCMP_CMP_MASKED_EQ":
+		return true
+	}
+	return false
+}
+
+func hasDuplicateIndex(args []seccompArg) bool {
+	seen := make(map[uint]bool, len(args))
+	for _, a := range args {
+		if seen[a.Index] {
+			return true
+		}
+		seen[a.Index] = true
+	}
+	return false
+}
+
+// emitSeccompRule returns ret when the syscall is nr and every comparison
+// in args holds. The accumulator holds the syscall number on entry and
+// exit. Arguments are 64 bits wide and compared as two little-endian
+// 32-bit words, high word first.
+func emitSeccompRule(p *bpfProgram, nr uint32, args []seccompArg, ret uint32) {
+	if len(args) == 0 {
+		p.insns = append(p.insns, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jf: 1, K: nr})
+		p.ret(ret)
+		return
+	}
+	fail := p.label()
+	p.jump(unix.BPF_JEQ, nr, -1, fail)
+	for _, a := range args {
+		pass := p.label()
+		lo := uint32(seccompDataArgs + 8*a.Index)
+		hi := lo + 4
+		v, v2 := a.Value, a.ValueTwo
+		switch a.Op {
+		case "SCMP_CMP_EQ":
+			p.load(hi)
+			p.jump(unix.BPF_JEQ, uint32(v>>32), -1, fail)
+			p.load(lo)
+			p.jump(unix.BPF_JEQ, uint32(v), -1, fail)
+		case "SCMP_CMP_NE":
+			p.load(hi)
+			p.jump(unix.BPF_JEQ, uint32(v>>32), -1, pass)
+			p.load(lo)
+			p.jump(unix.BPF_JEQ, uint32(v), fail, -1)
+		case "SCMP_CMP_MASKED_EQ":
+			p.load(hi)
+			p.stmt(unix.BPF_ALU|unix.BPF_AND|unix.BPF_K, uint32(v>>32))
+			p.jump(unix.BPF_JEQ, uint32(v2>>32), -1, fail)
+			p.load(lo)
+			p.stmt(unix.BPF_ALU|unix.BPF_AND|unix.BPF_K, uint32(v))
+			p.jump(unix.BPF_JEQ, uint32(v2), -1, fail)
+		case "SCMP_CMP_GT", "SCMP_CMP_GE":
+			p.load(hi)
+			p.jump(unix.BPF_JGT, uint32(v>>32), pass, -1)
+			p.jump(unix.BPF_JEQ, uint32(v>>32), -1, fail)
+			p.load(lo)
+			op := uint16(unix.BPF_JGT)
+			if a.Op == "SCMP_CMP_GE" {
+				op = unix.BPF_JGE
+			}
+			p.jump(op, uint32(v), -1, fail)
+		case "SCMP_CMP_LT", "SCMP_CMP_LE":
+			p.load(hi)
+			p.jump(unix.BPF_JGT, uint32(v>>32), fail, -1)
+			p.jump(unix.BPF_JEQ, uint32(v>>32), -1, pass)
+			p.load(lo)
+			op := uint16(unix.BPF_JGE)
+			if a.Op == "SCMP_CMP_LE" {
+				op = unix.BPF_JGT
+			}
+			p.jump(op, uint32(v), fail, -1)
+		}
+		p.bind(pass)
+	}
+	p.ret(ret)
+	p.bind(fail)
+	p.load(seccompDataNr)
+}
+
+// encodeSeccomp and decodeSeccomp carry the compiled program to the
+// privilege helper as a command-line argument.
+func encodeSeccomp(prog []unix.SockFilter) string {
+	b := make([]byte, 0, 8*len(prog))
+	for _, f := range prog {
+		b = binary.LittleEndian.AppendUint16(b, f.Code)
+		b = append(b, f.Jt, f.Jf)
+		b = binary.LittleEndian.AppendUint32(b, f.K)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func decodeSeccomp(s string) ([]unix.SockFilter, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b)%8 != 0 || len(b) == 0 {
+		return nil, fmt.Errorf("invalid seccomp program")
+	}
+	prog := make([]unix.SockFilter, len(b)/8)
+	for i := range prog {
+		c := b[8*i:]
+		prog[i] = unix.SockFilter{Code: binary.LittleEndian.Uint16(c), Jt: c[2], Jf: c[3], K: binary.LittleEndian.Uint32(c[4:])}
+	}
+	return prog, nil
+}
+
+// installSeccomp loads the encoded filter for the calling thread; it is
+// inherited across exec. Without no_new_privs the kernel requires
+// CAP_SYS_ADMIN.
+func installSeccomp(encoded string) error {
+	prog, err := decodeSeccomp(encoded)
+	if err != nil {
+		return err
+	}
+	fprog := unix.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	return unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&fprog)), 0, 0)
+}
@@ -0,0 +1,122 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+// seccompKnownSyscalls holds the syscall names of every Linux architecture,
+// so profiles written for several architectures (like Docker's default one)
+// load everywhere: names that exist elsewhere but not on this architecture
+// are skipped, and only names that exist nowhere are reported as errors.
+var seccompKnownSyscalls = map[string]bool{
+	"_llseek": true, "_newselect": true, "_sysctl": true, "accept": true, "accept4": true,
+	"access": true, "acct": true, "add_key": true, "adjtimex": true, "afs_syscall": true,
+	"alarm": true, "arch_prctl": true, "arch_specific_syscall": true, "arm_fadvise64_64": true,
+	"arm_sync_file_range": true, "bdflush": true, "bind": true, "bpf": true, "break": true,
+	"brk": true, "cachectl": true, "cacheflush": true, "cachestat": true, "capget": true,
+	"capset": true, "chdir": true, "chmod": true, "chown": true, "chown32": true, "chroot": true,
+	"clock_adjtime": true, "clock_adjtime64": true, "clock_getres": true, "clock_getres_time64": true,
+	"clock_gettime": true, "clock_gettime64": true, "clock_nanosleep": true,
+	"clock_nanosleep_time64": true, "clock_settime": true, "clock_settime64": true, "clone": true,
+	"clone3": true, "close": true, "close_range": true, "connect": true, "copy_file_range": true,
+	"creat": true, "create_module": true, "delete_module": true, "dup": true, "dup2": true,
+	"dup3": true, "epoll_create": true, "epoll_create1": true, "epoll_ctl": true,
+	"epoll_ctl_old": true, "epoll_pwait": true, "epoll_pwait2": true, "epoll_wait": true,
+	"epoll_wait_old": true, "eventfd": true, "eventfd2": true, "execv": true, "execve": true,
+	"execveat": true, "exit": true, "exit_group": true, "faccessat": true, "faccessat2": true,
+	"fadvise64": true, "fadvise64_64": true, "fallocate": true, "fanotify_init": true,
+	"fanotify_mark": true, "fchdir": true, "fchmod": true, "fchmodat": true, "fchmodat2": true,
+	"fchown": true, "fchown32": true, "fchownat": true, "fcntl": true, "fcntl64": true,
+	"fdatasync": true, "fgetxattr": true, "finit_module": true, "flistxattr": true, "flock": true,
+	"fork": true, "fremovexattr": true, "fsconfig": true, "fsetxattr": true, "fsmount": true,
+	"fsopen": true, "fspick": true, "fstat": true, "fstat64": true, "fstatat64": true,
+	"fstatfs": true, "fstatfs64": true, "fsync": true, "ftime": true, "ftruncate": true,
+	"ftruncate64": true, "futex": true, "futex_requeue": true, "futex_time64": true,
+	"futex_wait": true, "futex_waitv": true, "futex_wake": true, "futimesat": true,
+	"get_kernel_syms": true, "get_mempolicy": true, "get_robust_list": true, "get_thread_area": true,
+	"getcpu": true, "getcwd": true, "getdents": true, "getdents64": true, "getdomainname": true,
+	"getegid": true, "getegid32": true, "geteuid": true, "geteuid32": true, "getgid": true,
+	"getgid32": true, "getgroups": true, "getgroups32": true, "getitimer": true, "getpagesize": true,
+	"getpeername": true, "getpgid": true, "getpgrp": true, "getpid": true, "getpmsg": true,
+	"getppid": true, "getpriority": true, "getrandom": true, "getresgid": true, "getresgid32": true,
+	"getresuid": true, "getresuid32": true, "getrlimit": true, "getrusage": true, "getsid": true,
+	"getsockname": true, "getsockopt": true, "gettid": true, "gettimeofday": true, "getuid": true,
+	"getuid32": true, "getxattr": true, "getxattrat": true, "gtty": true, "idle": true,
+	"init_module": true, "inotify_add_watch": true, "inotify_init": true, "inotify_init1": true,
+	"inotify_rm_watch": true, "io_cancel": true, "io_destroy": true, "io_getevents": true,
+	"io_pgetevents": true, "io_pgetevents_time64": true, "io_setup": true, "io_submit": true,
+	"io_uring_enter": true, "io_uring_register": true, "io_uring_setup": true, "ioctl": true,
+	"ioperm": true, "iopl": true, "ioprio_get": true, "ioprio_set": true, "ipc": true, "kcmp": true,
+	"kern_features": true, "kexec_file_load": true, "kexec_load": true, "keyctl": true, "kill": true,
+	"landlock_add_rule": true, "landlock_create_ruleset": true, "landlock_restrict_self": true,
+	"lchown": true, "lchown32": true, "lgetxattr": true, "link": true, "linkat": true, "listen": true,
+	"listmount": true, "listxattr": true, "listxattrat": true, "llistxattr": true, "lock": true,
+	"lookup_dcookie": true, "lremovexattr": true, "lseek": true, "lsetxattr": true,
+	"lsm_get_self_attr": true, "lsm_list_modules": true, "lsm_set_self_attr": true, "lstat": true,
+	"lstat64": true, "madvise": true, "map_shadow_stack": true, "mbind": true, "membarrier": true,
+	"memfd_create": true, "memfd_secret": true, "memory_ordering": true, "migrate_pages": true,
+	"mincore": true, "mkdir": true, "mkdirat": true, "mknod": true, "mknodat": true, "mlock": true,
+	"mlock2": true, "mlockall": true, "mmap": true, "mmap2": true, "modify_ldt": true, "mount": true,
+	"mount_setattr": true, "move_mount": true, "move_pages": true, "mprotect": true, "mpx": true,
+	"mq_getsetattr": true, "mq_notify": true, "mq_open": true, "mq_timedreceive": true,
+	"mq_timedreceive_time64": true, "mq_timedsend": true, "mq_timedsend_time64": true,
+	"mq_unlink": true, "mremap": true, "mseal": true, "msgctl": true, "msgget": true, "msgrcv": true,
+	"msgsnd": true, "msync": true, "multiplexer": true, "munlock": true, "munlockall": true,
+	"munmap": true, "name_to_handle_at": true, "nanosleep": true, "newfstatat": true,
+	"nfsservctl": true, "nice": true, "oldfstat": true, "oldlstat": true, "oldolduname": true,
+	"oldstat": true, "olduname": true, "open": true, "open_by_handle_at": true, "open_tree": true,
+	"open_tree_attr": true, "openat": true, "openat2": true, "pause": true, "pciconfig_iobase": true,
+	"pciconfig_read": true, "pciconfig_write": true, "perf_event_open": true, "perfctr": true,
+	"personality": true, "pidfd_getfd": true, "pidfd_open": true, "pidfd_send_signal": true,
+	"pipe": true, "pipe2": true, "pivot_root": true, "pkey_alloc": true, "pkey_free": true,
+	"pkey_mprotect": true, "poll": true, "ppoll": true, "ppoll_time64": true, "prctl": true,
+	"pread64": true, "preadv": true, "preadv2": true, "prlimit64": true, "process_madvise": true,
+	"process_mrelease": true, "process_vm_readv": true, "process_vm_writev": true, "prof": true,
+	"profil": true, "pselect6": true, "pselect6_time64": true, "ptrace": true, "putpmsg": true,
+	"pwrite64": true, "pwritev": true, "pwritev2": true, "query_module": true, "quotactl": true,
+	"quotactl_fd": true, "read": true, "readahead": true, "readdir": true, "readlink": true,
+	"readlinkat": true, "readv": true, "reboot": true, "recv": true, "recvfrom": true,
+	"recvmmsg": true, "recvmmsg_time64": true, "recvmsg": true, "remap_file_pages": true,
+	"removexattr": true, "removexattrat": true, "rename": true, "renameat": true, "renameat2": true,
+	"request_key": true, "reserved177": true, "reserved193": true, "reserved221": true,
+	"reserved82": true, "restart_syscall": true, "riscv_flush_icache": true, "riscv_hwprobe": true,
+	"rmdir": true, "rseq": true, "rt_sigaction": true, "rt_sigpending": true, "rt_sigprocmask": true,
+	"rt_sigqueueinfo": true, "rt_sigreturn": true, "rt_sigsuspend": true, "rt_sigtimedwait": true,
+	"rt_sigtimedwait_time64": true, "rt_tgsigqueueinfo": true, "rtas": true,
+	"s390_guarded_storage": true, "s390_pci_mmio_read": true, "s390_pci_mmio_write": true,
+	"s390_runtime_instr": true, "s390_sthyi": true, "sched_get_affinity": true,
+	"sched_get_priority_max": true, "sched_get_priority_min": true, "sched_getaffinity": true,
+	"sched_getattr": true, "sched_getparam": true, "sched_getscheduler": true,
+	"sched_rr_get_interval": true, "sched_rr_get_interval_time64": true, "sched_set_affinity": true,
+	"sched_setaffinity": true, "sched_setattr": true, "sched_setparam": true,
+	"sched_setscheduler": true, "sched_yield": true, "seccomp": true, "security": true,
+	"select": true, "semctl": true, "semget": true, "semop": true, "semtimedop": true,
+	"semtimedop_time64": true, "send": true, "sendfile": true, "sendfile64": true, "sendmmsg": true,
+	"sendmsg": true, "sendto": true, "set_mempolicy": true, "set_mempolicy_home_node": true,
+	"set_robust_list": true, "set_thread_area": true, "set_tid_address": true, "setdomainname": true,
+	"setfsgid": true, "setfsgid32": true, "setfsuid": true, "setfsuid32": true, "setgid": true,
+	"setgid32": true, "setgroups": true, "setgroups32": true, "sethostname": true, "setitimer": true,
+	"setns": true, "setpgid": true, "setpriority": true, "setregid": true, "setregid32": true,
+	"setresgid": true, "setresgid32": true, "setresuid": true, "setresuid32": true, "setreuid": true,
+	"setreuid32": true, "setrlimit": true, "setsid": true, "setsockopt": true, "settimeofday": true,
+	"setuid": true, "setuid32": true, "setxattr": true, "setxattrat": true, "sgetmask": true,
+	"shmat": true, "shmctl": true, "shmdt": true, "shmget": true, "shutdown": true, "sigaction": true,
+	"sigaltstack": true, "signal": true, "signalfd": true, "signalfd4": true, "sigpending": true,
+	"sigprocmask": true, "sigreturn": true, "sigsuspend": true, "socket": true, "socketcall": true,
+	"socketpair": true, "splice": true, "spu_create": true, "spu_run": true, "ssetmask": true,
+	"stat": true, "stat64": true, "statfs": true, "statfs64": true, "statmount": true, "statx": true,
+	"stime": true, "stty": true, "subpage_prot": true, "swapcontext": true, "swapoff": true,
+	"swapon": true, "switch_endian": true, "symlink": true, "symlinkat": true, "sync": true,
+	"sync_file_range": true, "sync_file_range2": true, "syncfs": true, "sys_debug_setcontext": true,
+	"syscall": true, "syscall_mask": true, "sysfs": true, "sysinfo": true, "syslog": true,
+	"sysmips": true, "tee": true, "tgkill": true, "time": true, "timer_create": true,
+	"timer_delete": true, "timer_getoverrun": true, "timer_gettime": true, "timer_gettime64": true,
+	"timer_settime": true, "timer_settime64": true, "timerfd": true, "timerfd_create": true,
+	"timerfd_gettime": true, "timerfd_gettime64": true, "timerfd_settime": true,
+	"timerfd_settime64": true, "times": true, "tkill": true, "truncate": true, "truncate64": true,
+	"tuxcall": true, "ugetrlimit": true, "ulimit": true, "umask": true, "umount": true,
+	"umount2": true, "uname": true, "unlink": true, "unlinkat": true, "unshare": true,
+	"unused109": true, "unused150": true, "unused18": true, "unused28": true, "unused59": true,
+	"unused84": true, "uretprobe": true, "uselib": true, "userfaultfd": true, "ustat": true,
+	"utime": true, "utimensat": true, "utimensat_time64": true, "utimes": true, "utrap_install": true,
+	"vfork": true, "vhangup": true, "vm86": true, "vm86old": true, "vmsplice": true, "vserver": true,
+	"wait4": true, "waitid": true, "waitpid": true, "write": true, "writev": true,
+}
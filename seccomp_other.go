@@ -0,0 +1,15 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+import "errors"
+
+const seccompSupported = false
+
+func loadSeccomp(cfg *config, profilePath, preset string) (string, error) {
+	return "", errors.New("seccomp is only supported on Linux (amd64 and arm64)")
+}
+
+func installSeccomp(encoded string) error {
+	return errors.New("seccomp is only supported on Linux (amd64 and arm64)")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// selfTestTimeout bounds each network check of CRONRUNNER_SELFTEST.
+const selfTestTimeout = 10 * time.Second
+
+// Settings are listed in the self-test summary if their name starts with
+// one of selfTestPrefixes or is one of selfTestNames; values of names
+// containing one of redactedWords are hidden.
+var (
+	selfTestPrefixes = []string{"CRON_", "CRONRUNNER_", "NOTIFY_", "HEALTH_", "HISTORY_", "NATS_", "K8S_JOB_", "LAMBDA_", "S3_OUTPUT_", "VAULT_", "CLOUDWATCH_", "REDIS_"}
+	selfTestNames    = []string{"LOG_FILE", "LOG_SEPARATOR_FORMAT", "RESTART_ON_FAIL", "ALLOW_CONCURRENT", "LIVENESS_MAX_AGE_SEC", "AWS_REGION"}
+	redactedWords    = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIAL", "STDIN_DATA"}
+)
+
+// selfTest checks the configuration and the integrations it names without
+// starting the scheduler, for CRONRUNNER_SELFTEST, and writes a report to
+// w. It returns the exit status: 0 if every check passed, 1 otherwise.
+func selfTest(w io.Writer) int {
+	failed := false
+	check := func(name string, err error, detail string) {
+		switch {
+		case err != nil:
+			failed = true
+			fmt.Fprintf(w, "[FAIL] %s: %v\n", name, err)
+		case detail != "":
+			fmt.Fprintf(w, "[ OK ] %s: %s\n", name, detail)
+		default:
+			fmt.Fprintf(w, "[ OK ] %s\n", name)
+		}
+	}
+	skip := func(name, reason string) {
+		fmt.Fprintf(w, "[SKIP] %s: %s\n", name, reason)
+	}
+
+	fmt.Fprintln(w, "cronrunner self-test")
+	cfg, err := loadConfig()
+	check("configuration", err, "")
+	if err == nil {
+		useHTTPProxy(cfg)
+		switch {
+		case len(cfg.schedules) > 0:
+			check("schedule", nil, fmt.Sprintf("'%s' (%s parser), next run %s", cfg.schedule, cfg.parserName, nextRunTime(cfg)))
+		case cfg.natsSubject != "":
+			skip("schedule", "NATS queue mode")
+		}
+		if cfg.workDir != "" {
+			check("CRON_WORKDIR", nil, cfg.workDir)
+		}
+		if cfg.logFilePath != "" {
+			check("LOG_FILE directory", checkWritableDir(filepath.Dir(cfg.logFilePath)), filepath.Dir(cfg.logFilePath))
+		}
+		if cfg.webhookURL != "" {
+			check("NOTIFY_WEBHOOK_URL", newWebhookNotifier(cfg.webhookURL, cfg.webhookTimeout, cfg.webhookTLS).test(), "test event delivered")
+		}
+	}
+	if u := strings.TrimSpace(os.Getenv("NATS_URL")); u != "" {
+		check("NATS_URL", checkNATS(u, strings.TrimSpace(os.Getenv("NATS_SUBJECT"))), redactURL(u))
+	}
+	if os.Getenv("REDIS_LOCK_URL") != "" {
+		skip("REDIS_LOCK_URL", "cronrunner has no Redis support; the setting is ignored")
+	}
+
+	fmt.Fprintln(w, "\nSettings:")
+	for _, kv := range selfTestSettings() {
+		fmt.Fprintf(w, "  %s\n", kv)
+	}
+
+	if failed {
+		fmt.Fprintln(w, "\nSelf-test failed")
+		return 1
+	}
+	fmt.Fprintln(w, "\nSelf-test passed")
+	return 0
+}
+
+// nextRunTime returns when the schedule fires next, for the self-test.
+func nextRunTime(cfg *config) string {
+	var buf bytes.Buffer
+	if err := printNextRuns(&buf, cfg, 1); err != nil {
+		return err.Error()
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// checkWritableDir creates and removes a file in dir.
+func checkWritableDir(dir string) error {
+	f, err := os.CreateTemp(dir, ".cronrunner-selftest-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkNATS connects to the NATS server and, when a subject is given,
+// looks up the JetStream stream that holds it.
+func checkNATS(natsURL, subject string) error {
+	nc, err := nats.Connect(natsURL, nats.Name("cronrunner-selftest"), nats.Timeout(selfTestTimeout))
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+	if subject == "" {
+		return nil
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), selfTestTimeout)
+	defer cancel()
+	if _, err := js.StreamNameBySubject(ctx, subject); err != nil {
+		return fmt.Errorf("find stream for subject %s: %w", subject, err)
+	}
+	return nil
+}
+
+// selfTestSettings returns the settings cronrunner reads from the
+// environment as sorted NAME=value lines, with CRON_EXPRESSION and
+// CRON_CMD decoded and secrets redacted.
+func selfTestSettings() []string {
+	var out []string
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !slices.Contains(selfTestNames, name) && !slices.ContainsFunc(selfTestPrefixes, func(p string) bool { return strings.HasPrefix(name, p) }) {
+			continue
+		}
+		switch {
+		case slices.ContainsFunc(redactedWords, func(s string) bool { return strings.Contains(name, s) }):
+			value = "<redacted>"
+		case name == "CRON_EXPRESSION" || name == "CRON_CMD":
+			if s, err := decodeBase64Any(value); err == nil && s != value {
+				value = s + " (decoded)"
+			}
+		default:
+			value = redactURL(value)
+		}
+		out = append(out, name+"="+value)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// redactURL hides the password in a URL with user information, such as a
+// proxy or NATS URL; anything else is returned unchanged.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return u.Redacted()
+}
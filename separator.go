@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Values accepted by LOG_SEPARATOR_FORMAT.
+const (
+	separatorText = "text"
+	separatorJSON = "json"
+)
+
+const (
+	eventRunStart = "run_start"
+	eventRunEnd   = "run_end"
+)
+
+// RunMeta describes the run a LOG_FILE separator belongs to.
+type RunMeta struct {
+	Format      string
+	Job         string
+	RunID       string
+	ScheduledAt time.Time
+	ExitCode    int
+	Duration    time.Duration
+}
+
+// writeRunSeparator writes the start or end marker of a run to w in the
+// configured LOG_SEPARATOR_FORMAT.
+func writeRunSeparator(w io.Writer, event string, meta RunMeta) error {
+	if meta.Format == separatorJSON {
+		var rec any
+		switch event {
+		case eventRunStart:
+			rec = struct {
+				Event       string    `json:"event"`
+				Job         string    `json:"job"`
+				RunID       string    `json:"run_id"`
+				ScheduledAt time.Time `json:"scheduled_at,omitzero"`
+			}{event, meta.Job, meta.RunID, meta.ScheduledAt}
+		default:
+			rec = struct {
+				Event      string `json:"event"`
+				ExitCode   int    `json:"exit_code"`
+				DurationMs int64  `json:"duration_ms"`
+			}{event, meta.ExitCode, meta.Duration.Milliseconds()}
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	var line string
+	switch event {
+	case eventRunStart:
+		line = "===== RUN START " + now + " =====\n"
+	default:
+		line = "===== RUN END " + now + " exit=" + strconv.Itoa(meta.ExitCode) + " duration=" + meta.Duration.String() + " =====\n\n"
+	}
+	_, err := io.WriteString(w, line)
+	return err
+}
+
+func parseSeparatorFormat(s string) (string, error) {
+	switch s {
+	case "", separatorText:
+		return separatorText, nil
+	case separatorJSON:
+		return separatorJSON, nil
+	}
+	return "", fmt.Errorf("Invalid LOG_SEPARATOR_FORMAT value '%s': must be text or json", s)
+}
+
+// newRunID returns a short random identifier for correlating a run's log
+// lines and separators.
+func newRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
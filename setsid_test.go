@@ -0,0 +1,82 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// procSession returns the pid and session ID in the /proc/<pid>/stat
+// contents stat.
+func procSession(t *testing.T, stat string) (pid, sid int) {
+	t.Helper()
+	// The command name in parentheses may contain spaces; the fields after
+	// it are state, ppid, pgrp and session.
+	first, rest, ok := strings.Cut(stat, " (")
+	i := strings.LastIndexByte(rest, ')')
+	var fields []string
+	if ok && i >= 0 {
+		fields = strings.Fields(rest[i+1:])
+	}
+	if len(fields) < 4 {
+		t.Fatalf("malformed stat %q", stat)
+	}
+	pid, err := strconv.Atoi(first)
+	if err == nil {
+		sid, err = strconv.Atoi(fields[3])
+	}
+	if err != nil {
+		t.Fatalf("malformed stat %q", stat)
+	}
+	return pid, sid
+}
+
+// childSession runs a command that records its own stat and returns its
+// pid and session ID.
+func childSession(t *testing.T, env map[string]string) (pid, sid int) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "stat")
+	r := newTestRunner(t, "cat /proc/$$/stat > "+out, env)
+	if rec := r.execute(r.request(newRunID())); !rec.succeeded() {
+		t.Fatalf("command failed with exit code %d", rec.ExitCode)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return procSession(t, string(b))
+}
+
+func ownSession(t *testing.T) int {
+	t.Helper()
+	b, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sid := procSession(t, string(b))
+	return sid
+}
+
+func TestSetsid(t *testing.T) {
+	pid, sid := childSession(t, map[string]string{"CRON_SETSID": "true"})
+	if sid != pid {
+		t.Errorf("child %d is in session %d, want it to lead its own", pid, sid)
+	}
+	if own := ownSession(t); sid == own {
+		t.Errorf("child is in our session %d", own)
+	}
+}
+
+func TestNoSetsid(t *testing.T) {
+	pid, sid := childSession(t, map[string]string{"CRON_SETSID": ""})
+	if sid == pid {
+		t.Errorf("child %d leads its own session without CRON_SETSID", pid)
+	}
+	if own := ownSession(t); sid != own {
+		t.Errorf("child is in session %d, want ours, %d", sid, own)
+	}
+}
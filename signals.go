@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// parseSignals resolves names such as "SIGUSR1" or "usr1" to signals.
+func parseSignals(names []string) ([]os.Signal, error) {
+	var sigs []os.Signal
+	for _, name := range names {
+		key := strings.TrimPrefix(strings.ToUpper(name), "SIG")
+		sig, ok := signalNames[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown signal '%s'", name)
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+func containsSignal(sigs []os.Signal, sig os.Signal) bool {
+	for _, s := range sigs {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// signalName returns the conventional SIG-prefixed name of sig.
+func signalName(sig os.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+	return sig.String()
+}
+
+func signalList(sigs []os.Signal) string {
+	names := make([]string, len(sigs))
+	for i, sig := range sigs {
+		names[i] = signalName(sig)
+	}
+	return strings.Join(names, ",")
+}
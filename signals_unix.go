@@ -0,0 +1,31 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+var signalNames = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"TERM":  syscall.SIGTERM,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"WINCH": syscall.SIGWINCH,
+	"CONT":  syscall.SIGCONT,
+	"TSTP":  syscall.SIGTSTP,
+}
+
+// shutdownSignals stop cronrunner; triggerSignal starts a run,
+// pauseSignal toggles pause and reloadSignal re-reads the schedule and
+// command files. termSignal asks a command to exit before it is killed.
+var (
+	shutdownSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	triggerSignal   = os.Signal(syscall.SIGUSR1)
+	pauseSignal     = os.Signal(syscall.SIGUSR2)
+	reloadSignal    = os.Signal(syscall.SIGHUP)
+	termSignal      = os.Signal(syscall.SIGTERM)
+)
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// Windows can't deliver signals to another process, so nothing can be
+// forwarded with CRON_FORWARD_SIGNALS.
+var signalNames = map[string]syscall.Signal{}
+
+// Ctrl+C arrives as os.Interrupt and console close, logoff and shutdown
+// events as SIGTERM. There are no user signals, so runs can only be
+// triggered, paused and reloaded over HTTP, and commands can only be
+// killed, not asked to exit.
+var (
+	shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	triggerSignal   os.Signal
+	pauseSignal     os.Signal
+	reloadSignal    os.Signal
+	termSignal      os.Signal
+)
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultSlackTailLines = 20
+
+// slackTextLimit is kept below the 3000 characters Slack allows in a
+// section block, leaving room for the code fence.
+const slackTextLimit = 2900
+
+// slackNotifier posts a Block Kit message to SLACK_WEBHOOK_URL for every
+// failed run. The output tail of each run is collected while it runs and
+// handed over when the run is reported.
+type slackNotifier struct {
+	url        string
+	channel    string
+	username   string
+	tailLines  int
+	historyURL string
+	client     *http.Client
+
+	mu    sync.Mutex
+	tails map[string]*tailBuffer
+
+	// pending tracks messages still being sent, so shutdown can wait for
+	// the last ones.
+	pending sync.WaitGroup
+}
+
+func newSlackNotifier(cfg *config) *slackNotifier {
+	return &slackNotifier{
+		url:        cfg.slackURL,
+		channel:    cfg.slackChannel,
+		username:   cfg.slackUsername,
+		tailLines:  cfg.slackTailLines,
+		historyURL: cfg.slackHistoryURL,
+		client:     newHTTPClient(cfg.notifyHTTPTimeout),
+		tails:      make(map[string]*tailBuffer),
+	}
+}
+
+// tail returns the writer collecting the output of the run, or nil when
+// SLACK_TAIL_LINES is 0.
+func (s *slackNotifier) tail(runID string) *tailBuffer {
+	if s.tailLines == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tails[runID]
+	if !ok {
+		t = newTailBuffer(s.tailLines)
+		s.tails[runID] = t
+	}
+	return t
+}
+
+// report sends the message in the background so a slow or unreachable
+// Slack never delays the job. Successful runs only release their tail.
+func (s *slackNotifier) report(rec runRecord) {
+	s.mu.Lock()
+	t := s.tails[rec.RunID]
+	delete(s.tails, rec.RunID)
+	s.mu.Unlock()
+
+	if rec.succeeded() {
+		return
+	}
+	var output string
+	if t != nil {
+		output = t.String()
+	}
+	body, err := json.Marshal(s.message(rec, output))
+	if err != nil {
+		logf(LevelError, "Failed to encode Slack message for run %s: %v", rec.RunID, err)
+		return
+	}
+
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			logf(LevelError, "Failed to send Slack message for run %s: %v", rec.RunID, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			logf(LevelWarn, "Slack webhook for run %s returned %s", rec.RunID, resp.Status)
+		}
+	}()
+}
+
+// wait blocks until every message has been sent or has failed, which the
+// client timeout bounds.
+func (s *slackNotifier) wait() {
+	s.pending.Wait()
+}
+
+type slackMessage struct {
+	Text     string       `json:"text"`
+	Channel  string       `json:"channel,omitempty"`
+	Username string       `json:"username,omitempty"`
+	Blocks   []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func mrkdwn(format string, args ...interface{}) slackText {
+	return slackText{Type: "mrkdwn", Text: fmt.Sprintf(format, args...)}
+}
+
+// message builds the Block Kit payload: a header, the run's details, the
+// output tail and a link to the run in GET /runs.
+func (s *slackNotifier) message(rec runRecord, output string) slackMessage {
+	title := fmt.Sprintf("cronrunner: %s failed", rec.Job)
+	switch {
+	case rec.NotFound:
+		title = fmt.Sprintf("cronrunner: %s command not found", rec.Job)
+	case rec.Killed:
+		title = fmt.Sprintf("cronrunner: %s was killed", rec.Job)
+	}
+
+	fields := []slackText{
+		mrkdwn("*Job*\n%s", slackEscape(rec.Job)),
+		mrkdwn("*Exit code*\n%d", rec.ExitCode),
+	}
+	// Manual and queued runs have no scheduled time.
+	if !rec.ScheduledAt.IsZero() {
+		fields = append(fields, mrkdwn("*Scheduled*\n%s", rec.ScheduledAt.Format(time.RFC3339)))
+	}
+	fields = append(fields,
+		mrkdwn("*Started*\n%s", rec.StartedAt.Format(time.RFC3339)),
+		mrkdwn("*Duration*\n%v", time.Duration(rec.DurationMs)*time.Millisecond),
+		mrkdwn("*Attempts*\n%d", rec.Attempts),
+	)
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: title}},
+		{Type: "section", Fields: fields},
+	}
+	if output = slackEscape(strings.TrimRight(output, "\n")); output != "" {
+		if len(output) > slackTextLimit {
+			output = "…" + strings.ToValidUTF8(output[len(output)-slackTextLimit:], "")
+		}
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: "```" + output + "```"}})
+	}
+	if s.historyURL != "" {
+		blocks = append(blocks, slackBlock{Type: "context", Elements: []slackText{
+			mrkdwn("<%s|View run %s>", s.runURL(rec), rec.RunID),
+		}})
+	}
+
+	return slackMessage{
+		Text:     fmt.Sprintf("%s (exit code %d)", title, rec.ExitCode),
+		Channel:  s.channel,
+		Username: s.username,
+		Blocks:   blocks,
+	}
+}
+
+// runURL points at GET /runs filtered to the run's job and start time.
+func (s *slackNotifier) runURL(rec runRecord) string {
+	started := rec.StartedAt.UTC().Format(time.RFC3339Nano)
+	q := url.Values{"job": {rec.Job}, "from": {started}, "to": {started}}
+	return strings.TrimRight(s.historyURL, "/") + "/runs?" + q.Encode()
+}
+
+// slackEscape escapes the characters Slack treats as control sequences.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// tailBuffer keeps the last n lines written to it.
+type tailBuffer struct {
+	mu    sync.Mutex
+	n     int
+	lines []string
+	cur   []byte
+}
+
+func newTailBuffer(n int) *tailBuffer {
+	return &tailBuffer{n: n}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, b := range p {
+		if b != '\n' {
+			// A single line longer than the message could ever show only
+			// keeps its end.
+			if len(t.cur) >= slackTextLimit {
+				t.cur = t.cur[1:]
+			}
+			t.cur = append(t.cur, b)
+			continue
+		}
+		t.lines = append(t.lines, string(t.cur))
+		t.cur = t.cur[:0]
+		if len(t.lines) > t.n {
+			t.lines = t.lines[len(t.lines)-t.n:]
+		}
+	}
+	return len(p), nil
+}
+
+// String returns the kept lines, including an unterminated last line.
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := t.lines
+	if len(t.cur) > 0 {
+		lines = append(lines[:len(lines):len(lines)], string(t.cur))
+		if len(lines) > t.n {
+			lines = lines[1:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const sshDialTimeout = 30 * time.Second
+
+// sshRunner runs each attempt on CRON_SSH_HOST over SSH instead of as a
+// local child process. Every attempt gets its own connection and session;
+// the command is sent as is and run by the remote user's login shell.
+type sshRunner struct {
+	addr      string
+	config    *ssh.ClientConfig
+	killGrace time.Duration
+}
+
+func newSSHRunner(cfg *config) (*sshRunner, error) {
+	pem, err := os.ReadFile(cfg.sshKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CRON_SSH_KEY_FILE: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(pem)
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("CRON_SSH_KEY_FILE is encrypted; a key without a passphrase is required")
+		}
+		return nil, fmt.Errorf("parse CRON_SSH_KEY_FILE: %w", err)
+	}
+	hostKey := ssh.InsecureIgnoreHostKey()
+	if !cfg.sshInsecure {
+		if hostKey, err = knownhosts.New(cfg.sshKnownHosts); err != nil {
+			return nil, fmt.Errorf("read CRON_SSH_KNOWN_HOSTS: %w", err)
+		}
+	}
+	return &sshRunner{
+		addr: cfg.sshHost,
+		config: &ssh.ClientConfig{
+			User:            cfg.sshUser,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKey,
+			Timeout:         sshDialTimeout,
+		},
+		killGrace: cfg.killGrace,
+	}, nil
+}
+
+// sshExitError reports a remote command that exited non-zero or was killed
+// by a signal.
+type sshExitError struct{ *ssh.ExitError }
+
+func (e *sshExitError) ExitCode() int {
+	if e.Signal() != "" {
+		// Like a shell reports a child killed by a signal, with Linux's
+		// signal numbers.
+		if n, ok := sshSignalNumbers[ssh.Signal(e.Signal())]; ok {
+			return 128 + n
+		}
+	}
+	return e.ExitStatus()
+}
+
+var sshSignalNumbers = map[ssh.Signal]int{
+	ssh.SIGHUP: 1, ssh.SIGINT: 2, ssh.SIGQUIT: 3, ssh.SIGILL: 4, ssh.SIGABRT: 6,
+	ssh.SIGFPE: 8, ssh.SIGKILL: 9, ssh.SIGSEGV: 11, ssh.SIGPIPE: 13, ssh.SIGALRM: 14,
+	ssh.SIGTERM: 15, ssh.SIGUSR1: 10, ssh.SIGUSR2: 12,
+}
+
+// run executes req on the remote host with its output going to stdout and
+// stderr, like a local command's. When ctx ends, the remote command is sent
+// SIGTERM, or SIGKILL without CRON_KILL_GRACE_SEC, and the connection is
+// closed once the grace period is over.
+func (s *sshRunner) run(ctx context.Context, req runRequest, stdout, stderr io.Writer) error {
+	d := net.Dialer{Timeout: sshDialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", s.addr, err)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, s.addr, s.config)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connect to %s: %w", s.addr, err)
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("open session on %s: %w", s.addr, err)
+	}
+	defer session.Close()
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if err := session.Start(sshCommand(req)); err != nil {
+		return fmt.Errorf("start command on %s: %w", s.addr, err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		sig := ssh.SIGTERM
+		if s.killGrace <= 0 {
+			sig = ssh.SIGKILL
+		}
+		// Servers that don't support signals ignore the request; closing
+		// the connection then hangs up the session.
+		_ = session.Signal(sig)
+		select {
+		case err = <-done:
+		case <-time.After(s.killGrace):
+			client.Close()
+			err = <-done
+		}
+	}
+	var ee *ssh.ExitError
+	if errors.As(err, &ee) {
+		return &sshExitError{ee}
+	}
+	return err
+}
+
+// sshCommand exports the run's environment variables before the command,
+// since most servers refuse environment requests.
+func sshCommand(req runRequest) string {
+	if len(req.env) == 0 {
+		return req.command
+	}
+	var b strings.Builder
+	b.WriteString("export")
+	for _, k := range slices.Sorted(maps.Keys(req.env)) {
+		b.WriteString(" " + k + "=" + shellQuote(req.env[k]))
+	}
+	return b.String() + "; " + req.command
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// defaultKnownHosts is the known_hosts file used without
+// CRON_SSH_KNOWN_HOSTS.
+func defaultKnownHosts() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
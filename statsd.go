@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const statsdWriteTimeout = 100 * time.Millisecond
+
+// statsdReporter sends per-run counters and a duration timing to
+// CRON_STATSD_ADDR over UDP. Delivery is best effort: a StatsD server that
+// is down or slow never delays or fails a run.
+type statsdReporter struct {
+	conn   net.Conn
+	prefix string
+}
+
+func newStatsdReporter(addr, prefix string) (*statsdReporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdReporter{conn: conn, prefix: prefix}, nil
+}
+
+var statsdReplacer = strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_")
+
+// report emits <prefix>.<job>.runs, .failures and .timeouts counters and a
+// .duration timing in milliseconds, in a single datagram.
+func (s *statsdReporter) report(rec runRecord) {
+	name := s.prefix + "." + statsdReplacer.Replace(rec.Job)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s.runs:1|c\n", name)
+	if !rec.succeeded() {
+		fmt.Fprintf(&b, "%s.failures:1|c\n", name)
+	}
+	if rec.Killed {
+		fmt.Fprintf(&b, "%s.timeouts:1|c\n", name)
+	}
+	fmt.Fprintf(&b, "%s.duration:%d|ms", name, rec.DurationMs)
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(statsdWriteTimeout))
+	if _, err := s.conn.Write([]byte(b.String())); err != nil {
+		logf(LevelError, "Failed to send StatsD metrics for run %s: %v", rec.RunID, err)
+	}
+}
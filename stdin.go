@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// openStdin returns the reader wired to the child's stdin for one attempt,
+// and a function releasing it. CRON_STDIN_FILE is reopened on every attempt
+// so edits to the file are picked up and it can be a FIFO; inline data from
+// CRON_STDIN_DATA or CRON_STDIN is replayed from the start. With none of them
+// set the child gets no stdin, as before.
+func openStdin(cfg *config) (io.Reader, func()) {
+	if cfg.stdinFile != "" {
+		f, err := os.Open(cfg.stdinFile)
+		if err != nil {
+			logf(LevelError, "Failed to open CRON_STDIN_FILE '%s'; running without stdin: %v", cfg.stdinFile, err)
+			return nil, func() {}
+		}
+		return f, func() { _ = f.Close() }
+	}
+	if cfg.stdinSource != "" {
+		return bytes.NewReader(cfg.stdinData), func() {}
+	}
+	return nil, func() {}
+}
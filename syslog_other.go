@@ -0,0 +1,11 @@
+//go:build windows || plan9
+
+package main
+
+import "errors"
+
+func validSyslogFacility(name string) bool { return true }
+
+func startSyslog(network, addr, facility, tag string) error {
+	return errors.New("syslog is not available on this platform")
+}
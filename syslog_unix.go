@@ -0,0 +1,36 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"log"
+	"log/syslog"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "mail": syslog.LOG_MAIL,
+	"daemon": syslog.LOG_DAEMON, "auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG,
+	"lpr": syslog.LOG_LPR, "news": syslog.LOG_NEWS, "uucp": syslog.LOG_UUCP,
+	"cron": syslog.LOG_CRON, "authpriv": syslog.LOG_AUTHPRIV, "ftp": syslog.LOG_FTP,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3, "local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+func validSyslogFacility(name string) bool {
+	_, ok := syslogFacilities[name]
+	return ok
+}
+
+// startSyslog sends cronrunner's own log messages to syslog, the local
+// daemon when addr is empty. syslog stamps each message itself, so the log
+// package's date and time prefix is dropped.
+func startSyslog(network, addr, facility, tag string) error {
+	w, err := syslog.Dial(network, addr, syslogFacilities[facility]|syslog.LOG_INFO, tag)
+	if err != nil {
+		return err
+	}
+	log.SetOutput(w)
+	log.SetFlags(0)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// buildTLSConfig builds the client TLS configuration for webhook requests
+// from NOTIFY_WEBHOOK_MTLS_CERT/_KEY, NOTIFY_WEBHOOK_CA_CERT and
+// NOTIFY_WEBHOOK_TLS_SKIP_VERIFY. It returns nil when none of them are set,
+// so the default transport settings apply.
+func buildTLSConfig() (*tls.Config, error) {
+	certFile := os.Getenv("NOTIFY_WEBHOOK_MTLS_CERT")
+	keyFile := os.Getenv("NOTIFY_WEBHOOK_MTLS_KEY")
+	caFile := os.Getenv("NOTIFY_WEBHOOK_CA_CERT")
+	skipVerify := parseBool(os.Getenv("NOTIFY_WEBHOOK_TLS_SKIP_VERIFY"))
+
+	if certFile == "" && keyFile == "" && caFile == "" && !skipVerify {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: skipVerify,
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("NOTIFY_WEBHOOK_MTLS_CERT and NOTIFY_WEBHOOK_MTLS_KEY must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load webhook client certificate: %v", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read NOTIFY_WEBHOOK_CA_CERT: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("NOTIFY_WEBHOOK_CA_CERT '%s' contains no PEM certificates", caFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	return tlsCfg, nil
+}
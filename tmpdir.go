@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runTmpDir is the directory CRON_TMPDIR creates for a single run. path is
+// where cronrunner sees it, childPath where the command does, which only
+// differ under CRON_CHROOT.
+type runTmpDir struct {
+	path      string
+	childPath string
+}
+
+// newRunTmpDir creates the run's temporary directory under CRON_TMPDIR_BASE
+// and hands it to CRON_RUN_AS_USER when set, so the command can write to it.
+func newRunTmpDir(cfg *config, runID string) (*runTmpDir, error) {
+	base := filepath.Join(cfg.chroot, cfg.tmpDirBase)
+	dir, err := os.MkdirTemp(base, "cronrunner-"+runID+"-")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.runAs != nil {
+		if err := os.Chown(dir, int(cfg.runAs.uid), int(cfg.runAs.gid)); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	child := dir
+	if cfg.chroot != "" {
+		child = "/" + strings.TrimPrefix(strings.TrimPrefix(dir, filepath.Clean(cfg.chroot)), "/")
+	}
+	return &runTmpDir{path: dir, childPath: child}, nil
+}
+
+// env returns the variables that point the command at the directory.
+func (d *runTmpDir) env() map[string]string {
+	return map[string]string{"TMPDIR": d.childPath, "TEMP": d.childPath, "TMP": d.childPath}
+}
+
+// remove deletes the directory and everything the command left in it. A
+// failure is only logged; the run's outcome stands.
+func (d *runTmpDir) remove() {
+	if err := os.RemoveAll(d.path); err != nil {
+		logf(LevelError, "Failed to remove CRON_TMPDIR directory '%s': %v", d.path, err)
+	}
+}
+
+// checkTmpDirBase verifies at startup that CRON_TMPDIR_BASE (inside
+// CRON_CHROOT when set) is an existing directory.
+func checkTmpDirBase(cfg *config) error {
+	dir := filepath.Join(cfg.chroot, cfg.tmpDirBase)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+	return nil
+}
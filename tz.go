@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// tzAliases maps timezone abbreviations people put in CRON_TZ to the IANA
+// location they usually mean. It is consulted before time.LoadLocation, so
+// EST, MST and HST, which the tz database also has as fixed offsets, follow
+// daylight saving time like the other abbreviations here; names it doesn't
+// list (CET, EET, ...) keep the tz database's meaning. Several abbreviations
+// are ambiguous; CST is taken as US Central here, so China and Taiwan need
+// Asia/Shanghai or Asia/Taipei. Keys are upper case.
+var tzAliases = map[string]string{
+	"EST":  "America/New_York",
+	"EDT":  "America/New_York",
+	"CST":  "America/Chicago",
+	"CDT":  "America/Chicago",
+	"MST":  "America/Denver",
+	"MDT":  "America/Denver",
+	"PST":  "America/Los_Angeles",
+	"PDT":  "America/Los_Angeles",
+	"AKST": "America/Anchorage",
+	"AKDT": "America/Anchorage",
+	"HST":  "Pacific/Honolulu",
+	"BRT":  "America/Sao_Paulo",
+	"ART":  "America/Argentina/Buenos_Aires",
+	"BST":  "Europe/London",
+	"IST":  "Asia/Kolkata",
+	"CEST": "Europe/Paris",
+	"EEST": "Europe/Athens",
+	"MSK":  "Europe/Moscow",
+	"SAST": "Africa/Johannesburg",
+	"PKT":  "Asia/Karachi",
+	"WIB":  "Asia/Jakarta",
+	"ICT":  "Asia/Bangkok",
+	"SGT":  "Asia/Singapore",
+	"HKT":  "Asia/Hong_Kong",
+	"PHT":  "Asia/Manila",
+	"JST":  "Asia/Tokyo",
+	"KST":  "Asia/Seoul",
+	"AWST": "Australia/Perth",
+	"ACST": "Australia/Adelaide",
+	"ACDT": "Australia/Adelaide",
+	"AEST": "Australia/Sydney",
+	"AEDT": "Australia/Sydney",
+	"NZST": "Pacific/Auckland",
+	"NZDT": "Pacific/Auckland",
+}
+
+// loadTimezone resolves CRON_TZ. A name in tzAliases is replaced by the
+// IANA location it stands for, which is also the returned name; any other
+// name is passed to time.LoadLocation as given.
+func loadTimezone(name string) (*time.Location, string, error) {
+	if alias, ok := tzAliases[strings.ToUpper(name)]; ok {
+		loc, err := time.LoadLocation(alias)
+		if err != nil {
+			return nil, "", fmt.Errorf("alias for %s: %v", name, err)
+		}
+		return loc, alias, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return loc, name, nil
+}
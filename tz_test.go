@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestLoadTimezone(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		// EST, MST and HST are fixed offsets in the tz database too; the
+		// alias wins.
+		{"EST", "America/New_York"},
+		{"MST", "America/Denver"},
+		{"HST", "Pacific/Honolulu"},
+		{"ist", "Asia/Kolkata"},
+		{"Europe/Berlin", "Europe/Berlin"},
+		{"CET", "CET"},
+		{"UTC", "UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, name, err := loadTimezone(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tt.want || loc.String() != tt.want {
+				t.Errorf("loadTimezone(%q) = %s, %q; want %q", tt.name, loc, name, tt.want)
+			}
+		})
+	}
+	if _, _, err := loadTimezone("Nowhere/Special"); err == nil {
+		t.Error("loadTimezone accepted an unknown name")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usageText = `Usage: cronrunner [flags]
+
+cronrunner runs CRON_CMD on the schedule given by CRON_EXPRESSION.
+All configuration is read from environment variables; the flags below
+override the most common ones, e.g. for local testing:
+
+  cronrunner --cron '*/10 * * * * *' --cmd 'echo hello'
+
+Environment:
+  CRON_EXPRESSION      Schedule (base64 or plain text, required unless CRON_EXPRESSION_FILE is set)
+  CRON_EXPRESSION_FILE Read the schedule from this file instead, as plain text
+  CRONRUNNER_SELFTEST  Check the configuration and integrations, print a report and exit
+  CRONRUNNER_CONFIGMAP_DIR
+                       Read settings from a mounted ConfigMap directory; the environment wins
+  CRON_CMD             Command to execute (base64 or plain text, required unless CRON_CMD_FILE is set)
+  CRON_CMD_FILE        Read the command from this file instead, re-read before every run
+  CRON_SHELL           Run CRON_CMD through "<shell> -c" (e.g. /bin/sh; cmd and powershell on Windows)
+  CRON_JOB_NAME        Name of the job in history, metrics and notifications (default: the executable)
+  CRON_LOG_JOB_NAME    Prefix cronrunner's own log lines with [<job name>] (1, true, yes)
+  CRON_CONDITION_CMD   Run this check (base64 or plain text) before each scheduled run; skip the run unless it exits 0
+  CRON_CONDITION_TIMEOUT_SEC
+                       Timeout for CRON_CONDITION_CMD (default 30)
+  CRON_WORKDIR         Working directory for the command (inside CRON_CHROOT when set)
+  CRON_TMPDIR          Give each run its own TMPDIR, removed when the run ends (1, true, yes)
+  CRON_TMPDIR_BASE     Parent directory for CRON_TMPDIR (default $TMPDIR or /tmp)
+  CRON_CHROOT          Run the command with this directory as its root (Linux, needs CAP_SYS_CHROOT)
+  CRON_KILL_AFTER_MIN  Kill the command after this many minutes, across restarts
+  CRON_TOTAL_TIMEOUT_MIN
+                       Same as CRON_KILL_AFTER_MIN; takes precedence when both are set
+  CRON_KILL_AFTER_SEC  Run timeout in seconds (or a duration); wins over both
+  CRON_ATTEMPT_TIMEOUT_MIN
+                       Kill each individual attempt after this many minutes
+  CRON_TOTAL_DEADLINE  Bound a whole tick, condition check and restart delays included
+  CRON_MAX_CONSECUTIVE_FAILURES
+                       Stop running the command after this many failed runs in a row
+  CRON_BREAKER_ACTION  What to do then: pause (default) or exit (exit status 1)
+  CRON_MAX_RUNS        Shut down (exit status 0) after this many completed runs
+  CRON_SHUTDOWN_TIMEOUT_SEC
+                       On SIGTERM, wait this long for a running command before killing it
+  CRON_KILL_GRACE_SEC  Send SIGTERM first when killing a command, SIGKILL after this many seconds
+  CRON_WARN_AFTER      Log a warning when a run is still going after this long (e.g. 10m)
+  CRON_EXPRESSION_HUMAN
+                       Log each schedule in English at startup (1, true, yes)
+  CRON_PARSER          Schedule syntax: seconds (default, 6 or 5 fields) or standard (5 fields)
+  CRON_TZ              Timezone used by the scheduler (e.g. Asia/Taipei)
+  CRON_JITTER_SEC      Delay each scheduled run by up to this many seconds
+  CRON_JITTER_MODE     random (default, per run) or host (a fixed offset per instance)
+  CRON_INSTANCE_ID     Instance name the host jitter is derived from (default: hostname)
+  LOG_FILE             Append child stdout/stderr to this file per run
+  CRON_LOG_CREATE_DIRS Create missing LOG_FILE directories (default true)
+  CRON_LOG_FSYNC       Sync LOG_FILE to disk after every attempt (1, true, yes)
+  LOG_OUTPUT_BUFFER_SIZE
+                       Buffer this many bytes of output before writing LOG_FILE (default 0, unbuffered)
+  LOG_OUTPUT_FLUSH_INTERVAL_MS
+                       Flush the LOG_FILE buffer this often (default 1000, 0 only when full)
+  CRON_LOG_FILE_MODE   Octal permissions for a newly created LOG_FILE (default 0644)
+  CRON_LOG_FILE_OWNER  Owner of a newly created LOG_FILE (user or user:group)
+  CRON_LOG_MAX_RUN_BYTES
+                       Truncate each run's output in LOG_FILE after this many bytes
+  CRON_LOG_CAP_CONSOLE Apply CRON_LOG_MAX_RUN_BYTES to stdout/stderr too (1, true, yes)
+  CRON_REDACT_PATTERNS Replace these regexps (one per line) in the command's output with ***
+  CRON_REDACT_CMD_LOG  Mask the logged command: name (first word only) or patterns
+  LOG_LEVEL            Only log cronrunner's messages at this level or above: DEBUG, INFO (default), WARN, ERROR
+  LOG_SEPARATOR_FORMAT Run separators written to LOG_FILE: text (default) or json
+  CRON_LOG_COLOR       Color cronrunner's own log lines: auto (default, if stderr is a TTY), always, never
+  CRONRUNNER_LOG_FILE  Write cronrunner's own logs to this file instead of stderr
+  CRONRUNNER_LOG_MAX_BYTES
+                       Rotate CRONRUNNER_LOG_FILE to <file>.1 past this many bytes
+  CRON_SYSLOG          Send cronrunner's own logs to the local syslog daemon (1, true, yes)
+  CRON_SYSLOG_ADDR     Send them to this syslog server instead ([udp|tcp|unix]://addr)
+  CRON_SYSLOG_FACILITY Syslog facility (default cron)
+  CRON_SYSLOG_TAG      Syslog tag (default cronrunner)
+  RESTART_ON_FAIL      Rerun the command until it exits 0 (1, true, yes)
+  RESTART_BACKOFF_STRATEGY
+                       Wait between restarts: constant, linear, exponential, fibonacci
+  RESTART_BACKOFF_BASE_SEC
+                       Base delay for RESTART_BACKOFF_STRATEGY (default 1s)
+  RESTART_BACKOFF_MAX_SEC
+                       Longest wait between restarts (default no cap)
+  CRON_RETRY_EXIT_CODES
+                       Only restart on these exit codes under RESTART_ON_FAIL (comma-separated, 75-78 for a range)
+  CRON_NO_RETRY_EXIT_CODES
+                       Never restart on these exit codes under RESTART_ON_FAIL (comma-separated, 75-78 for a range)
+  ALLOW_CONCURRENT     Start a run even if the previous one is still going
+  OVERLAP_STRATEGY     Tick during a run: drop (default), queue or replace
+  CRON_ENV_ALLOWLIST   Only pass matching variables to the command (e.g. APP_*,HOME,PATH)
+  CRON_ENV_BLOCKLIST   Pass every variable except the matching ones
+  CRON_HTTP_PROXY, CRON_HTTPS_PROXY, CRON_NO_PROXY
+                       Proxy variables (http_proxy, HTTP_PROXY, ...) set for the command
+  CRON_CLEAR_PROXY     Remove inherited proxy variables from the command's environment (1, true, yes)
+  CRONRUNNER_HTTP_PROXY
+                       Proxy for cronrunner's own HTTP requests, not the command's (http, https or socks5 URL)
+  CRON_CA_CERT_FILE    PEM bundle set as SSL_CERT_FILE and NODE_EXTRA_CA_CERTS for the command
+  CRON_CA_CERT_DIR     Certificate directory set as SSL_CERT_DIR for the command
+  CRON_RUN_AS_USER     Run the command as this user (name or uid; requires root)
+  CRON_RUN_AS_GROUP    Group for CRON_RUN_AS_USER (default: the user's primary group)
+  CRON_SUPPLEMENTARY_GROUPS
+                       Comma-separated extra groups for the command (names or gids)
+  CRON_DROP_CAPS       Capabilities removed from the command, e.g. CAP_NET_RAW,CAP_SYS_ADMIN or ALL (Linux)
+  CRON_NO_NEW_PRIVS    Set no_new_privs on the command (1, true, yes; Linux)
+  CRON_NEW_NETNS       Run the command in a new network namespace with only loopback (1, true, yes; Linux)
+  CRON_NETNS_PATH      Run the command in this network namespace (name from "ip netns add", or a path; Linux)
+  CRON_SECCOMP_PROFILE Filter the command's syscalls with this Docker/OCI seccomp profile (Linux amd64/arm64)
+  CRON_SECCOMP_PRESET  Built-in seccomp filter instead: default (denies ptrace, mount, reboot, ...)
+  CRON_NICE            Niceness of the command, -20 (highest priority) to 19 (lowest)
+  CRON_IONICE_CLASS    I/O scheduling class: 0 none, 1 realtime, 2 best-effort, 3 idle (Linux)
+  CRON_IONICE_LEVEL    I/O priority within the class, 0 (highest) to 7 (default 4)
+  CRON_UMASK           Octal umask for the command, e.g. 0027 (Linux/macOS)
+  CRON_SETSID          Run the command in its own session, detached from any terminal (Unix)
+  CRON_ALLOCATE_PTY    Run the command attached to a pseudo-terminal (Linux/macOS)
+  CRON_PTY_COLS, CRON_PTY_ROWS
+                       Terminal size for CRON_ALLOCATE_PTY (default 80x24)
+  CRON_MEM_LIMIT_MB    Cap the command's address space (RLIMIT_AS, Linux only)
+  CRON_RLIMIT_NOFILE, CRON_RLIMIT_CORE, CRON_RLIMIT_AS, CRON_RLIMIT_CPU
+                       Resource limits for the command, e.g. 1024, 1G, 10m, unlimited (Linux only)
+  CRON_STDIN           Text written to the command's stdin
+  CRON_STDIN_DATA      Base64-encoded stdin for the command (wins over CRON_STDIN)
+  CRON_STDIN_FILE      File connected to the command's stdin, reopened each run
+  CRON_PID_FILE        Write the running command's PID to this file
+  CRON_EXITCODE_FILE   Overwrite this file with each run's exit code
+  CRON_EXITCODE_FORMAT Content of CRON_EXITCODE_FILE: plain (default) or json
+  CRONRUNNER_EXIT_CODE_OUTPUT
+                       Print CRONRUNNER_EXIT_CODE=<N> on stdout after every run
+  CRON_EVENT_FILE      Append a JSON line per run and skipped tick to this file (- for stdout)
+  CRON_FORWARD_SIGNALS Relay these signals to the running command (e.g. SIGUSR1,SIGUSR2)
+  CRON_FORWARD_SHUTDOWN
+                       Pass SIGTERM/SIGINT on to the running command at shutdown (default true)
+  VAULT_SECRET_PATH    Vault secret (e.g. secret/data/myapp) whose keys are added to the command's env
+  VAULT_ADDR, VAULT_TOKEN
+                       Vault server and token used to read VAULT_SECRET_PATH
+  VAULT_SECRET_LEASE_RENEW
+                       Reuse the secret until its lease nearly expires (1, true, yes)
+  CRON_OTEL_ENDPOINT   OTLP/HTTP endpoint for a span per run (builds with -tags otel)
+  CRON_WAIT_FOR        Wait until these host:port addresses accept TCP connections
+  CRON_WAIT_TIMEOUT_SEC
+                       Give up waiting for CRON_WAIT_FOR after this many seconds
+  HEALTH_PORT          Serve the health, status, history and control endpoints on this port
+  HEALTH_API_TOKEN     Bearer token required by POST and PUT endpoints (/trigger, /pause, /resume, /reload, /jobs)
+  LIVENESS_MAX_AGE_SEC /healthz answers 503 after this long without a successful run (0 = off)
+  HISTORY_DB_PATH      Persist run history to this SQLite database
+  HISTORY_MAX_ROWS     Keep at most this many runs in HISTORY_DB_PATH
+  CRON_STATSD_ADDR     Send run metrics to this StatsD host:port over UDP
+  CRON_STATSD_PREFIX   StatsD metric prefix (default cronrunner)
+  CRON_PUSHGATEWAY_URL Push run metrics to this Prometheus Pushgateway after each run
+  CRON_PUSHGATEWAY_INSTANCE
+                       Pushgateway instance label (default hostname)
+  CRON_PUSHGATEWAY_TIMEOUT_SEC
+                       Pushgateway request timeout (default 10)
+  CLOUDWATCH_NAMESPACE Publish JobRunCount/JobDuration metrics here (needs AWS_REGION)
+  NOTIFY_WEBHOOK_URL   POST a JSON summary of every run to this URL
+  CRON_WARN_WEBHOOK    Also POST the CRON_WARN_AFTER warning to NOTIFY_WEBHOOK_URL
+  NOTIFY_WEBHOOK_TIMEOUT_SEC
+                       Webhook request timeout (default NOTIFY_HTTP_TIMEOUT_SEC)
+  NOTIFY_WEBHOOK_MTLS_CERT, NOTIFY_WEBHOOK_MTLS_KEY
+                       PEM client certificate and key for webhook requests
+  NOTIFY_WEBHOOK_CA_CERT
+                       PEM CA bundle used to verify the webhook server
+  NOTIFY_WEBHOOK_TLS_SKIP_VERIFY
+                       Don't verify the webhook server certificate (1, true, yes)
+  SLACK_WEBHOOK_URL    Post failed runs to this Slack incoming webhook
+  SLACK_CHANNEL, SLACK_USERNAME
+                       Override the Slack webhook's default channel and name
+  SLACK_TAIL_LINES     Lines of output in the Slack message (default 20)
+  SLACK_HISTORY_URL    cronrunner's HTTP server as Slack users reach it, for run links
+  PAGERDUTY_ROUTING_KEY
+                       Trigger a PagerDuty incident when a run fails (Events API v2)
+  PAGERDUTY_RESOLVE    Resolve the incident when the job succeeds again (1, true, yes)
+  PAGERDUTY_EVENTS_URL Events API endpoint (default https://events.pagerduty.com/v2/enqueue)
+  HEALTHCHECK_PING_URL GET this URL after every successful run ({duration}, {exit_code})
+  HEALTHCHECK_PING_FAIL_URL
+                       GET this URL after every failed run
+  NOTIFY_HTTP_TIMEOUT_SEC
+                       Default timeout for all outgoing HTTP calls (default 30)
+  S3_OUTPUT_BUCKET     Upload each run's output to this S3 bucket
+  S3_OUTPUT_KEY_PREFIX Key prefix for uploaded output
+  S3_OUTPUT_COMPRESS   Gzip output before uploading (1, true, yes)
+  S3_OUTPUT_TIMEOUT_SEC
+                       S3 request timeout (default NOTIFY_HTTP_TIMEOUT_SEC)
+  NATS_URL             With NATS_SUBJECT and no CRON_EXPRESSION, run commands from JetStream
+  NATS_SUBJECT         Subject to consume {"cmd","run_id","env"} messages from
+  NATS_DURABLE         Durable consumer name (default cronrunner)
+  NATS_MAX_CONCURRENT  Number of messages processed at once (default 1)
+  K8S_JOB_MODE         Run each attempt as a Kubernetes Job instead of locally (1, true, yes)
+  K8S_JOB_IMAGE        Container image for the Job (required with K8S_JOB_MODE)
+  K8S_JOB_SERVICE_ACCOUNT
+                       Service account for the Job's pod
+  K8S_JOB_NAMESPACE    Namespace for the Job (default: cronrunner's own namespace)
+  K8S_JOB_KEEP         Keep finished Jobs instead of deleting them (1, true, yes)
+  LAMBDA_FUNCTION_NAME Invoke this Lambda function with CRON_CMD as payload instead of running it
+  LAMBDA_TIMEOUT_SEC   Request timeout for each Lambda invocation
+  GCP_CLOUD_RUN_JOB    Run each attempt as an execution of this Cloud Run job instead of locally
+  GCP_PROJECT          Google Cloud project of the job (required with GCP_CLOUD_RUN_JOB)
+  GCP_REGION           Region of the job, e.g. europe-west1 (required with GCP_CLOUD_RUN_JOB)
+  CRON_SSH_HOST        Run the command on this host over SSH instead of locally (host or host:port)
+  CRON_SSH_USER        User to log in as (required with CRON_SSH_HOST)
+  CRON_SSH_KEY_FILE    Private key to log in with (required with CRON_SSH_HOST)
+  CRON_SSH_KNOWN_HOSTS known_hosts file to verify the host key (default ~/.ssh/known_hosts)
+  CRON_SSH_INSECURE    Don't verify the host key (1, true, yes)
+
+Schedule formats:
+  Seconds field        "*/10 * * * * *"   sec min hour dom month dow (seconds parser only)
+  Standard cron        "0 8 * * *"        min hour dom month dow (runs at second 0)
+  Interval             "@every 30s"       any Go duration: 30s, 2h, 1h30m
+  Descriptors          "@yearly" "@annually" "@monthly" "@weekly"
+                       "@daily" "@midnight" "@hourly"
+  Several schedules    "0 9 * * *; 0 17 * * *"   one command, separated by ";"
+
+Time settings (*_MIN, *_SEC) take a whole number of minutes or seconds, or a
+Go duration such as 90s, 5m or 1h30m.
+
+Flags:
+  -h, --help           Show this help and exit
+`
+
+func usage() {
+	fmt.Fprint(os.Stderr, usageText)
+	flag.PrintDefaults()
+}
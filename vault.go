@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// vaultSecrets reads VAULT_SECRET_PATH at the start of each run so the
+// command's environment always carries the current secret values.
+type vaultSecrets struct {
+	client  *vault.Client
+	path    string
+	renew   bool
+	timeout time.Duration
+
+	mu      sync.Mutex
+	cached  map[string]string
+	expires time.Time
+}
+
+// newVaultSecrets builds a client from the standard VAULT_ADDR and
+// VAULT_TOKEN environment variables.
+func newVaultSecrets(path string, renew bool, timeout time.Duration) (*vaultSecrets, error) {
+	vcfg := vault.DefaultConfig()
+	if vcfg.Error != nil {
+		return nil, vcfg.Error
+	}
+	vcfg.Timeout = timeout
+	// Vault's transport carries its own TLS settings, so only the proxy is
+	// taken from cronrunner's.
+	if t, ok := vcfg.HttpClient.Transport.(*http.Transport); ok {
+		t.Proxy = sharedTransport.Proxy
+	}
+	client, err := vault.NewClient(vcfg)
+	if err != nil {
+		return nil, err
+	}
+	if client.Token() == "" {
+		return nil, fmt.Errorf("VAULT_TOKEN is not set")
+	}
+	return &vaultSecrets{
+		client:  client,
+		path:    strings.Trim(path, "/"),
+		renew:   renew,
+		timeout: vcfg.Timeout,
+	}, nil
+}
+
+// env returns the secret's key/value pairs. With VAULT_SECRET_LEASE_RENEW the
+// previous values are reused until their lease is close to expiring;
+// otherwise the secret is read again every time.
+func (v *vaultSecrets) env() (map[string]string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.renew && v.cached != nil && time.Now().Before(v.expires) {
+		return v.cached, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+	secret, err := v.client.Logical().ReadWithContext(ctx, v.path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", v.path, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("no secret at %s", v.path)
+	}
+
+	data := secret.Data
+	// KV version 2 nests the values under "data", next to "metadata".
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+
+	out := make(map[string]string, len(data))
+	for k, val := range data {
+		switch val := val.(type) {
+		case string:
+			out[k] = val
+		case nil:
+			out[k] = ""
+		default:
+			b, err := json.Marshal(val)
+			if err != nil {
+				return nil, fmt.Errorf("encode %s: %w", k, err)
+			}
+			out[k] = string(b)
+		}
+	}
+
+	v.cached = out
+	v.expires = time.Time{}
+	if lease := time.Duration(secret.LeaseDuration) * time.Second; lease > 0 {
+		// Re-read once 80% of the lease has elapsed, so a run never starts
+		// with credentials about to expire.
+		v.expires = time.Now().Add(lease * 4 / 5)
+	}
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+const waitForRetryInterval = 2 * time.Second
+
+// waitForAddrs blocks until a TCP connection can be opened to every address
+// in addrs, retrying until ctx is cancelled or timeout (when non-zero)
+// elapses.
+func waitForAddrs(ctx context.Context, addrs []string, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for _, addr := range addrs {
+		for attempt := 1; ; attempt++ {
+			var d net.Dialer
+			dialCtx, cancel := context.WithTimeout(ctx, waitForRetryInterval)
+			conn, err := d.DialContext(dialCtx, "tcp", addr)
+			cancel()
+			if err == nil {
+				_ = conn.Close()
+				logf(LevelInfo, "CRON_WAIT_FOR: %s is reachable", addr)
+				break
+			}
+			logf(LevelWarn, "CRON_WAIT_FOR: %s not reachable (attempt %d): %v", addr, attempt, err)
+
+			select {
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					return fmt.Errorf("%s not reachable within %v", addr, timeout)
+				}
+				return ctx.Err()
+			case <-time.After(waitForRetryInterval):
+			}
+		}
+	}
+	return nil
+}
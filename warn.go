@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// startWarnTimer starts the CRON_WARN_AFTER timer for a run that began at
+// start. If the run is still going when it fires, a warning is logged and,
+// with CRON_WARN_WEBHOOK, sent to the webhook. The returned function
+// cancels the timer's context and must be called once the run is over.
+func (r *runner) startWarnTimer(meta RunMeta, start, hardDeadline time.Time) context.CancelFunc {
+	if r.cfg.warnAfter <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		t := time.NewTimer(time.Until(start.Add(r.cfg.warnAfter)))
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		running := time.Since(start).Round(time.Millisecond)
+		if hardDeadline.IsZero() {
+			logf(LevelWarn, "Warning: run %s has been running for %v (CRON_WARN_AFTER=%v)", meta.RunID, running, r.cfg.warnAfter)
+		} else {
+			logf(LevelWarn, "Warning: run %s has been running for %v (CRON_WARN_AFTER=%v); it will be killed at %s, in %v",
+				meta.RunID, running, r.cfg.warnAfter, hardDeadline.Format(time.RFC3339), time.Until(hardDeadline).Round(time.Second))
+		}
+		if r.cfg.warnWebhook && r.webhook != nil {
+			w := runWarning{
+				Event:     "warning",
+				Job:       meta.Job,
+				RunID:     meta.RunID,
+				StartedAt: start,
+				RunningMs: running.Milliseconds(),
+			}
+			if !hardDeadline.IsZero() {
+				w.KillAt = &hardDeadline
+			}
+			r.webhook.warn(w)
+		}
+	}()
+	return cancel
+}
+
+// runWarning is the webhook payload sent when a run passes
+// CRON_WARN_AFTER.
+type runWarning struct {
+	Event     string     `json:"event"`
+	Job       string     `json:"job"`
+	RunID     string     `json:"run_id"`
+	StartedAt time.Time  `json:"started_at"`
+	RunningMs int64      `json:"running_ms"`
+	KillAt    *time.Time `json:"kill_at,omitempty"`
+}
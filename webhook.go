@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookNotifier POSTs the outcome of every run as JSON to
+// NOTIFY_WEBHOOK_URL.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookNotifier(url string, timeout time.Duration, tlsCfg *tls.Config) *webhookNotifier {
+	client := newHTTPClient(timeout)
+	if tlsCfg != nil {
+		transport := sharedTransport.Clone()
+		transport.TLSClientConfig = tlsCfg
+		client.Transport = transport
+	}
+	return &webhookNotifier{url: url, client: client}
+}
+
+// webhookPayload is the run record plus a summary status.
+type webhookPayload struct {
+	runRecord
+	Status string `json:"status"`
+}
+
+// test sends {"event":"test"} for CRONRUNNER_SELFTEST and fails unless
+// the endpoint answers with a 2xx status.
+func (w *webhookNotifier) test() error {
+	resp, err := w.client.Post(w.url, "application/json", strings.NewReader(`{"event":"test"}`))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// report delivers the notification before the run is considered finished,
+// bounded by the client timeout. Failures are logged and never affect the
+// run's outcome.
+func (w *webhookNotifier) report(rec runRecord) {
+	payload := webhookPayload{runRecord: rec, Status: rec.status()}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logf(LevelError, "Failed to encode webhook for run %s: %v", rec.RunID, err)
+		return
+	}
+
+	w.send(rec.RunID, body)
+}
+
+// warn sends the CRON_WARN_WEBHOOK notification for a run that is still
+// going.
+func (w *webhookNotifier) warn(rw runWarning) {
+	body, err := json.Marshal(rw)
+	if err != nil {
+		logf(LevelError, "Failed to encode warning webhook for run %s: %v", rw.RunID, err)
+		return
+	}
+	w.send(rw.RunID, body)
+}
+
+func (w *webhookNotifier) send(runID string, body []byte) {
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logf(LevelError, "Failed to send webhook for run %s: %v", runID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		logf(LevelWarn, "Webhook for run %s returned %s", runID, resp.Status)
+	}
+}